@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+	"github.com/JohnDeved/myrient-cli/internal/walk"
+)
+
+// batchResult is the outcome of one request in a batch download.
+type batchResult struct {
+	Req downloader.Request
+	Err error
+	// Present is set when the file already existed and was skipped.
+	Present bool
+	// Skipped says why the request was left to another download, e.g. an
+	// earlier duplicate in the batch or one already queued in the daemon.
+	Skipped string
+	// StoredName is set when the filesystem profile stored the file under
+	// a name other than Req.Name.
+	StoredName string
+}
+
+// downloadBatch downloads all requests through a single manager using the
+// configured parallelism and waits until every item has finished. Ctrl+C
+// pauses the batch and saves it for --resume; the paused requests report
+// errInterrupted.
+func downloadBatch(c *client.Client, cfg *config.Config, outDir string, reqs []downloader.Request) []batchResult {
+	dlm := downloader.NewManager(c, outDir, cfg.MaxConcurrentDownloads)
+	dlm.ApplyConfig(cfg)
+	defer recordHistory(dlm, c.BaseURL(), warnHistory)()
+	events, unsubscribe := dlm.Subscribe(64)
+	defer unsubscribe()
+
+	results := make([]batchResult, len(reqs))
+	byID := make(map[int]int, len(reqs))
+	for i, req := range reqs {
+		req.Extract = req.Extract || cfg.ExtractArchives
+		results[i].Req = req
+		item, created := dlm.Add(req)
+		if !created {
+			results[i].Skipped = fmt.Sprintf("duplicate of %s", item.Name)
+			continue
+		}
+		byID[item.ID] = i
+		if item.OriginalName != "" {
+			results[i].StoredName = item.Name
+		}
+	}
+	defer printStoredNames(cfg, results)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// Held items only resume when space frees up; in a one-shot CLI run
+	// that means waiting forever.
+	if !followBatch(ctx, events, byID, results, dlm.Cancel, cfg.Progress) {
+		// A second Ctrl+C while pausing kills the process.
+		stop()
+		pauseBatch(dlm, cfg, outDir, byID, results)
+	}
+	return results
+}
+
+// followBatch consumes events until every tracked item has finished,
+// recording outcomes in results and rendering them with the progress mode.
+// byID maps item IDs to result indices and is drained as items finish.
+// onHeld, if set, is called for items held for insufficient space. It
+// returns false if ctx ended first.
+func followBatch(ctx context.Context, events <-chan downloader.Event, byID map[int]int, results []batchResult, onHeld func(id int), mode string) bool {
+	view := newProgressView(mode, len(byID))
+	defer view.close()
+	for len(byID) > 0 {
+		var ev downloader.Event
+		select {
+		case <-ctx.Done():
+			return false
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			ev = e
+		}
+		i, tracked := byID[ev.ItemID]
+		if !tracked {
+			continue
+		}
+		view.handle(ev, displayName(results[i].Req))
+		switch ev.Type {
+		case downloader.EventFailed:
+			if ev.Status == downloader.StatusNoSpace && onHeld != nil {
+				onHeld(ev.ItemID)
+			}
+			results[i].Err = ev.Err
+			delete(byID, ev.ItemID)
+		case downloader.EventCompleted:
+			results[i].Present = ev.Status == downloader.StatusPresent
+			delete(byID, ev.ItemID)
+		}
+	}
+	return true
+}
+
+// printStoredNames lists the files of a batch that were stored under names
+// adapted to the filesystem profile.
+func printStoredNames(cfg *config.Config, results []batchResult) {
+	var renamed []batchResult
+	for _, r := range results {
+		if r.StoredName != "" && r.Err == nil {
+			renamed = append(renamed, r)
+		}
+	}
+	if len(renamed) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Stored %d file(s) under %s-compatible names (listed in %s):\n", len(renamed), cfg.FSProfile, downloader.NamesFile)
+	for _, r := range renamed {
+		fmt.Fprintf(os.Stderr, "  %s -> %s\n", r.Req.Name, r.StoredName)
+	}
+}
+
+func displayName(req downloader.Request) string {
+	if req.Subdir == "" {
+		return req.Name
+	}
+	return path.Join(filepath.ToSlash(req.Subdir), req.Name)
+}
+
+// batchError prints a one-line summary and returns an error listing the
+// failed results, or nil when all succeeded.
+func batchError(results []batchResult) error {
+	var failures []string
+	present, paused, skipped := 0, 0, 0
+	for _, r := range results {
+		switch {
+		case errors.Is(r.Err, errInterrupted):
+			paused++
+		case r.Err != nil:
+			failures = append(failures, fmt.Sprintf("%s: %v", displayName(r.Req), r.Err))
+		case r.Present:
+			present++
+		case r.Skipped != "":
+			skipped++
+		}
+	}
+	summary := fmt.Sprintf("Downloaded %d, already present %d, failed %d",
+		len(results)-present-paused-skipped-len(failures), present, len(failures))
+	if skipped > 0 {
+		summary += fmt.Sprintf(", skipped %d", skipped)
+	}
+	if paused > 0 {
+		summary += fmt.Sprintf(", paused %d", paused)
+	}
+	fmt.Fprintln(os.Stderr, summary)
+	if len(failures) == 0 {
+		if paused > 0 {
+			return fmt.Errorf("%w: %d download(s) paused", errInterrupted, paused)
+		}
+		return nil
+	}
+	return fmt.Errorf("%d download(s) failed:\n- %s", len(failures), strings.Join(failures, "\n- "))
+}
+
+// remoteDirPath converts a directory URL or path argument into a listing
+// path relative to the base URL, e.g. "No-Intro/Nintendo - Game Boy/".
+func remoteDirPath(baseURL, arg string) string {
+	arg = strings.TrimSpace(arg)
+	base := strings.TrimRight(baseURL, "/") + "/"
+	if strings.HasPrefix(arg, base) {
+		arg = strings.TrimPrefix(arg, base)
+		if decoded, err := url.PathUnescape(arg); err == nil {
+			arg = decoded
+		}
+	}
+	return normalizeListPath(arg)
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from stdin.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+func walkFilterFromFlags(cmd *cobra.Command) (*walk.Filter, error) {
+	include, _ := cmd.Flags().GetStringArray("include")
+	exclude, _ := cmd.Flags().GetStringArray("exclude")
+	minRaw, _ := cmd.Flags().GetString("min-size")
+	maxRaw, _ := cmd.Flags().GetString("max-size")
+	minSize, maxSize := util.ParseSize(minRaw), util.ParseSize(maxRaw)
+	if minRaw != "" && minSize == 0 {
+		return nil, fmt.Errorf("invalid --min-size %q", minRaw)
+	}
+	if maxRaw != "" && maxSize == 0 {
+		return nil, fmt.Errorf("invalid --max-size %q", maxRaw)
+	}
+	return walk.NewFilter(include, exclude, minSize, maxSize)
+}
+
+// walkFromFlags lists the files below dirPath that pass the
+// --include/--exclude/--min-size/--max-size filters, from the local index
+// when --use-index is set.
+func walkFromFlags(cmd *cobra.Command, c *client.Client, dirPath string) ([]walk.File, error) {
+	filter, err := walkFilterFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var files []walk.File
+	if useIndex, _ := cmd.Flags().GetBool("use-index"); useIndex {
+		db, err := index.OpenDB(config.DBPath())
+		if err != nil {
+			return nil, fmt.Errorf("opening database: %w", err)
+		}
+		files, err = walk.Indexed(db, dirPath, filter)
+		db.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Listing /%s recursively...\n", dirPath)
+		files, err = walk.Remote(context.Background(), c, dirPath, filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files matched under /%s", dirPath)
+	}
+	return files, nil
+}
+
+func runRecursiveDownload(cmd *cobra.Command, c *client.Client, cfg *config.Config, outDir string, tmpl *destpath.Template, arg string) error {
+	dirPath := remoteDirPath(c.BaseURL(), arg)
+	if dirPath == "" {
+		return fmt.Errorf("refusing to download the whole archive; pass a directory path")
+	}
+	files, err := walkFromFlags(cmd, c, dirPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Matched %d file(s), %s total, under /%s\n",
+		len(files), util.FormatBytes(walk.TotalSize(files)), dirPath)
+
+	reqs := make([]downloader.Request, 0, len(files))
+	for _, f := range files {
+		subdir, name := tmpl.Resolve(dirPath + f.RelPath)
+		reqs = append(reqs, downloader.Request{
+			Name:   name,
+			URL:    f.URL,
+			Subdir: subdir,
+			Size:   f.Size,
+		})
+	}
+
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if dryRun {
+		for _, f := range files {
+			fmt.Printf("%s\t%s\t%s\n", f.SizeText, f.RelPath, destFor(c, cfg, outDir, tmpl, f.URL))
+		}
+		return nil
+	}
+
+	yes, _ := cmd.Flags().GetBool("yes")
+	if !yes {
+		if !isInteractiveTerminal() {
+			return fmt.Errorf("refusing to start a recursive download without confirmation; pass --yes")
+		}
+		if !confirm("Download these files?") {
+			return fmt.Errorf("aborted")
+		}
+	}
+
+	if dc := daemonFromFlags(cmd); dc != nil {
+		results, err := submitToDaemon(cmd, dc, cfg, outDir, reqs)
+		if err != nil || results == nil {
+			return err
+		}
+		return batchError(results)
+	}
+	fmt.Fprintf(os.Stderr, "To: %s\n", outDir)
+	cmd.SilenceUsage = true
+	return batchError(downloadBatch(c, cfg, outDir, reqs))
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/daemon"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+)
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	indexEvery, _ := cmd.Flags().GetDuration("index-every")
+
+	c := client.New(cfg.BaseURL, cfg.RequestsPerSecond)
+	dlm := downloader.NewManager(c, cfg.DownloadDir, cfg.MaxConcurrentDownloads)
+	dlm.ApplyConfig(cfg)
+	defer recordHistory(dlm, c.BaseURL(), func(err error) {
+		daemonLog("History: %v", err)
+	})()
+
+	sock := config.SocketPath()
+	ln, err := daemon.Listen(sock)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sock)
+
+	// Offer partials left by earlier sessions as paused downloads.
+	if adopted, unmatched, err := adoptParts(dlm, cfg.DownloadDir); err != nil {
+		daemonLog("Scanning for partial downloads: %v", err)
+	} else if adopted+unmatched > 0 {
+		daemonLog("Adopted %d partial download(s) as paused, %d unmatched (see 'myrient parts')", adopted, unmatched)
+	}
+
+	// The daemon outlives the terminal that started it.
+	signal.Ignore(syscall.SIGHUP)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	srv := daemon.NewServer(dlm, cfg.DownloadDir)
+	srv.SetShutdown(cancel)
+
+	events, unsubscribe := dlm.Subscribe(64)
+	defer unsubscribe()
+	go logDaemonEvents(events)
+
+	if indexEvery > 0 {
+		go refreshIndexEvery(ctx, c, cfg, indexEvery)
+	}
+
+	daemonLog("Listening on %s, downloading to %s", sock, cfg.DownloadDir)
+	err = srv.Serve(ctx, ln)
+	// Pausing keeps the partial files, which the next start adopts, and
+	// waits for running transfers to stop writing.
+	if paused := dlm.PauseAll(); len(paused) > 0 {
+		daemonLog("Paused %d download(s)", len(paused))
+	}
+	daemonLog("Stopped")
+	return err
+}
+
+func daemonLog(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "%s %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+}
+
+func logDaemonEvents(events <-chan downloader.Event) {
+	for ev := range events {
+		switch ev.Type {
+		case downloader.EventEnqueued:
+			daemonLog("Queued #%d %s", ev.ItemID, ev.Name)
+		case downloader.EventCompleted:
+			if ev.Status == downloader.StatusPresent {
+				daemonLog("Already present #%d %s", ev.ItemID, ev.Name)
+			} else {
+				daemonLog("Downloaded #%d %s", ev.ItemID, ev.DestPath)
+			}
+		case downloader.EventFailed:
+			daemonLog("Failed #%d %s: %v", ev.ItemID, ev.Name, ev.Err)
+		case downloader.EventRetrying:
+			daemonLog("Retrying #%d %s after %s error: %v", ev.ItemID, ev.Name, ev.Class, ev.Err)
+		}
+	}
+}
+
+// refreshIndexEvery re-crawls stale index directories on a fixed interval.
+func refreshIndexEvery(ctx context.Context, c *client.Client, cfg *config.Config, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		db, err := index.OpenDB(config.DBPath())
+		if err != nil {
+			daemonLog("Index refresh skipped: opening database: %v", err)
+			continue
+		}
+		daemonLog("Index refresh started")
+		crawler := index.NewCrawler(c, db, cfg.IndexStaleDays)
+		err = crawler.CrawlAll(ctx)
+		p := crawler.Progress()
+		db.Close()
+		if err != nil && ctx.Err() == nil {
+			daemonLog("Index refresh failed: %v", err)
+			continue
+		}
+		daemonLog("Index refresh done (%d dirs, %d files, %d errors)", p.DirsProcessed, p.FilesFound, p.Errors)
+	}
+}
+
+func runDaemonStatus(cmd *cobra.Command, args []string) error {
+	dc, err := daemon.Dial(config.SocketPath())
+	if err != nil {
+		fmt.Println("No daemon running")
+		return nil
+	}
+	items, stats, err := dc.List()
+	if err != nil {
+		return err
+	}
+	active, pending := 0, 0
+	for _, it := range items {
+		if it.Status == downloader.StatusActive {
+			active++
+		}
+		if it.Status.Pending() {
+			pending++
+		}
+	}
+	fmt.Printf("Daemon running on %s\n", config.SocketPath())
+	fmt.Printf("Download dir: %s\n", stats.DownloadDir)
+	fmt.Printf("Queue: %d item(s), %d pending, %d active, %s/s\n",
+		len(items), pending, active, util.FormatBytes(int64(stats.Throughput)))
+	return nil
+}
+
+func runDaemonStop(cmd *cobra.Command, args []string) error {
+	dc, err := requireDaemon()
+	if err != nil {
+		return err
+	}
+	if err := dc.Shutdown(); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Daemon stopping")
+	return nil
+}
+
+// requireDaemon connects to the running daemon or explains how to start one.
+func requireDaemon() (*daemon.Client, error) {
+	dc, err := daemon.Dial(config.SocketPath())
+	if err != nil {
+		return nil, errors.New("no daemon running (start one with 'myrient daemon')")
+	}
+	return dc, nil
+}
+
+// daemonFromFlags returns a client for the running daemon unless
+// --no-daemon was given or no daemon answers.
+func daemonFromFlags(cmd *cobra.Command) *daemon.Client {
+	if noDaemon, _ := cmd.Flags().GetBool("no-daemon"); noDaemon {
+		return nil
+	}
+	dc, err := daemon.Dial(config.SocketPath())
+	if err != nil {
+		return nil
+	}
+	return dc
+}
+
+// submitToDaemon hands requests to the daemon and, unless --detach is set,
+// follows them until they finish. Interrupting only stops following. The
+// returned results are nil when the downloads were not followed to the end.
+// Requests without a Dir are saved below outDir.
+func submitToDaemon(cmd *cobra.Command, dc *daemon.Client, cfg *config.Config, outDir string, reqs []downloader.Request) ([]batchResult, error) {
+	absDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return nil, fmt.Errorf("resolving output directory: %w", err)
+	}
+	for i := range reqs {
+		if reqs[i].Dir == "" {
+			reqs[i].Dir = absDir
+		}
+		reqs[i].Extract = reqs[i].Extract || cfg.ExtractArchives
+	}
+	for _, name := range []string{"skip-existing", "verify-existing", "overwrite"} {
+		if cmd.Flags().Changed(name) {
+			fmt.Fprintf(os.Stderr, "Note: --%s is ignored; the daemon uses its own existing_files setting\n", name)
+		}
+	}
+	if cmd.Flags().Changed("fs-profile") {
+		fmt.Fprintln(os.Stderr, "Note: --fs-profile is ignored; the daemon uses its own fs_profile setting")
+	}
+
+	detach, _ := cmd.Flags().GetBool("detach")
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var events <-chan downloader.Event
+	if !detach {
+		// Watch before adding so no event for the new items is missed.
+		events, err = dc.Watch(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("watching daemon: %w", err)
+		}
+	}
+
+	added, err := dc.Add(reqs)
+	if err != nil {
+		return nil, fmt.Errorf("submitting to daemon: %w", err)
+	}
+	results := make([]batchResult, len(reqs))
+	byID := make(map[int]int, len(reqs))
+	queued := 0
+	for i, a := range added {
+		results[i].Req = reqs[i]
+		if !a.Created {
+			results[i].Skipped = fmt.Sprintf("already queued as #%d", a.ID)
+			fmt.Fprintf(os.Stderr, "Already queued as #%d: %s\n", a.ID, reqs[i].Name)
+			continue
+		}
+		byID[a.ID] = i
+		queued++
+	}
+	fmt.Fprintf(os.Stderr, "Submitted %d file(s) to the daemon, to: %s\n", queued, absDir)
+	if detach || queued == 0 {
+		return nil, nil
+	}
+
+	if !followBatch(ctx, events, byID, results, nil, cfg.Progress) {
+		fmt.Fprintln(os.Stderr, "Stopped following; downloads continue in the daemon (see 'myrient queue list')")
+		return nil, nil
+	}
+	return results, nil
+}
+
+func runQueueList(cmd *cobra.Command, args []string) error {
+	dc, err := requireDaemon()
+	if err != nil {
+		return err
+	}
+	items, stats, err := dc.List()
+	if err != nil {
+		return err
+	}
+
+	if jsonMode, _ := cmd.Flags().GetBool("json"); jsonMode {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Items []downloader.Snapshot `json:"items"`
+			Stats *daemon.Stats         `json:"stats"`
+		}{items, stats})
+	}
+
+	if len(items) == 0 {
+		fmt.Println("Queue is empty")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tPROGRESS\tSIZE\tSPEED\tNAME")
+	for _, it := range items {
+		progress := "-"
+		if it.TotalBytes > 0 {
+			progress = fmt.Sprintf("%.0f%%", it.Progress()*100)
+		}
+		size := "-"
+		if it.TotalBytes > 0 {
+			size = util.FormatBytes(it.TotalBytes)
+		}
+		speed := "-"
+		if it.Speed > 0 {
+			speed = util.FormatBytes(int64(it.Speed)) + "/s"
+		}
+		name := it.Name
+		if it.Error != "" {
+			name += " (" + it.Error + ")"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", it.ID, it.Status, progress, size, speed, name)
+	}
+	tw.Flush()
+	if stats.ETAKnown {
+		fmt.Printf("\nThroughput %s/s, ETA %s\n", util.FormatBytes(int64(stats.Throughput)), util.FormatDuration(stats.ETA))
+	}
+	return nil
+}
+
+func runQueueAdd(cmd *cobra.Command, args []string) error {
+	dc, err := requireDaemon()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	tmpl, err := pathTemplateFromFlags(cmd, cfg)
+	if err != nil {
+		return err
+	}
+	outDir, _ := cmd.Flags().GetString("output")
+	if outDir == "" {
+		outDir = cfg.DownloadDir
+	}
+	absDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return fmt.Errorf("resolving output directory: %w", err)
+	}
+
+	c := client.New(cfg.BaseURL, cfg.RequestsPerSecond)
+	var reqs []downloader.Request
+	for _, arg := range args {
+		req, err := fileRequest(c, tmpl, arg)
+		if err != nil {
+			return err
+		}
+		req.Dir = absDir
+		reqs = append(reqs, req)
+	}
+	added, err := dc.Add(reqs)
+	if err != nil {
+		return err
+	}
+	for i, a := range added {
+		if a.Created {
+			fmt.Printf("#%d queued: %s\n", a.ID, reqs[i].Name)
+		} else {
+			fmt.Printf("#%d already queued: %s\n", a.ID, reqs[i].Name)
+		}
+	}
+	return nil
+}
+
+func runQueueOp(cmd *cobra.Command, args []string) error {
+	dc, err := requireDaemon()
+	if err != nil {
+		return err
+	}
+	op := cmd.Name()
+
+	if op == "clear" {
+		n, err := dc.ClearFinished()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Cleared %d finished download(s)\n", n)
+		return nil
+	}
+	if all, _ := cmd.Flags().GetBool("all"); all && op == "cancel" {
+		if err := dc.CancelAll(); err != nil {
+			return err
+		}
+		fmt.Println("Cancelled all pending downloads")
+		return nil
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("%s needs at least one download ID (see 'myrient queue list')", op)
+	}
+
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid download ID %q", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	var n int
+	switch op {
+	case "pause":
+		n, err = dc.Pause(ids...)
+	case "resume":
+		n, err = dc.Resume(ids...)
+	case "cancel":
+		n, err = dc.Cancel(ids...)
+	case "retry":
+		n, err = dc.Retry(ids...)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d of %d download(s) affected\n", op, n, len(ids))
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/dat"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+)
+
+func runDATImport(cmd *cobra.Command, args []string) error {
+	dats, err := dat.Open(config.DATDBPath())
+	if err != nil {
+		return err
+	}
+	defer dats.Close()
+	db, err := index.OpenDB(config.DBPath())
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	for _, p := range args {
+		loaded, err := dat.Load(p)
+		if err != nil {
+			return err
+		}
+		for _, df := range loaded {
+			info, err := dats.Import(df)
+			if err != nil {
+				return fmt.Errorf("importing %s: %w", df.Header.Name, err)
+			}
+			linked, err := linkedGames(dats, db, info)
+			if err != nil {
+				return err
+			}
+			version := ""
+			if info.Version != "" {
+				version = " (" + info.Version + ")"
+			}
+			fmt.Printf("Imported %s%s: %d games, %d ROMs, %d linked to indexed files\n",
+				info.Name, version, info.Games, info.ROMs, linked)
+		}
+	}
+	return nil
+}
+
+// linkedGames counts the games of an imported DAT that have an indexed file.
+func linkedGames(dats *dat.DB, db *index.DB, info dat.Info) (int, error) {
+	games, err := dats.GameNames(info.ID)
+	if err != nil {
+		return 0, err
+	}
+	linked, err := db.CountLinkedGames(games)
+	if err != nil {
+		return 0, fmt.Errorf("linking %s to the index: %w", info.Name, err)
+	}
+	return linked, nil
+}
+
+// datOut is an imported DAT as printed by "dat list --json".
+type datOut struct {
+	Name       string `json:"name"`
+	Version    string `json:"version,omitempty"`
+	ImportedAt string `json:"imported_at"`
+	Games      int    `json:"games"`
+	ROMs       int    `json:"roms"`
+	Linked     int    `json:"linked"`
+}
+
+func runDATList(cmd *cobra.Command, args []string) error {
+	dats, err := dat.Open(config.DATDBPath())
+	if err != nil {
+		return err
+	}
+	defer dats.Close()
+	db, err := index.OpenDB(config.DBPath())
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	infos, err := dats.List()
+	if err != nil {
+		return err
+	}
+	linked := make([]int, len(infos))
+	for i, info := range infos {
+		if linked[i], err = linkedGames(dats, db, info); err != nil {
+			return err
+		}
+	}
+
+	if jsonMode, _ := cmd.Flags().GetBool("json"); jsonMode {
+		out := make([]datOut, len(infos))
+		for i, info := range infos {
+			out[i] = datOut{Name: info.Name, Version: info.Version, ImportedAt: info.ImportedAt.Format("2006-01-02T15:04:05Z07:00"),
+				Games: info.Games, ROMs: info.ROMs, Linked: linked[i]}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+	if len(infos) == 0 {
+		fmt.Println("No DATs imported.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tVERSION\tGAMES\tROMS\tLINKED\tIMPORTED")
+	for i, info := range infos {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n", info.Name, orDash(info.Version), info.Games, info.ROMs,
+			linked[i], info.ImportedAt.Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+func runDATAudit(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	if format != "text" && format != "csv" && format != "json" {
+		return fmt.Errorf("unknown --format %q (use text, csv or json)", format)
+	}
+	only := map[string]bool{}
+	statuses, _ := cmd.Flags().GetStringSlice("only")
+	for _, s := range statuses {
+		s = strings.ToLower(strings.TrimSpace(s))
+		switch s {
+		case dat.StatusHave, dat.StatusMiss, dat.StatusBad, dat.StatusUnknown:
+			only[s] = true
+		default:
+			return fmt.Errorf("unknown --only status %q (use have, miss, bad or unknown)", s)
+		}
+	}
+	if info, err := os.Stat(args[0]); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", args[0])
+	}
+
+	datDB, err := dat.Open(config.DATDBPath())
+	if err != nil {
+		return err
+	}
+	defer datDB.Close()
+	names, _ := cmd.Flags().GetStringArray("dat")
+	dats, err := datDB.Load(names)
+	if err != nil {
+		return err
+	}
+	db, err := index.OpenDB(config.DBPath())
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	if len(dats) == 0 {
+		return fmt.Errorf("no DATs imported; run \"myrient dat import <file>\" first")
+	}
+
+	auditor := dat.NewAuditor(dats)
+	allMissing, _ := cmd.Flags().GetBool("all-missing")
+	auditor.SetAllMissing(allMissing || len(names) > 0)
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	rep, err := auditor.Audit(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("auditing %s: %w", args[0], err)
+	}
+	for i, row := range rep.Rows {
+		if row.Status != dat.StatusMiss {
+			continue
+		}
+		files, err := db.GameFiles(row.Game)
+		if err != nil {
+			return err
+		}
+		if len(files) > 0 {
+			rep.Rows[i].URL = files[0].URL
+		}
+	}
+	rep = rep.Filter(only)
+
+	var w io.Writer = os.Stdout
+	if outFile, _ := cmd.Flags().GetString("output-file"); outFile != "" && outFile != "-" {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	switch format {
+	case "csv":
+		return rep.WriteCSV(w)
+	case "json":
+		if rep.Rows == nil {
+			rep.Rows = []dat.Row{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+	return rep.WriteText(w)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+	"github.com/JohnDeved/myrient-cli/internal/discset"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+// discPick is a file to download, with the disc set it completes.
+type discPick struct {
+	URL string
+	// Title is the set title, or empty for files that are not part of a
+	// multi-disc set.
+	Title string
+}
+
+// entryNames returns the names of entries, in order.
+func entryNames(entries []client.Entry) []string {
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// expandDiscSets replaces every file URL that belongs to a multi-disc set
+// with all discs of the set, found in the file's directory listing.
+// listings caches directory listings by remote path.
+func expandDiscSets(c *client.Client, fileURLs []string, listings map[string][]client.Entry) ([]discPick, error) {
+	var picks []discPick
+	seen := map[string]bool{}
+	add := func(p discPick) {
+		if !seen[p.URL] {
+			seen[p.URL] = true
+			picks = append(picks, p)
+		}
+	}
+	for _, fileURL := range fileURLs {
+		remote := destpath.RemotePath(c.BaseURL(), fileURL)
+		if _, ok := discset.Parse(path.Base(remote)); !ok {
+			add(discPick{URL: fileURL})
+			continue
+		}
+		dir := normalizeListPath(path.Dir(remote))
+		entries, ok := listings[dir]
+		if !ok {
+			var err error
+			entries, err = c.ListDirectory(context.Background(), dir)
+			if err != nil {
+				return nil, fmt.Errorf("listing /%s: %w", dir, err)
+			}
+			listings[dir] = entries
+		}
+		names := entryNames(entries)
+		found := false
+		for i, name := range names {
+			if name != path.Base(remote) {
+				continue
+			}
+			if set, ok := discset.Find(names, i); ok {
+				for _, j := range set.Indices {
+					add(discPick{URL: entries[j].URL, Title: set.Title})
+				}
+				found = true
+			}
+			break
+		}
+		if !found {
+			add(discPick{URL: fileURL})
+		}
+	}
+	return picks, nil
+}
+
+// discSetRequest marks req as a disc of the set title: its playlist is
+// written when the set completes, and with folder the set gets a folder of
+// its own.
+func discSetRequest(req downloader.Request, title string, folder bool) downloader.Request {
+	if title == "" {
+		return req
+	}
+	req.Playlist = discset.PlaylistName(title)
+	if folder {
+		req.Subdir = path.Join(filepath.ToSlash(req.Subdir), title)
+	}
+	return req
+}
+
+// downloadDiscSets downloads the files with every disc set they belong to
+// completed, through the daemon when one is running. Sets are downloaded
+// by one manager, so each set's playlist is written once all its discs are
+// there.
+func downloadDiscSets(cmd *cobra.Command, c *client.Client, cfg *config.Config, outDir string, tmpl *destpath.Template, fileURLs []string, listings map[string][]client.Entry) error {
+	folder, _ := cmd.Flags().GetBool("set-folder")
+	folder = folder || cfg.DiscSetFolder
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	profile, _ := downloader.ParseFSProfile(cfg.FSProfile)
+
+	picks, err := expandDiscSets(c, fileURLs, listings)
+	if err != nil {
+		return err
+	}
+	var reqs []downloader.Request
+	for _, p := range picks {
+		if dryRun {
+			subdir, name := tmpl.Resolve(destpath.RemotePath(c.BaseURL(), p.URL))
+			req := discSetRequest(downloader.Request{Name: name, Subdir: subdir}, p.Title, folder)
+			fmt.Fprintf(os.Stderr, "Save to: %s\n", filepath.Join(outDir, filepath.FromSlash(profile.Subdir(req.Subdir)), profile.Name(req.Name)))
+			continue
+		}
+		req, err := fileRequest(c, tmpl, p.URL)
+		if err != nil {
+			return err
+		}
+		reqs = append(reqs, discSetRequest(req, p.Title, folder))
+	}
+	if dryRun {
+		return nil
+	}
+
+	cmd.SilenceUsage = true
+	var results []batchResult
+	if dc := daemonFromFlags(cmd); dc != nil {
+		results, err = submitToDaemon(cmd, dc, cfg, outDir, reqs)
+		if err != nil || results == nil {
+			return err
+		}
+	} else {
+		results = downloadBatch(c, cfg, outDir, reqs)
+	}
+	printPlaylists(outDir, reqs, profile)
+	return batchError(results)
+}
+
+// printPlaylists reports the playlists written for the disc sets among
+// the finished requests.
+func printPlaylists(outDir string, reqs []downloader.Request, profile downloader.FSProfile) {
+	seen := map[string]bool{}
+	for _, req := range reqs {
+		if req.Playlist == "" {
+			continue
+		}
+		dir := req.Dir
+		if dir == "" {
+			dir = outDir
+		}
+		p := filepath.Join(dir, filepath.FromSlash(profile.Subdir(filepath.ToSlash(req.Subdir))), profile.Name(req.Playlist))
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		if _, err := os.Stat(p); err == nil {
+			fmt.Fprintf(os.Stderr, "Playlist: %s\n", p)
+		}
+	}
+}
+
+// discLabel describes where the i-th listed name sits in its disc set, e.g.
+// "disc 1 of 3", or returns "" for files that are not part of one.
+func discLabel(sets map[int]discset.Set, i int) string {
+	set, ok := sets[i]
+	if !ok {
+		return ""
+	}
+	for n, j := range set.Indices {
+		if j == i {
+			return fmt.Sprintf("disc %d of %d", n+1, len(set.Indices))
+		}
+	}
+	return ""
+}
+
+// setsByIndex maps every name that is part of a disc set to its set.
+func setsByIndex(names []string) map[int]discset.Set {
+	out := map[int]discset.Set{}
+	for _, set := range discset.Sets(names) {
+		for _, i := range set.Indices {
+			out[i] = set
+		}
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+	"github.com/JohnDeved/myrient-cli/internal/export"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+)
+
+// exportFile is a file selected for export, before its destination is
+// resolved.
+type exportFile struct {
+	// Remote is the path relative to the archive root.
+	Remote string
+	URL    string
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	formatRaw, _ := cmd.Flags().GetString("format")
+	format, err := export.ParseFormat(formatRaw)
+	if err != nil {
+		return err
+	}
+	tmpl, err := pathTemplateFromFlags(cmd, cfg)
+	if err != nil {
+		return err
+	}
+	outDir, _ := cmd.Flags().GetString("output")
+	if outDir == "" {
+		outDir = cfg.DownloadDir
+	}
+
+	c := client.New(cfg.BaseURL, cfg.RequestsPerSecond)
+	from, _ := cmd.Flags().GetString("from")
+	limit, _ := cmd.Flags().GetInt("limit")
+	arg := strings.Join(args, " ")
+
+	var files []exportFile
+	switch from {
+	case "search":
+		files, err = exportFromSearch(cmd, arg, limit)
+	case "find":
+		resolver := newQueryResolver(cmd, c)
+		resolver.matchLimit = limit
+		matches, rerr := resolver.matches(arg)
+		if rerr != nil {
+			return rerr
+		}
+		for _, m := range matches {
+			files = append(files, exportFile{Remote: destpath.RemotePath(c.BaseURL(), m.URL), URL: m.URL})
+		}
+	case "dir":
+		dirPath := remoteDirPath(c.BaseURL(), arg)
+		if dirPath == "" {
+			return fmt.Errorf("refusing to export the whole archive; pass a directory path")
+		}
+		walked, werr := walkFromFlags(cmd, c, dirPath)
+		if werr != nil {
+			return werr
+		}
+		for _, f := range walked {
+			files = append(files, exportFile{Remote: dirPath + f.RelPath, URL: f.URL})
+		}
+	default:
+		return fmt.Errorf("unknown --from %q (want search, find or dir)", from)
+	}
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("nothing to export for %q", arg)
+	}
+
+	probe, _ := cmd.Flags().GetBool("probe-sizes")
+	entries := make([]export.Entry, 0, len(files))
+	for _, f := range files {
+		subdir, name := tmpl.Resolve(f.Remote)
+		e := export.Entry{URL: f.URL, Path: strings.TrimPrefix(subdir+"/"+name, "/")}
+		if probe {
+			e.Size, err = probeSize(c, f.URL)
+			if err != nil {
+				return err
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	outFile, _ := cmd.Flags().GetString("output-file")
+	var w io.Writer = os.Stdout
+	if outFile != "" && outFile != "-" {
+		mode := os.FileMode(0o644)
+		if format == export.Wget || format == export.Curl {
+			mode = 0o755
+		}
+		f, err := os.OpenFile(outFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+		if err != nil {
+			return fmt.Errorf("creating export file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := export.Write(w, format, outDir, entries); err != nil {
+		return fmt.Errorf("writing export: %w", err)
+	}
+	if outFile != "" && outFile != "-" {
+		fmt.Fprintf(os.Stderr, "Exported %d file(s) as %s to %s\n", len(entries), format, outFile)
+	}
+	return nil
+}
+
+func exportFromSearch(cmd *cobra.Command, query string, limit int) ([]exportFile, error) {
+	db, err := index.OpenDB(config.DBPath())
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	collection, _ := cmd.Flags().GetString("collection")
+	var results []index.SearchResult
+	if collection != "" {
+		results, err = db.SearchInCollection(query, collection, limit)
+	} else {
+		results, err = db.Search(query, limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	files := make([]exportFile, 0, len(results))
+	for _, r := range results {
+		files = append(files, exportFile{Remote: r.Path, URL: r.URL})
+	}
+	return files, nil
+}
+
+// probeSize asks the server for the exact size of a file. Listings only
+// carry rounded sizes.
+func probeSize(c *client.Client, fileURL string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	body, size, _, err := c.DownloadFile(ctx, fileURL, 0)
+	if err != nil {
+		return 0, fmt.Errorf("probing size of %s: %w", fileURL, err)
+	}
+	body.Close()
+	return size, nil
+}
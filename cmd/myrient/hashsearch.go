@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/dat"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+	"github.com/JohnDeved/myrient-cli/internal/history"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+)
+
+// hashFlags are the search flags that identify files by checksum or serial
+// instead of by name, mapped to their lookup kind.
+var hashFlags = []struct{ flag, kind string }{
+	{"crc", "crc"},
+	{"md5", "md5"},
+	{"sha1", "sha1"},
+	{"serial", "serial"},
+}
+
+// hashMatch is a file identified by checksum or serial.
+type hashMatch struct {
+	Name       string `json:"name"`
+	Path       string `json:"path,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Size       string `json:"size,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	// Indexed is false for DAT games without an indexed file and for
+	// downloads of files no longer in the index.
+	Indexed bool `json:"indexed"`
+	// Source is "dat" for a ROM in an imported DAT and "download" for a
+	// completed download.
+	Source string `json:"source"`
+	DAT    string `json:"dat,omitempty"`
+	Game   string `json:"game,omitempty"`
+	ROM    string `json:"rom,omitempty"`
+	Serial string `json:"serial,omitempty"`
+}
+
+// hashQuery returns the lookup kind and normalized value of the checksum or
+// serial flag given to search, if any. Only one such flag may be given, and
+// not together with a query.
+func hashQuery(cmd *cobra.Command, args []string) (kind, value string, ok bool, err error) {
+	var given []string
+	for _, f := range hashFlags {
+		v, _ := cmd.Flags().GetString(f.flag)
+		if v == "" {
+			continue
+		}
+		given = append(given, "--"+f.flag)
+		kind, value = f.kind, v
+	}
+	switch {
+	case len(given) == 0:
+		return "", "", false, nil
+	case len(given) > 1:
+		return "", "", false, fmt.Errorf("%s cannot be combined; give one checksum or serial", strings.Join(given, " and "))
+	case len(args) > 0:
+		return "", "", false, fmt.Errorf("%s cannot be combined with a query", given[0])
+	}
+	if kind == "serial" {
+		if dat.NormalizeSerial(value) == "" {
+			return "", "", false, fmt.Errorf("invalid serial %q", value)
+		}
+		return kind, value, true, nil
+	}
+	if value, err = dat.NormalizeHash(kind, value); err != nil {
+		return "", "", false, err
+	}
+	return kind, value, true, nil
+}
+
+// collectionOf returns the collection of a remote path, e.g. "No-Intro".
+func collectionOf(remotePath string) string {
+	collection, _, _ := strings.Cut(remotePath, "/")
+	return collection
+}
+
+// findByHash looks value up in the imported DATs, if any, and, for
+// checksums, in the completed downloads, and returns the files it identifies.
+func findByHash(db *index.DB, dats *dat.DB, baseURL, kind, value string) ([]hashMatch, error) {
+	var roms []dat.ROMMatch
+	if dats != nil {
+		var err error
+		if roms, err = dats.FindROMs(kind, value); err != nil {
+			return nil, fmt.Errorf("looking up DATs: %w", err)
+		}
+	}
+	var matches []hashMatch
+	seen := map[string]bool{}
+	for _, m := range roms {
+		found := hashMatch{Name: m.Game, Source: "dat", DAT: m.DAT, Game: m.Game, ROM: m.ROM.Name, Serial: m.Serial}
+		if found.Serial == "" {
+			found.Serial = m.ROM.Serial
+		}
+		files, err := db.GameFiles(m.Game)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 && !seen[m.DAT+"\x00"+m.Game] {
+			seen[m.DAT+"\x00"+m.Game] = true
+			matches = append(matches, found)
+		}
+		for _, f := range files {
+			if seen[f.URL] {
+				continue
+			}
+			seen[f.URL] = true
+			found.Name, found.Path, found.URL, found.Size = f.Name, f.Path, f.URL, f.Size
+			found.Collection, found.Indexed = collectionOf(f.Path), true
+			matches = append(matches, found)
+		}
+	}
+	if kind == "serial" {
+		return matches, nil
+	}
+
+	if _, err := os.Stat(config.HistoryDBPath()); err != nil {
+		return matches, nil
+	}
+	hdb, err := history.Open(config.HistoryDBPath())
+	if err != nil {
+		return nil, err
+	}
+	defer hdb.Close()
+	q := history.Query{Status: history.StatusCompleted}
+	switch kind {
+	case "crc":
+		q.CRC32 = value
+	case "md5":
+		q.MD5 = value
+	case "sha1":
+		q.SHA1 = value
+	}
+	entries, err := hdb.List(q)
+	if err != nil {
+		return nil, fmt.Errorf("looking up downloads: %w", err)
+	}
+	for _, e := range entries {
+		if seen[e.URL] {
+			continue
+		}
+		seen[e.URL] = true
+		remote := destpath.RemotePath(baseURL, e.URL)
+		found := hashMatch{Name: e.Name, Path: remote, URL: e.URL, Collection: collectionOf(remote), Source: "download"}
+		named, err := db.FilesNamed(path.Base(remote))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range named {
+			if f.Path == remote {
+				found.Size, found.Indexed = f.Size, true
+			}
+		}
+		matches = append(matches, found)
+	}
+	return matches, nil
+}
+
+func runHashSearch(cmd *cobra.Command, kind, value string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	db, err := index.OpenDB(config.DBPath())
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	var dats *dat.DB
+	if _, err := os.Stat(config.DATDBPath()); err == nil {
+		if dats, err = dat.Open(config.DATDBPath()); err != nil {
+			return err
+		}
+		defer dats.Close()
+	}
+
+	matches, err := findByHash(db, dats, cfg.BaseURL, kind, value)
+	if err != nil {
+		return err
+	}
+	collection, _ := cmd.Flags().GetString("collection")
+	limit, _ := cmd.Flags().GetInt("limit")
+	var results []hashMatch
+	for _, m := range matches {
+		if collection != "" && !strings.EqualFold(m.Collection, collection) {
+			continue
+		}
+		if limit > 0 && len(results) == limit {
+			break
+		}
+		results = append(results, m)
+	}
+
+	if jsonMode, _ := cmd.Flags().GetBool("json"); jsonMode {
+		if results == nil {
+			results = []hashMatch{}
+		}
+		out := struct {
+			Kind       string      `json:"kind"`
+			Value      string      `json:"value"`
+			Collection string      `json:"collection,omitempty"`
+			Count      int         `json:"count"`
+			Results    []hashMatch `json:"results"`
+		}{
+			Kind:       kind,
+			Value:      value,
+			Collection: collection,
+			Count:      len(results),
+			Results:    results,
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No files match %s %s.\n", strings.ToUpper(kind), value)
+		fmt.Println("Tip: Import DATs with 'myrient dat import' to identify files you have not downloaded.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tCOLLECTION\tSIZE\tSOURCE")
+	for _, r := range results {
+		source := "download"
+		if r.Source == "dat" {
+			source = r.DAT + ": " + r.ROM
+			if r.Serial != "" {
+				source += " [" + r.Serial + "]"
+			}
+		}
+		name := r.Name
+		if !r.Indexed {
+			name += " (not indexed)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, orDash(r.Collection), orDash(r.Size), source)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "\n%d results found.\n", len(results))
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestHashQuery(t *testing.T) {
+	for _, tc := range []struct {
+		flags       map[string]string
+		args        []string
+		kind, value string
+		ok, err     bool
+	}{
+		{flags: nil, args: []string{"tetris"}},
+		{flags: map[string]string{"crc": "0x3A1C8A3"}, kind: "crc", value: "03a1c8a3", ok: true},
+		{flags: map[string]string{"serial": "SLUS-00594"}, kind: "serial", value: "SLUS-00594", ok: true},
+		{flags: map[string]string{"md5": "abc"}, err: true},
+		{flags: map[string]string{"serial": "--"}, err: true},
+		{flags: map[string]string{"crc": "1a2b3c4d", "serial": "SLUS-00594"}, err: true},
+		{flags: map[string]string{"crc": "1a2b3c4d"}, args: []string{"tetris"}, err: true},
+	} {
+		cmd := &cobra.Command{}
+		for _, f := range hashFlags {
+			cmd.Flags().String(f.flag, "", "")
+		}
+		for name, v := range tc.flags {
+			cmd.Flags().Set(name, v)
+		}
+		kind, value, ok, err := hashQuery(cmd, tc.args)
+		if kind != tc.kind || value != tc.value || ok != tc.ok || (err != nil) != tc.err {
+			t.Errorf("%v %v: got %q %q %v %v", tc.flags, tc.args, kind, value, ok, err)
+		}
+	}
+}
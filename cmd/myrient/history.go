@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/history"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+)
+
+// recordHistory records every download dlm finishes in the history
+// database. The returned function stops recording and closes the database.
+func recordHistory(dlm *downloader.Manager, baseURL string, onErr func(error)) func() {
+	db, err := history.Open(config.HistoryDBPath())
+	if err != nil {
+		onErr(err)
+		return func() {}
+	}
+	dlm.SetFinishHook(db.Hook(baseURL, onErr))
+	return func() {
+		dlm.SetFinishHook(nil)
+		db.Close()
+	}
+}
+
+func warnHistory(err error) {
+	fmt.Fprintf(os.Stderr, "Warning: download history: %v\n", err)
+}
+
+// parseSince parses a look-back period such as "36h" or "7d".
+func parseSince(s string) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid --since %q", s)
+		}
+		return time.Now().AddDate(0, 0, -n), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid --since %q (e.g. 36h or 7d)", s)
+	}
+	return time.Now().Add(-d), nil
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	q := history.Query{Text: strings.Join(args, " ")}
+	q.Collection, _ = cmd.Flags().GetString("collection")
+	q.Limit, _ = cmd.Flags().GetInt("limit")
+	if failed, _ := cmd.Flags().GetBool("failed"); failed {
+		q.Status = history.StatusFailed
+	}
+	if completed, _ := cmd.Flags().GetBool("completed"); completed {
+		q.Status = history.StatusCompleted
+	}
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		t, err := parseSince(since)
+		if err != nil {
+			return err
+		}
+		q.Since = t
+	}
+
+	db, err := history.Open(config.HistoryDBPath())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	entries, err := db.List(q)
+	if err != nil {
+		return err
+	}
+
+	if jsonMode, _ := cmd.Flags().GetBool("json"); jsonMode {
+		if entries == nil {
+			entries = []history.Entry{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No downloads recorded.")
+		return nil
+	}
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FINISHED\tSTATUS\tSIZE\tSPEED\tNAME")
+	for _, e := range entries {
+		speed := "-"
+		if e.AvgSpeed > 0 {
+			speed = util.FormatBytes(int64(e.AvgSpeed)) + "/s"
+		}
+		name := e.Name
+		if e.Error != "" {
+			name += " (" + e.Error + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", e.FinishedAt.Format("2006-01-02 15:04"), e.Status,
+			util.FormatBytes(e.Size), speed, name)
+		if verbose {
+			fmt.Fprintf(tw, "\t\t\t\t  to: %s\n", e.DestPath)
+			fmt.Fprintf(tw, "\t\t\t\t  url: %s\n", e.URL)
+			if e.SHA1 != "" {
+				fmt.Fprintf(tw, "\t\t\t\t  crc32 %s  md5 %s  sha1 %s\n", e.CRC32, e.MD5, e.SHA1)
+			}
+		}
+	}
+	return tw.Flush()
+}
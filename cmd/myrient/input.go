@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+// Exit statuses of a batch download. A plain error exits with 1.
+const (
+	exitFailed  = 1
+	exitPartial = 2
+	// exitInterrupted follows the shell convention for SIGINT.
+	exitInterrupted = 130
+)
+
+// exitError makes the process exit with a specific status.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// inputLine is one entry of a batch input list.
+type inputLine struct {
+	N      int
+	Target string
+	// Dir overrides the output directory for this line; relative paths are
+	// taken relative to the output directory.
+	Dir string
+}
+
+// readInputLines parses a batch input list. Each line holds a file URL, a
+// remote file path or a query, optionally followed by a tab and an output
+// directory. Blank lines and lines starting with "#" are ignored.
+func readInputLines(r io.Reader) ([]inputLine, error) {
+	var lines []inputLine
+	sc := bufio.NewScanner(r)
+	n := 0
+	for sc.Scan() {
+		n++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		target, dir, _ := strings.Cut(text, "\t")
+		target, dir = strings.TrimSpace(target), strings.TrimSpace(dir)
+		if target == "" {
+			return nil, fmt.Errorf("line %d: missing URL, path or query", n)
+		}
+		lines = append(lines, inputLine{N: n, Target: target, Dir: dir})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("opening input list: %w", err)
+	}
+	return f, nil
+}
+
+// isFileURL reports whether s looks like an absolute http(s) URL.
+func isFileURL(s string) bool {
+	parsed, err := url.ParseRequestURI(s)
+	return err == nil && strings.HasPrefix(strings.ToLower(s), "http") && parsed.Host != ""
+}
+
+// remoteFileURL builds the URL of a file path relative to the archive root.
+func remoteFileURL(baseURL, remotePath string) string {
+	segs := strings.Split(strings.Trim(remotePath, "/"), "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.TrimRight(baseURL, "/") + "/" + strings.Join(segs, "/")
+}
+
+// lineOutcome ties the files resolved from one input line to their batch
+// results.
+type lineOutcome struct {
+	line inputLine
+	err  error
+	// reqs indexes the batch requests created for this line.
+	reqs []int
+}
+
+// resolveLine turns an input line into file URLs.
+func resolveLine(c *client.Client, resolver *queryResolver, all bool, line inputLine) ([]string, error) {
+	switch {
+	case isFileURL(line.Target):
+		return []string{line.Target}, nil
+	case strings.Contains(line.Target, "/"):
+		if strings.HasSuffix(line.Target, "/") {
+			return nil, fmt.Errorf("refusing to download directory path %s (use download --recursive)", line.Target)
+		}
+		return []string{remoteFileURL(c.BaseURL(), line.Target)}, nil
+	}
+	matches, err := resolver.matches(line.Target)
+	if err != nil {
+		return nil, err
+	}
+	if !all {
+		matches = matches[:1]
+	}
+	urls := make([]string, 0, len(matches))
+	for _, m := range matches {
+		urls = append(urls, m.URL)
+	}
+	return urls, nil
+}
+
+func runInputDownload(cmd *cobra.Command, c *client.Client, cfg *config.Config, outDir string, tmpl *destpath.Template) error {
+	name, _ := cmd.Flags().GetString("input")
+	in, err := openInput(name)
+	if err != nil {
+		return err
+	}
+	lines, err := readInputLines(in)
+	in.Close()
+	if err != nil {
+		return fmt.Errorf("reading input list: %w", err)
+	}
+	if len(lines) == 0 {
+		return errors.New("input list is empty")
+	}
+	// Failures from here on are reported per line, not as usage errors.
+	cmd.SilenceUsage = true
+
+	allMatches, _ := cmd.Flags().GetBool("all")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	resolver := newQueryResolver(cmd, c)
+
+	fmt.Fprintf(os.Stderr, "Resolving %d line(s)...\n", len(lines))
+	outcomes := make([]lineOutcome, 0, len(lines))
+	var reqs []downloader.Request
+	for _, line := range lines {
+		o := lineOutcome{line: line}
+		dir := outDir
+		if line.Dir != "" {
+			dir = line.Dir
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(outDir, dir)
+			}
+		}
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			o.err = fmt.Errorf("resolving output directory: %w", err)
+			outcomes = append(outcomes, o)
+			continue
+		}
+
+		urls, err := resolveLine(c, resolver, allMatches, line)
+		if err != nil {
+			if dryRun {
+				fmt.Printf("%d\tERROR\t%v\n", line.N, err)
+			}
+			o.err = err
+			outcomes = append(outcomes, o)
+			continue
+		}
+		for _, fileURL := range urls {
+			if dryRun {
+				fmt.Printf("%d\t%s\t%s\n", line.N, fileURL, destFor(c, cfg, absDir, tmpl, fileURL))
+				continue
+			}
+			req, err := fileRequest(c, tmpl, fileURL)
+			if err != nil {
+				o.err = err
+				break
+			}
+			req.Dir = absDir
+			o.reqs = append(o.reqs, len(reqs))
+			reqs = append(reqs, req)
+		}
+		outcomes = append(outcomes, o)
+	}
+	if dryRun {
+		return nil
+	}
+
+	var results []batchResult
+	if len(reqs) > 0 {
+		if dc := daemonFromFlags(cmd); dc != nil {
+			results, err = submitToDaemon(cmd, dc, cfg, outDir, reqs)
+			if err != nil || results == nil {
+				return err
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "Downloading %d file(s)\n", len(reqs))
+			results = downloadBatch(c, cfg, outDir, reqs)
+		}
+	}
+	// Keep stdout parseable when it carries JSON progress events.
+	report := io.Writer(os.Stdout)
+	if cfg.Progress == progressJSON {
+		report = os.Stderr
+	}
+	return inputReport(report, outcomes, results)
+}
+
+// inputReport prints one row per resolved file (or per failed line) to w and
+// returns an error whose exit status reflects whether some or all lines
+// failed.
+func inputReport(w io.Writer, outcomes []lineOutcome, results []batchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tRESULT\tINPUT\tDETAIL")
+	failedLines := 0
+	for _, o := range outcomes {
+		failed := o.err != nil
+		for _, i := range o.reqs {
+			r := results[i]
+			status, detail := "ok", filepath.Join(r.Req.Dir, filepath.FromSlash(r.Req.Subdir), r.Req.Name)
+			switch {
+			case r.Err != nil:
+				status, detail = "FAILED", r.Err.Error()
+				failed = true
+			case r.Present:
+				status = "present"
+			case r.Skipped != "":
+				status, detail = "skipped", r.Skipped
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", o.line.N, status, o.line.Target, detail)
+		}
+		if o.err != nil {
+			fmt.Fprintf(tw, "%d\tFAILED\t%s\t%v\n", o.line.N, o.line.Target, o.err)
+		}
+		if failed {
+			failedLines++
+		}
+	}
+	tw.Flush()
+
+	switch {
+	case interrupted(results):
+		return &exitError{exitInterrupted, fmt.Errorf("%w; run 'myrient download --resume' to continue", errInterrupted)}
+	case failedLines == 0:
+		return nil
+	case failedLines == len(outcomes):
+		return &exitError{exitFailed, fmt.Errorf("all %d line(s) failed", failedLines)}
+	default:
+		return &exitError{exitPartial, fmt.Errorf("%d of %d line(s) failed", failedLines, len(outcomes))}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+func TestReadInputLines(t *testing.T) {
+	in := "# wanted\n" +
+		"https://example.org/files/a.zip\n" +
+		"\n" +
+		"No-Intro/Nintendo - Game Boy/Tetris (World).zip\tgb\n" +
+		"  chrono trigger  \t /abs/dir \n"
+	lines, err := readInputLines(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []inputLine{
+		{N: 2, Target: "https://example.org/files/a.zip"},
+		{N: 4, Target: "No-Intro/Nintendo - Game Boy/Tetris (World).zip", Dir: "gb"},
+		{N: 5, Target: "chrono trigger", Dir: "/abs/dir"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(lines), len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: got %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestRemoteFileURL(t *testing.T) {
+	got := remoteFileURL("https://myrient.erista.me/files/", "/No-Intro/Nintendo - Game Boy/Tetris (World).zip")
+	want := "https://myrient.erista.me/files/No-Intro/Nintendo%20-%20Game%20Boy/Tetris%20%28World%29.zip"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestInputReport_SkippedIsNotFailure(t *testing.T) {
+	results := []batchResult{
+		{Req: downloader.Request{Name: "a.zip", Dir: "/dl"}},
+		{Req: downloader.Request{Name: "b.zip", Dir: "/dl"}, Skipped: "already queued as #3"},
+	}
+	outcomes := []lineOutcome{
+		{line: inputLine{N: 1, Target: "a.zip"}, reqs: []int{0}},
+		{line: inputLine{N: 2, Target: "b.zip"}, reqs: []int{1}},
+	}
+	var out strings.Builder
+	if err := inputReport(&out, outcomes, results); err != nil {
+		t.Fatalf("inputReport: %v", err)
+	}
+	if !strings.Contains(out.String(), "skipped  b.zip  already queued as #3") {
+		t.Errorf("report:\n%s", out.String())
+	}
+	if err := batchError(results); err != nil {
+		t.Errorf("batchError: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/dat"
+	"github.com/JohnDeved/myrient-cli/internal/history"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+	"github.com/JohnDeved/myrient-cli/internal/library"
+)
+
+func runLibraryScan(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	dirs := args
+	if len(dirs) == 0 {
+		dirs = []string{cfg.DownloadDir}
+	}
+	hash, _ := cmd.Flags().GetBool("hash")
+	jsonMode, _ := cmd.Flags().GetBool("json")
+
+	lib, err := library.Open(config.LibraryDBPath())
+	if err != nil {
+		return err
+	}
+	defer lib.Close()
+	scanner := library.NewScanner(lib, cfg.BaseURL)
+	scanner.SetHash(hash)
+	if _, err := os.Stat(config.DBPath()); err == nil {
+		db, err := index.OpenDB(config.DBPath())
+		if err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer db.Close()
+		scanner.SetIndex(db)
+		if _, err := os.Stat(config.DATDBPath()); err == nil {
+			dats, err := dat.Open(config.DATDBPath())
+			if err != nil {
+				return err
+			}
+			defer dats.Close()
+			scanner.SetDATs(dats)
+		}
+	} else if !hash {
+		fmt.Fprintln(os.Stderr, "Warning: no local index; only --hash can match files (run \"myrient index\" first)")
+	}
+	if _, err := os.Stat(config.HistoryDBPath()); err == nil {
+		h, err := history.Open(config.HistoryDBPath())
+		if err != nil {
+			return err
+		}
+		defer h.Close()
+		scanner.SetHistory(h)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+	var sums []library.Summary
+	for _, dir := range dirs {
+		if !jsonMode {
+			fmt.Fprintf(os.Stderr, "Scanning %s...\n", dir)
+		}
+		sum, err := scanner.Scan(ctx, dir)
+		if err != nil {
+			return fmt.Errorf("scanning %s: %w", dir, err)
+		}
+		sums = append(sums, sum)
+		if !jsonMode {
+			printScanSummary(sum)
+		}
+	}
+	if jsonMode {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sums)
+	}
+	return nil
+}
+
+func printScanSummary(sum library.Summary) {
+	matched := 0
+	for _, n := range sum.Matched {
+		matched += n
+	}
+	fmt.Printf("  %d file(s): %d matched, %d unknown", sum.Files, matched, sum.Unmatched)
+	var methods []string
+	for _, method := range []string{library.MatchHash, library.MatchSize, library.MatchName, library.MatchExtracted} {
+		if n := sum.Matched[method]; n > 0 {
+			methods = append(methods, fmt.Sprintf("%d by %s", n, method))
+		}
+	}
+	if len(methods) > 0 {
+		fmt.Printf(" (%s)", strings.Join(methods, ", "))
+	}
+	fmt.Println()
+	if sum.Hashed > 0 {
+		fmt.Printf("  %d file(s) hashed\n", sum.Hashed)
+	}
+	if sum.Forgotten > 0 {
+		fmt.Printf("  %d file(s) no longer present were forgotten\n", sum.Forgotten)
+	}
+}
+
+// missingFile is a remote file missing from the library, as printed by
+// "library missing --json".
+type missingFile struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	URL  string `json:"url"`
+	Size string `json:"size"`
+	Date string `json:"date"`
+}
+
+func runLibraryMissing(cmd *cobra.Command, args []string) error {
+	dirPath, _ := cmd.Flags().GetString("path")
+	dirPath = normalizeListPath(dirPath)
+	if dirPath == "" {
+		return fmt.Errorf("--path is required (e.g. \"No-Intro/Nintendo - Game Boy\")")
+	}
+	urlsOnly, _ := cmd.Flags().GetBool("urls")
+	jsonMode, _ := cmd.Flags().GetBool("json")
+
+	if _, err := os.Stat(config.DBPath()); err != nil {
+		return fmt.Errorf("no local index; run \"myrient index\" first")
+	}
+	db, err := index.OpenDB(config.DBPath())
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	files, err := db.FilesUnder(dirPath)
+	if err != nil {
+		return fmt.Errorf("listing %s: %w", dirPath, err)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no indexed files under /%s", dirPath)
+	}
+
+	lib, err := library.Open(config.LibraryDBPath())
+	if err != nil {
+		return err
+	}
+	defer lib.Close()
+	owned, err := lib.Owned(dirPath)
+	if err != nil {
+		return err
+	}
+
+	var missing []index.FileRecord
+	for _, f := range files {
+		if _, ok := owned[f.Path]; !ok {
+			missing = append(missing, f)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Have %d of %d file(s) under /%s; %d missing\n", len(files)-len(missing), len(files), dirPath, len(missing))
+
+	switch {
+	case jsonMode:
+		out := make([]missingFile, len(missing))
+		for i, f := range missing {
+			out[i] = missingFile{Name: f.Name, Path: f.Path, URL: f.URL, Size: f.Size, Date: f.Date}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	case urlsOnly:
+		for _, f := range missing {
+			fmt.Println(f.URL)
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SIZE\tPATH")
+	for _, f := range missing {
+		fmt.Fprintf(tw, "%s\t%s\n", f.Size, f.Path)
+	}
+	return tw.Flush()
+}
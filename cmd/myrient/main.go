@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"os/signal"
 	"path"
+	"path/filepath"
 	"runtime/debug"
 	"sort"
 	"strconv"
@@ -18,8 +20,10 @@ import (
 
 	"github.com/JohnDeved/myrient-cli/internal/client"
 	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
 	"github.com/JohnDeved/myrient-cli/internal/downloader"
 	"github.com/JohnDeved/myrient-cli/internal/index"
+	"github.com/JohnDeved/myrient-cli/internal/mirror"
 	"github.com/JohnDeved/myrient-cli/internal/tui"
 	"github.com/JohnDeved/myrient-cli/internal/util"
 )
@@ -79,29 +83,106 @@ from myrient.erista.me directly in your terminal.`,
 	searchCmd := &cobra.Command{
 		Use:   "search <query>",
 		Short: "Search the local index for games/ROMs",
-		Args:  cobra.MinimumNArgs(1),
-		RunE:  runSearch,
+		Long: `Search the local index for games/ROMs by name.
+
+With --crc, --md5, --sha1 or --serial instead of a query, identify a file
+by checksum or product serial: the value is looked up in the DATs imported
+with "myrient dat import" and, for checksums, in completed downloads, and
+the matching indexed files are listed across collections with the DAT
+entry they match.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if _, _, ok, err := hashQuery(cmd, args); err != nil {
+				return err
+			} else if ok {
+				return nil
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		RunE: runSearch,
 	}
 	searchCmd.Flags().String("collection", "", "Filter by collection name")
 	searchCmd.Flags().Int("limit", 50, "Maximum number of results")
 	searchCmd.Flags().Bool("json", false, "Output JSON")
+	searchCmd.Flags().String("crc", "", "Find files by CRC32 checksum (e.g. 1a2b3c4d)")
+	searchCmd.Flags().String("md5", "", "Find files by MD5 checksum")
+	searchCmd.Flags().String("sha1", "", "Find files by SHA-1 checksum")
+	searchCmd.Flags().String("serial", "", "Find files by product serial (e.g. SLUS-00594)")
 
 	// Download command
 	downloadCmd := &cobra.Command{
-		Use:   "download <url-or-query>",
+		Use:   "download [url-or-query]",
 		Short: "Download a file from Myrient by URL or query",
-		Args:  cobra.ExactArgs(1),
-		RunE:  runDownload,
+		Long: `Download a file from Myrient by URL or query.
+
+Files are saved below the output directory according to --template (or the
+path_template config setting); run "myrient template" to list the tokens.
+
+When a query matches several files that rank close together and the command
+runs on a terminal, the top matches are listed with their region, language,
+tags and size and you pick one or more by number; scripts (or
+--interactive=false) get the best match as before.
+
+With --recursive the argument is a directory path or URL and every file below
+it is downloaded.
+Patterns for --include/--exclude are globs matched against file names (or the
+relative path when they contain "/"); prefix a pattern with "re:" to use a
+regular expression against the relative path.
+
+With --input the argument is omitted and targets are read from a file (or
+stdin for "-"), one per line: a file URL, a remote file path such as
+"No-Intro/Nintendo - Game Boy/Tetris (World).zip", or a query resolved with
+the --search-path options. A tab after the target starts an output directory
+for that line, relative to --output unless absolute. Blank lines and lines
+starting with "#" are ignored. All files download in one queue and a report
+with one row per file is printed at the end; the exit status is 0 when every
+line succeeded, 2 when some failed and 1 when all failed.
+
+Ctrl+C pauses running downloads, keeping their partial files, and saves the
+unfinished part of the batch; "myrient download --resume" continues it.
+Press Ctrl+C again to quit without waiting.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			input, _ := cmd.Flags().GetString("input")
+			resume, _ := cmd.Flags().GetBool("resume")
+			if input != "" || resume {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: runDownload,
 	}
 	downloadCmd.Flags().StringP("output", "o", "", "Output directory for this download")
+	downloadCmd.Flags().StringP("input", "i", "", "Read URLs, remote paths or queries from a file, one per line (- for stdin)")
 	downloadCmd.Flags().String("search-path", "No-Intro/Nintendo - Nintendo DS (Decrypted)/", "Directory path to search when argument is a query")
 	downloadCmd.Flags().String("prefer-region", "", "Preferred region when resolving a query (eu, usa, japan)")
 	downloadCmd.Flags().String("prefer-language", "", "Preferred languages in order (comma-separated, e.g. de,en)")
 	downloadCmd.Flags().Bool("exact", false, "Require exact phrase match when resolving a query")
 	downloadCmd.Flags().Bool("include-nonretail", false, "Include demo/beta/kiosk variants in query matches")
 	downloadCmd.Flags().Bool("all", false, "When using a query, download all matching files")
+	downloadCmd.Flags().Bool("interactive", false, "Pick among several close query matches by number (default on a terminal; --interactive=false picks the best match)")
+	downloadCmd.Flags().Int("choices", 10, "Number of close query matches to offer when picking interactively")
 	downloadCmd.Flags().Int("match-limit", 0, "Limit matched query results before downloading (0 = unlimited)")
 	downloadCmd.Flags().Bool("dry-run", false, "Resolve query and print selected match without downloading")
+	downloadCmd.Flags().String("template", "", "Destination path template (default from config, e.g. {collection}/{system}/{name})")
+	downloadCmd.Flags().Bool("skip-existing", false, "Skip files whose destination exists with the expected size (default)")
+	downloadCmd.Flags().Bool("verify-existing", false, "Skip existing files only when their hash (or size, if no hash is known) matches")
+	downloadCmd.Flags().Bool("overwrite", false, "Download and replace files that already exist")
+	downloadCmd.MarkFlagsMutuallyExclusive("skip-existing", "verify-existing", "overwrite")
+	downloadCmd.Flags().Bool("set", false, "Download every disc of multi-disc sets and write an .m3u playlist once the set is complete")
+	downloadCmd.Flags().Bool("set-folder", false, "With --set, put each set in a folder named after the game (default from config)")
+	downloadCmd.Flags().Bool("extract", false, "Extract ZIP archives into a folder named after them while they download, without keeping the archive")
+	downloadCmd.Flags().String("patch", "", "Apply an IPS, UPS or BPS patch (file, URL or ZIP containing one) to the downloaded ROM; downloads in this process")
+	downloadCmd.Flags().String("fs-profile", "", "Adapt file names to the destination filesystem: posix, windows, fat32 or exfat (default from config)")
+	downloadCmd.Flags().Bool("no-daemon", false, "Download in this process even if a daemon is running")
+	downloadCmd.Flags().Bool("detach", false, "When a daemon is running, submit and return without following progress")
+	downloadCmd.Flags().BoolP("recursive", "r", false, "Download every file below a remote directory")
+	downloadCmd.Flags().StringArray("include", nil, "With --recursive, only download files matching this glob or re:regex (repeatable)")
+	downloadCmd.Flags().StringArray("exclude", nil, "With --recursive, skip files matching this glob or re:regex (repeatable)")
+	downloadCmd.Flags().String("min-size", "", "With --recursive, skip files smaller than this (e.g. 10M)")
+	downloadCmd.Flags().String("max-size", "", "With --recursive, skip files larger than this (e.g. 4G)")
+	downloadCmd.Flags().Bool("use-index", false, "With --recursive, walk the local index instead of listing live")
+	downloadCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt for recursive downloads")
+	downloadCmd.Flags().Bool("resume", false, "Continue the downloads paused by an earlier Ctrl+C")
+	downloadCmd.Flags().String("progress", "auto", "Progress output: auto, bar, plain or json (NDJSON events on stdout)")
 
 	findCmd := &cobra.Command{
 		Use:   "find <query>",
@@ -124,9 +205,345 @@ from myrient.erista.me directly in your terminal.`,
 	}
 	statsCmd.Flags().Bool("json", false, "Output JSON")
 
-	rootCmd.AddCommand(browseCmd, listCmd, indexCmd, searchCmd, downloadCmd, findCmd, statsCmd)
+	templateCmd := &cobra.Command{
+		Use:   "template [path-or-url...]",
+		Short: "Preview destination paths, or list template tokens",
+		Long: `Preview where files would be saved using the destination path template.
+
+Without arguments, prints the configured template and the available tokens.
+Tokens are written as {token} or {token|fallback}; path segments that resolve
+to nothing are dropped.`,
+		RunE: runTemplate,
+	}
+	templateCmd.Flags().StringP("output", "o", "", "Output directory to resolve against")
+	templateCmd.Flags().String("template", "", "Template to preview instead of the configured one")
+	templateCmd.Flags().String("fs-profile", "", "Filesystem profile to apply: posix, windows, fat32 or exfat (default from config)")
+
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run the download manager in the background",
+		Long: `Run the download manager as a long-lived process with a control socket.
+
+While a daemon is running, "myrient download", "myrient queue" and the TUI
+submit downloads to it instead of downloading themselves, so closing the
+terminal does not stop transfers.`,
+		Args: cobra.NoArgs,
+		RunE: runDaemon,
+	}
+	daemonCmd.Flags().Duration("index-every", 0, "Refresh stale parts of the index on this interval (e.g. 24h; 0 = never)")
+	daemonCmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show whether a daemon is running",
+		Args:  cobra.NoArgs,
+		RunE:  runDaemonStatus,
+	}, &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the running daemon",
+		Args:  cobra.NoArgs,
+		RunE:  runDaemonStop,
+	})
+
+	queueCmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Inspect and control the daemon's download queue",
+	}
+	queueListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List queued, active and finished downloads",
+		Args:  cobra.NoArgs,
+		RunE:  runQueueList,
+	}
+	queueListCmd.Flags().Bool("json", false, "Output JSON")
+	queueAddCmd := &cobra.Command{
+		Use:   "add <file-url>...",
+		Short: "Submit file URLs to the daemon",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runQueueAdd,
+	}
+	queueAddCmd.Flags().StringP("output", "o", "", "Output directory for these downloads")
+	queueAddCmd.Flags().String("template", "", "Destination path template (default from config)")
+	queueCmd.AddCommand(queueListCmd, queueAddCmd)
+	for _, op := range []struct{ name, short string }{
+		{"pause", "Pause downloads by ID"},
+		{"resume", "Resume paused downloads by ID"},
+		{"cancel", "Cancel downloads by ID"},
+		{"retry", "Retry failed or held downloads by ID"},
+	} {
+		opCmd := &cobra.Command{
+			Use:   op.name + " <id>...",
+			Short: op.short,
+			RunE:  runQueueOp,
+		}
+		if op.name == "cancel" {
+			opCmd.Flags().Bool("all", false, "Cancel every pending download")
+		}
+		queueCmd.AddCommand(opCmd)
+	}
+	queueCmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove finished downloads from the queue",
+		Args:  cobra.NoArgs,
+		RunE:  runQueueOp,
+	})
+
+	// Export command
+	exportCmd := &cobra.Command{
+		Use:   "export <query-or-dir>",
+		Short: "Write a download list for aria2, wget, curl or Metalink",
+		Long: `Write a download list for an external downloader.
+
+The files come from the local index (--from search, the default), a live
+query match (--from find) or every file below a remote directory
+(--from dir). Destinations follow --template below --output.
+
+Formats: aria2 (aria2c -i input file), wget and curl (shell scripts) and
+metalink (Metalink 4). Sizes are only included with --probe-sizes, since
+listings round them; known hashes are included where the format allows.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runExport,
+	}
+	exportCmd.Flags().String("format", "aria2", "Export format: aria2, wget, curl or metalink")
+	exportCmd.Flags().String("from", "search", "Where files come from: search (local index), find (live query) or dir (remote directory)")
+	exportCmd.Flags().StringP("output-file", "O", "", "Write the export to this file instead of stdout")
+	exportCmd.Flags().StringP("output", "o", "", "Download directory written into the export (default from config)")
+	exportCmd.Flags().String("template", "", "Destination path template (default from config)")
+	exportCmd.Flags().Int("limit", 500, "Maximum number of search or find matches (0 = unlimited for find)")
+	exportCmd.Flags().String("collection", "", "With --from search, filter by collection name")
+	exportCmd.Flags().String("search-path", "No-Intro/Nintendo - Nintendo DS (Decrypted)/", "With --from find, directory path to search")
+	exportCmd.Flags().String("prefer-region", "", "With --from find, preferred region (eu, usa, japan)")
+	exportCmd.Flags().String("prefer-language", "", "With --from find, preferred languages in order (e.g. de,en)")
+	exportCmd.Flags().Bool("exact", false, "With --from find, require exact phrase match")
+	exportCmd.Flags().Bool("include-nonretail", false, "With --from find, include demo/beta/kiosk variants")
+	exportCmd.Flags().StringArray("include", nil, "With --from dir, only export files matching this glob or re:regex (repeatable)")
+	exportCmd.Flags().StringArray("exclude", nil, "With --from dir, skip files matching this glob or re:regex (repeatable)")
+	exportCmd.Flags().String("min-size", "", "With --from dir, skip files smaller than this (e.g. 10M)")
+	exportCmd.Flags().String("max-size", "", "With --from dir, skip files larger than this (e.g. 4G)")
+	exportCmd.Flags().Bool("use-index", false, "With --from dir, walk the local index instead of listing live")
+	exportCmd.Flags().Bool("probe-sizes", false, "Ask the server for each file's exact size")
+
+	// History command
+	historyCmd := &cobra.Command{
+		Use:   "history [text]",
+		Short: "Show finished downloads, optionally filtered by name, URL or path",
+		RunE:  runHistory,
+	}
+	historyCmd.Flags().Bool("failed", false, "Only show failed downloads")
+	historyCmd.Flags().Bool("completed", false, "Only show completed downloads")
+	historyCmd.MarkFlagsMutuallyExclusive("failed", "completed")
+	historyCmd.Flags().String("collection", "", "Only show downloads from this collection (e.g. No-Intro)")
+	historyCmd.Flags().String("since", "", "Only show downloads finished within this period (e.g. 36h or 7d)")
+	historyCmd.Flags().Int("limit", 50, "Maximum number of entries (0 = unlimited)")
+	historyCmd.Flags().BoolP("verbose", "V", false, "Also show destination, URL and checksums")
+	historyCmd.Flags().Bool("json", false, "Output JSON")
+
+	// Sync command
+	syncCmd := &cobra.Command{
+		Use:   "sync <remote-dir> <local-dir>",
+		Short: "Mirror a remote directory into a local folder",
+		Long: `Mirror a remote directory into a local folder.
+
+The remote directory is listed recursively and compared with the local
+folder by relative path, size and date. New files and files that changed
+upstream are downloaded into the same layout; everything else is left
+alone, so sync can be run repeatedly. Local files no longer present
+upstream are kept unless --delete or --quarantine is given. Quarantined
+files are moved to ` + mirror.QuarantineDir + `/<timestamp>/ inside the local folder.`,
+		Args: cobra.ExactArgs(2),
+		RunE: runSync,
+	}
+	syncCmd.Flags().Bool("dry-run", false, "Print the full plan without changing anything")
+	syncCmd.Flags().Bool("delete", false, "Delete local files that were removed upstream")
+	syncCmd.Flags().Bool("quarantine", false, "Move local files that were removed upstream into a quarantine folder")
+	syncCmd.MarkFlagsMutuallyExclusive("delete", "quarantine")
+	syncCmd.Flags().StringArray("include", nil, "Only sync files matching this glob or re:regex (repeatable)")
+	syncCmd.Flags().StringArray("exclude", nil, "Skip files matching this glob or re:regex (repeatable)")
+	syncCmd.Flags().String("min-size", "", "Skip files smaller than this (e.g. 10M)")
+	syncCmd.Flags().String("max-size", "", "Skip files larger than this (e.g. 4G)")
+	syncCmd.Flags().Bool("use-index", false, "Walk the local index instead of listing live")
+	syncCmd.Flags().String("fs-profile", "", "Adapt file names to the destination filesystem: posix, windows, fat32 or exfat (default from config)")
+	syncCmd.Flags().String("progress", "auto", "Progress output: auto, bar, plain or json (NDJSON events on stdout)")
+
+	// Parts command
+	partsCmd := &cobra.Command{
+		Use:   "parts [dir]",
+		Short: "List and clean up partial downloads left by earlier sessions",
+		Long: `List partial downloads (.part files) below the download directory (or dir)
+that no queue knows about, matched back to remote files through the local
+index.
+
+Matched partials can be resumed (--resume) or downloaded again from scratch
+(--requeue), through the daemon when one is running; --delete removes every
+listed partial. On a terminal without one of these flags you are asked what
+to do. The daemon and the TUI adopt matched partials as paused downloads when
+they start. Partials of a batch saved by Ctrl+C are left to
+"myrient download --resume".`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runParts,
+	}
+	partsCmd.Flags().Bool("resume", false, "Resume the matched partial downloads")
+	partsCmd.Flags().Bool("requeue", false, "Delete the matched partial files and download them again")
+	partsCmd.Flags().Bool("delete", false, "Delete every listed partial file")
+	partsCmd.MarkFlagsMutuallyExclusive("resume", "requeue", "delete")
+	partsCmd.Flags().BoolP("yes", "y", false, "Do not ask before deleting")
+	partsCmd.Flags().Bool("detach", false, "When a daemon is running, submit and return without following progress")
+	partsCmd.Flags().Bool("json", false, "Output JSON")
+	partsCmd.Flags().String("progress", "auto", "Progress output: auto, bar, plain or json (NDJSON events on stdout)")
+
+	patchCmd := &cobra.Command{
+		Use:   "patch <rom> <patch>",
+		Short: "Apply an IPS, UPS or BPS patch to a ROM",
+		Long: `Apply a soft patch, such as a translation from the T-En Collection, to a
+base ROM and write the patched ROM next to it, named after the patch with the
+ROM's extension.
+
+The ROM may be a ZIP archive as downloaded from Myrient; the largest file in
+it is patched. The patch may be a local file or a URL, and may itself be
+inside a ZIP archive. UPS and BPS patches record the CRC32 of the ROM they
+were made for and of the result; both are checked. IPS patches carry no
+checksums. xdelta patches are not supported.
+
+"myrient download --patch" downloads the base ROM and patches it in one go.`,
+		Args: cobra.ExactArgs(2),
+		RunE: runPatch,
+	}
+	patchCmd.Flags().StringP("output", "o", "", "Path of the patched ROM (default: next to the ROM, named after the patch)")
+	patchCmd.Flags().Bool("force", false, "Replace the output file if it exists")
+
+	resolveCmd := &cobra.Command{
+		Use:   "resolve <wishlist>",
+		Short: "Match a wishlist of game titles to files",
+		Long: `Match every title of a wishlist to the best file in a system's directory,
+using the same ranking as "download <query>".
+
+The wishlist is a CSV or TSV file (by extension) whose header names a
+"title" (or "name"/"game") column and optionally a "system" column, a CSV
+without header (titles in the first column), or a plain text file with one
+title per line; "-" reads stdin. --system names the directory to search,
+e.g. "Nintendo - Game Boy Advance", found through the local index, or a
+path such as "No-Intro/Nintendo - Game Boy Advance"; a system column
+overrides it per row.
+
+Each title is reported with its best match, the confidence that the match
+is the wished title (0-100, by word overlap), and runners-up. Titles are
+flagged as "ambiguous" when a different title matches almost as well, "low"
+when the best match is below --min-confidence and "missing" when nothing
+matches. --write saves the matched URLs for "download --input" and
+--download downloads them; flagged titles are left out unless
+--accept-ambiguous is given. The exit status is 2 when some titles did not
+match and 1 when none did.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runResolve,
+	}
+	resolveCmd.Flags().String("system", "", "System directory name (looked up in the local index) or path to search")
+	resolveCmd.Flags().String("search-path", "", "Directory path to search when neither --system nor a system column is given")
+	resolveCmd.Flags().String("prefer-region", "", "Preferred region (eu, usa, japan)")
+	resolveCmd.Flags().String("prefer-language", "", "Preferred languages in order (comma-separated, e.g. de,en)")
+	resolveCmd.Flags().Bool("exact", false, "Require exact phrase match")
+	resolveCmd.Flags().Bool("include-nonretail", false, "Include demo/beta/kiosk variants")
+	resolveCmd.Flags().Bool("use-index", false, "Match against the local index instead of listing live")
+	resolveCmd.Flags().Int("runners-up", 2, "Number of runners-up to show per title")
+	resolveCmd.Flags().Int("min-confidence", 60, "Flag matches below this confidence (0-100) as low")
+	resolveCmd.Flags().Bool("accept-ambiguous", false, "Include ambiguous and low-confidence matches in --write and --download")
+	resolveCmd.Flags().String("write", "", "Write the matched URLs to this file, one per line")
+	resolveCmd.Flags().Bool("download", false, "Download the matched files (through the daemon when one is running)")
+	resolveCmd.Flags().StringP("output", "o", "", "With --download, output directory")
+	resolveCmd.Flags().String("template", "", "With --download, destination path template (default from config)")
+	resolveCmd.Flags().Bool("no-daemon", false, "With --download, download in this process even if a daemon is running")
+	resolveCmd.Flags().Bool("detach", false, "With --download, submit to the daemon and return without following progress")
+	resolveCmd.Flags().String("progress", "auto", "With --download, progress output: auto, bar, plain or json")
+	resolveCmd.Flags().Bool("json", false, "Output JSON")
+
+	libraryCmd := &cobra.Command{
+		Use:   "library",
+		Short: "Track which remote files you already have locally",
+		Long: `Track which remote files are already in your local ROM folders.
+
+"library scan" walks local folders and matches every file to a remote file:
+by checksum against downloads in the history and DATs imported with "myrient
+dat import" (with --hash), by name and size against the local index, or by name alone for files unpacked from a remote
+archive (e.g. "Game (USA).gba" for "Game (USA).zip"). Folders extracted by
+"download --extract" are matched as a whole. Scanning again updates the
+record and forgets files that are gone; checksums of unchanged files are
+kept.
+
+"library missing" then lists the indexed files below a remote directory that
+no scanned file matched, and the browser marks owned files with ✓.`,
+	}
+	libraryScanCmd := &cobra.Command{
+		Use:   "scan [dir]...",
+		Short: "Scan local folders and match their files to remote files",
+		RunE:  runLibraryScan,
+	}
+	libraryScanCmd.Flags().Bool("hash", false, "Checksum files and match them against the download history and imported DATs")
+	libraryScanCmd.Flags().Bool("json", false, "Output JSON")
+	libraryMissingCmd := &cobra.Command{
+		Use:   "missing",
+		Short: "List indexed files below a remote directory that you do not have",
+		Args:  cobra.NoArgs,
+		RunE:  runLibraryMissing,
+	}
+	libraryMissingCmd.Flags().String("path", "", "Remote directory path (e.g. \"No-Intro/Nintendo - Game Boy\")")
+	libraryMissingCmd.Flags().Bool("urls", false, "Print only URLs, e.g. for \"download --input\"")
+	libraryMissingCmd.Flags().Bool("json", false, "Output JSON")
+	libraryCmd.AddCommand(libraryScanCmd, libraryMissingCmd)
+
+	datCmd := &cobra.Command{
+		Use:   "dat",
+		Short: "Import Logiqx DATs and audit local folders against them",
+		Long: `Import Logiqx XML DATs, as published by No-Intro, Redump and TOSEC, and audit
+local folders against them.
+
+Imported games are linked to indexed files by name ("Game (USA)" to
+"Game (USA).zip"), so audits can point to downloads of missing games. DATs
+are stored in dats.db in the config directory, apart from the index, so
+"myrient index --clear-db" keeps them; importing a DAT again replaces it, and
+deleting dats.db removes all of them.`,
+	}
+	datImportCmd := &cobra.Command{
+		Use:   "import <file.dat|file.zip>...",
+		Short: "Import DAT files, replacing earlier imports of the same DAT",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  runDATImport,
+	}
+	datListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List imported DATs",
+		Args:  cobra.NoArgs,
+		RunE:  runDATList,
+	}
+	datListCmd.Flags().Bool("json", false, "Output JSON")
+	datAuditCmd := &cobra.Command{
+		Use:   "audit <local-dir>",
+		Short: "Report which games a local folder has, misses, or holds bad copies of",
+		Long: `Audit a local folder against the imported DATs by CRC32 and size.
+
+ZIP archives are audited by the CRC32s recorded for their members, without
+extracting them; other files are read in full. Each game is reported as
+"have" when all its ROMs were found and "miss" otherwise, with a download URL
+when the game is linked to an indexed file. Local files named like a ROM but
+with a different size or CRC32 are reported as "bad", all others as
+"unknown". Missing games are listed for the DATs given with --dat, or for
+every DAT the folder holds files from.`,
+		Args: cobra.ExactArgs(1),
+		RunE: runDATAudit,
+	}
+	datAuditCmd.Flags().StringArray("dat", nil, "Audit against this imported DAT, by name or unique part of it (repeatable; default all)")
+	datAuditCmd.Flags().Bool("all-missing", false, "List missing games of every DAT, not only of DATs the folder holds files from")
+	datAuditCmd.Flags().StringSlice("only", nil, "Only report these statuses: have, miss, bad, unknown (comma-separated)")
+	datAuditCmd.Flags().String("format", "text", "Report format: text, csv or json")
+	datAuditCmd.Flags().StringP("output-file", "O", "", "Write the report to this file instead of stdout")
+	datCmd.AddCommand(datImportCmd, datListCmd, datAuditCmd)
+
+	rootCmd.AddCommand(browseCmd, listCmd, indexCmd, searchCmd, downloadCmd, findCmd, statsCmd, templateCmd, daemonCmd, queueCmd, exportCmd, historyCmd, syncCmd, partsCmd, patchCmd, resolveCmd, libraryCmd, datCmd)
 
 	if err := rootCmd.Execute(); err != nil {
+		var ee *exitError
+		if errors.As(err, &ee) {
+			os.Exit(ee.code)
+		}
+		if errors.Is(err, errInterrupted) {
+			os.Exit(exitInterrupted)
+		}
 		os.Exit(1)
 	}
 }
@@ -183,9 +600,11 @@ func runTUI(cmd *cobra.Command, args []string) error {
 	noAltScreen, _ := cmd.Flags().GetBool("no-alt-screen")
 	noMouse, _ := cmd.Flags().GetBool("no-mouse")
 
+	saved := interruptedDests()
 	return tui.Run(c, db, cfg, startPath, tui.RunOptions{
 		AltScreen:   !noAltScreen,
 		MouseMotion: !noMouse,
+		KnownParts:  func(dest string) bool { return saved[dest] },
 	})
 }
 
@@ -342,6 +761,13 @@ func clearLocalIndexDB() error {
 }
 
 func runSearch(cmd *cobra.Command, args []string) error {
+	kind, value, ok, err := hashQuery(cmd, args)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return runHashSearch(cmd, kind, value)
+	}
 	query := strings.Join(args, " ")
 
 	db, err := index.OpenDB(config.DBPath())
@@ -426,48 +852,68 @@ func runDownload(cmd *cobra.Command, args []string) error {
 
 	c := client.New(cfg.BaseURL, cfg.RequestsPerSecond)
 
+	tmpl, err := pathTemplateFromFlags(cmd, cfg)
+	if err != nil {
+		return err
+	}
+	if err := applyExistingFlags(cmd, cfg); err != nil {
+		return err
+	}
+	if _, err := applyFSProfileFlag(cmd, cfg); err != nil {
+		return err
+	}
+	if extract, _ := cmd.Flags().GetBool("extract"); extract {
+		cfg.ExtractArchives = true
+	}
+	if err := applyProgressFlag(cmd, cfg); err != nil {
+		return err
+	}
+
+	patchArg, _ := cmd.Flags().GetString("patch")
+	if patchArg != "" {
+		for _, name := range []string{"resume", "input", "recursive", "set", "set-folder"} {
+			if cmd.Flags().Changed(name) {
+				return fmt.Errorf("--patch and --%s cannot be combined", name)
+			}
+		}
+	}
+
+	if resume, _ := cmd.Flags().GetBool("resume"); resume {
+		if input, _ := cmd.Flags().GetString("input"); input != "" {
+			return errors.New("--resume and --input cannot be combined")
+		}
+		return runResume(cmd, c, cfg)
+	}
+
+	if input, _ := cmd.Flags().GetString("input"); input != "" {
+		if recursive, _ := cmd.Flags().GetBool("recursive"); recursive {
+			return errors.New("--input and --recursive cannot be combined")
+		}
+		return runInputDownload(cmd, c, cfg, outDir, tmpl)
+	}
+
 	arg := strings.TrimSpace(args[0])
 	fileURLs := []string{}
 
-	isURL := false
-	if parsed, parseErr := url.ParseRequestURI(arg); parseErr == nil && strings.HasPrefix(strings.ToLower(arg), "http") && parsed.Host != "" {
-		isURL = true
+	if recursive, _ := cmd.Flags().GetBool("recursive"); recursive {
+		return runRecursiveDownload(cmd, c, cfg, outDir, tmpl, arg)
 	}
 
+	isURL := isFileURL(arg)
+
 	allMatches, _ := cmd.Flags().GetBool("all")
-	matchLimit, _ := cmd.Flags().GetInt("match-limit")
 	dryRun, _ := cmd.Flags().GetBool("dry-run")
-	exact, _ := cmd.Flags().GetBool("exact")
-	includeNonRetail, _ := cmd.Flags().GetBool("include-nonretail")
+	listings := map[string][]client.Entry{}
 
 	if !isURL {
-		searchPath, _ := cmd.Flags().GetString("search-path")
-		preferRegion, _ := cmd.Flags().GetString("prefer-region")
-		preferLanguageRaw, _ := cmd.Flags().GetString("prefer-language")
-		preferLanguages := parsePreferredLanguages(preferLanguageRaw)
-		entries, err := c.ListDirectory(context.Background(), normalizeListPath(searchPath))
+		resolver := newQueryResolver(cmd, c)
+		listings = resolver.listings
+		matches, err := resolver.matches(arg)
 		if err != nil {
-			return fmt.Errorf("listing search path %q: %w", searchPath, err)
-		}
-		matches := rankMatches(entries, arg, preferRegion, preferLanguages, exact)
-		if !includeNonRetail {
-			filtered := make([]client.Entry, 0, len(matches))
-			for _, m := range matches {
-				if isNonRetail(strings.ToLower(m.Name)) {
-					continue
-				}
-				filtered = append(filtered, m)
-			}
-			matches = filtered
-		}
-		if matchLimit > 0 && matchLimit < len(matches) {
-			matches = matches[:matchLimit]
-		}
-		if len(matches) == 0 {
-			return fmt.Errorf("no matches found for %q in /%s", arg, normalizeListPath(searchPath))
+			return err
 		}
 		fmt.Fprintf(os.Stderr, "Resolved query: %s\n", arg)
-		fmt.Fprintf(os.Stderr, "From: /%s\n", normalizeListPath(searchPath))
+		fmt.Fprintf(os.Stderr, "From: /%s\n", resolver.searchPath)
 		if allMatches {
 			fmt.Fprintf(os.Stderr, "Matched %d file(s)\n", len(matches))
 			for i, m := range matches {
@@ -478,24 +924,66 @@ func runDownload(cmd *cobra.Command, args []string) error {
 				fileURLs = append(fileURLs, m.URL)
 			}
 		} else {
-			picked := matches[0]
-			fmt.Fprintf(os.Stderr, "Picked: %s\n", picked.Name)
-			fmt.Fprintf(os.Stderr, "URL: %s\n", picked.URL)
-			fileURLs = append(fileURLs, picked.URL)
-		}
-
-		if dryRun {
-			return nil
+			picks, err := pickQueryMatches(cmd, resolver, arg)
+			if err != nil {
+				return err
+			}
+			for _, picked := range picks {
+				fmt.Fprintf(os.Stderr, "Picked: %s\n", picked.Name)
+				fmt.Fprintf(os.Stderr, "URL: %s\n", picked.URL)
+				fileURLs = append(fileURLs, picked.URL)
+			}
 		}
 	} else {
 		fileURLs = append(fileURLs, arg)
 	}
+
+	wholeSet, _ := cmd.Flags().GetBool("set")
+	if setFolder, _ := cmd.Flags().GetBool("set-folder"); wholeSet || setFolder {
+		return downloadDiscSets(cmd, c, cfg, outDir, tmpl, fileURLs, listings)
+	}
+
+	if patchArg != "" && len(fileURLs) != 1 {
+		return fmt.Errorf("--patch applies to a single file, but %d matched", len(fileURLs))
+	}
+
+	if dryRun {
+		for _, fileURL := range fileURLs {
+			fmt.Fprintf(os.Stderr, "Save to: %s\n", destFor(c, cfg, outDir, tmpl, fileURL))
+		}
+		return nil
+	}
+
+	if patchArg != "" {
+		return downloadAndPatch(cmd, c, cfg, outDir, tmpl, fileURLs[0], patchArg)
+	}
+
+	if dc := daemonFromFlags(cmd); dc != nil {
+		var reqs []downloader.Request
+		for _, fileURL := range fileURLs {
+			req, err := fileRequest(c, tmpl, fileURL)
+			if err != nil {
+				return err
+			}
+			reqs = append(reqs, req)
+		}
+		results, err := submitToDaemon(cmd, dc, cfg, outDir, reqs)
+		if err != nil || results == nil {
+			return err
+		}
+		return batchError(results)
+	}
+
+	cmd.SilenceUsage = true
 	failures := []string{}
 	for i, fileURL := range fileURLs {
 		if len(fileURLs) > 1 {
 			fmt.Fprintf(os.Stderr, "\n[%d/%d]\n", i+1, len(fileURLs))
 		}
-		if err := downloadOne(c, outDir, fileURL); err != nil {
+		if err := downloadOne(c, cfg, outDir, tmpl, fileURL); err != nil {
+			if errors.Is(err, errInterrupted) {
+				return saveUnstarted(c, cfg, outDir, tmpl, fileURLs[i+1:])
+			}
 			failures = append(failures, err.Error())
 		}
 	}
@@ -506,65 +994,162 @@ func runDownload(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func downloadOne(c *client.Client, outDir, fileURL string) error {
+// saveUnstarted adds files an interrupted run never got to to the saved
+// state, so --resume downloads them too.
+func saveUnstarted(c *client.Client, cfg *config.Config, outDir string, tmpl *destpath.Template, fileURLs []string) error {
+	if len(fileURLs) > 0 {
+		reqs := make([]downloader.Request, len(fileURLs))
+		for i, fileURL := range fileURLs {
+			subdir, name := tmpl.Resolve(destpath.RemotePath(c.BaseURL(), fileURL))
+			reqs[i] = downloader.Request{Name: name, URL: fileURL, Subdir: subdir}
+		}
+		if err := saveInterrupted(cfg, outDir, reqs); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Saved %d more file(s) that had not started\n", len(fileURLs))
+	}
+	return errInterrupted
+}
+
+// pathTemplateFromFlags returns the --template flag, falling back to the
+// configured path template.
+func pathTemplateFromFlags(cmd *cobra.Command, cfg *config.Config) (*destpath.Template, error) {
+	raw, _ := cmd.Flags().GetString("template")
+	if raw == "" {
+		raw = cfg.PathTemplate
+	}
+	tmpl, err := destpath.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// applyExistingFlags overrides the configured existing-file policy with
+// --skip-existing, --verify-existing or --overwrite.
+func applyExistingFlags(cmd *cobra.Command, cfg *config.Config) error {
+	for _, name := range []string{"skip-existing", "verify-existing", "overwrite"} {
+		if set, _ := cmd.Flags().GetBool(name); set {
+			cfg.ExistingFiles = strings.TrimSuffix(name, "-existing")
+		}
+	}
+	if _, err := downloader.ParseExistingPolicy(cfg.ExistingFiles); err != nil {
+		return fmt.Errorf("invalid existing_files setting: %w", err)
+	}
+	return nil
+}
+
+// applyFSProfileFlag overrides the configured filesystem profile with
+// --fs-profile and returns the parsed result.
+func applyFSProfileFlag(cmd *cobra.Command, cfg *config.Config) (downloader.FSProfile, error) {
+	if value, _ := cmd.Flags().GetString("fs-profile"); value != "" {
+		cfg.FSProfile = value
+	}
+	profile, err := downloader.ParseFSProfile(cfg.FSProfile)
+	if err != nil {
+		return profile, fmt.Errorf("invalid fs_profile setting: %w", err)
+	}
+	cfg.FSProfile = profile.String()
+	return profile, nil
+}
+
+// destFor returns the local path a file URL resolves to under outDir, with
+// the configured filesystem profile applied.
+func destFor(c *client.Client, cfg *config.Config, outDir string, tmpl *destpath.Template, fileURL string) string {
+	subdir, name := tmpl.Resolve(destpath.RemotePath(c.BaseURL(), fileURL))
+	profile, _ := downloader.ParseFSProfile(cfg.FSProfile)
+	return filepath.Join(outDir, filepath.FromSlash(profile.Subdir(subdir)), profile.Name(name))
+}
+
+// fileRequest validates a file URL, checks that the server serves it and
+// builds the download request with its destination resolved by tmpl.
+func fileRequest(c *client.Client, tmpl *destpath.Template, fileURL string) (downloader.Request, error) {
 	u, err := url.Parse(fileURL)
 	if err != nil || u.Scheme == "" || u.Host == "" {
-		return fmt.Errorf("invalid URL: %q", fileURL)
+		return downloader.Request{}, fmt.Errorf("invalid URL: %q", fileURL)
 	}
 	if strings.HasSuffix(u.Path, "/") {
-		return fmt.Errorf("refusing to download directory URL: %s (provide a file URL)", fileURL)
+		return downloader.Request{}, fmt.Errorf("refusing to download directory URL: %s (provide a file URL)", fileURL)
 	}
 	if path.Base(strings.TrimSuffix(u.Path, "/")) == "files" {
-		return fmt.Errorf("refusing to download directory URL: %s (provide a file URL)", fileURL)
+		return downloader.Request{}, fmt.Errorf("refusing to download directory URL: %s (provide a file URL)", fileURL)
 	}
 
 	preflightCtx, preflightCancel := context.WithTimeout(context.Background(), 20*time.Second)
-	body, _, _, err := c.DownloadFile(preflightCtx, fileURL, 0)
+	body, size, _, err := c.DownloadFile(preflightCtx, fileURL, 0)
 	preflightCancel()
 	if err != nil {
-		return fmt.Errorf("download preflight failed: %w", err)
+		return downloader.Request{}, fmt.Errorf("download preflight failed: %w", err)
 	}
 	body.Close()
 
-	parts := strings.Split(fileURL, "/")
-	name := parts[len(parts)-1]
-	if name == "" && len(parts) > 1 {
-		name = parts[len(parts)-2]
-	}
-	if decoded, err := url.PathUnescape(name); err == nil {
-		name = decoded
+	subdir, name := tmpl.Resolve(destpath.RemotePath(c.BaseURL(), fileURL))
+	return downloader.Request{Name: name, URL: fileURL, Subdir: subdir, Size: size}, nil
+}
+
+func downloadOne(c *client.Client, cfg *config.Config, outDir string, tmpl *destpath.Template, fileURL string) error {
+	req, err := fileRequest(c, tmpl, fileURL)
+	if err != nil {
+		return err
 	}
+	name := req.Name
 
 	fmt.Fprintf(os.Stderr, "Downloading: %s\n", name)
-	fmt.Fprintf(os.Stderr, "To: %s\n", outDir)
-
-	dlm := downloader.NewManager(c, outDir, 1)
-	item, created := dlm.Enqueue(name, fileURL, "")
-	if !created {
-		fmt.Fprintf(os.Stderr, "Already queued or downloaded: %s\n", name)
-		return nil
+	profile, _ := downloader.ParseFSProfile(cfg.FSProfile)
+	dest := filepath.Join(outDir, filepath.FromSlash(profile.Subdir(req.Subdir)), profile.Name(name))
+	if cfg.ExtractArchives && strings.EqualFold(filepath.Ext(dest), ".zip") {
+		dest = downloader.ExtractDir(dest) + string(filepath.Separator)
 	}
+	fmt.Fprintf(os.Stderr, "To: %s\n", dest)
 
-	ticker := time.NewTicker(250 * time.Millisecond)
-	defer ticker.Stop()
+	if err := downloadBatch(c, cfg, outDir, []downloader.Request{req})[0].Err; err != nil {
+		if errors.Is(err, errInterrupted) {
+			return err
+		}
+		if errors.Is(err, downloader.ErrInsufficientSpace) {
+			return fmt.Errorf("download refused: %s: %v", name, err)
+		}
+		return fmt.Errorf("download failed: %s: %v", name, err)
+	}
+	return nil
+}
 
-	for range ticker.C {
-		item.Mu.Lock()
-		status := item.Status
-		errVal := item.Error
-		item.Mu.Unlock()
+func runTemplate(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	tmpl, err := pathTemplateFromFlags(cmd, cfg)
+	if err != nil {
+		return err
+	}
+	profile, err := applyFSProfileFlag(cmd, cfg)
+	if err != nil {
+		return err
+	}
+	outDir, _ := cmd.Flags().GetString("output")
+	if outDir == "" {
+		outDir = cfg.DownloadDir
+	}
 
-		progress := item.Progress()
-		speed := item.Speed()
+	if len(args) == 0 {
+		fmt.Printf("Template: %s\nFilesystem profile: %s\n\nTokens:\n", tmpl, profile)
+		for _, t := range destpath.Tokens() {
+			fmt.Printf("  {%s}\t%s\n", t[0], t[1])
+		}
+		return nil
+	}
 
-		switch status {
-		case downloader.StatusCompleted:
-			fmt.Fprintf(os.Stderr, "\rDownloaded: %s (100%%)                    \n", name)
-			return nil
-		case downloader.StatusFailed:
-			return fmt.Errorf("download failed: %s: %v", name, errVal)
-		case downloader.StatusActive:
-			fmt.Fprintf(os.Stderr, "\r  %.1f%% (%s/s)    ", progress*100, util.FormatBytes(int64(speed)))
+	for _, arg := range args {
+		remote := strings.Trim(arg, "/")
+		if strings.HasPrefix(strings.ToLower(arg), "http") {
+			remote = destpath.RemotePath(cfg.BaseURL, arg)
+		}
+		subdir, name := tmpl.Resolve(remote)
+		stored := profile.Name(name)
+		fmt.Println(filepath.Join(outDir, filepath.FromSlash(profile.Subdir(subdir)), stored))
+		if stored != name {
+			fmt.Fprintf(os.Stderr, "  stored as %q instead of %q (%s)\n", stored, name, profile)
 		}
 	}
 	return nil
@@ -596,8 +1181,32 @@ func runFind(cmd *cobra.Command, args []string) error {
 	if limit > 0 && limit < len(matches) {
 		matches = matches[:limit]
 	}
+	names := entryNames(entries)
+	sets := setsByIndex(names)
+	indexOf := make(map[string]int, len(entries))
+	for i, e := range entries {
+		indexOf[e.URL] = i
+	}
 
 	if jsonMode {
+		type discSet struct {
+			Title string   `json:"title"`
+			Files []string `json:"files"`
+		}
+		var discSets []discSet
+		listed := map[string]bool{}
+		for _, m := range matches {
+			set, ok := sets[indexOf[m.URL]]
+			if !ok || listed[set.Title] {
+				continue
+			}
+			listed[set.Title] = true
+			ds := discSet{Title: set.Title}
+			for _, i := range set.Indices {
+				ds.Files = append(ds.Files, names[i])
+			}
+			discSets = append(discSets, ds)
+		}
 		out := struct {
 			Query       string         `json:"query"`
 			SearchPath  string         `json:"search_path"`
@@ -606,6 +1215,7 @@ func runFind(cmd *cobra.Command, args []string) error {
 			Exact       bool           `json:"exact"`
 			Count       int            `json:"count"`
 			Matches     []client.Entry `json:"matches"`
+			DiscSets    []discSet      `json:"disc_sets,omitempty"`
 		}{
 			Query:       query,
 			SearchPath:  normalizeListPath(searchPath),
@@ -614,6 +1224,7 @@ func runFind(cmd *cobra.Command, args []string) error {
 			Exact:       exact,
 			Count:       len(matches),
 			Matches:     matches,
+			DiscSets:    discSets,
 		}
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
@@ -626,7 +1237,11 @@ func runFind(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 	for i, m := range matches {
-		fmt.Printf("%s\t%s\t%s\t%s\n", strconv.Itoa(i+1)+".", m.Size, m.Date, m.Name)
+		name := m.Name
+		if label := discLabel(sets, indexOf[m.URL]); label != "" {
+			name += "  [" + label + "]"
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", strconv.Itoa(i+1)+".", m.Size, m.Date, name)
 	}
 	return nil
 }
@@ -719,16 +1334,108 @@ func tokenize(s string) []string {
 	return out
 }
 
+// queryResolver turns free-text queries into ranked file matches using the
+// download command's search flags. Directory listings are cached, so many
+// queries against the same search path cost one request.
+type queryResolver struct {
+	c                *client.Client
+	searchPath       string
+	preferRegion     string
+	preferLanguages  []string
+	exact            bool
+	includeNonRetail bool
+	matchLimit       int
+	listings         map[string][]client.Entry
+}
+
+func newQueryResolver(cmd *cobra.Command, c *client.Client) *queryResolver {
+	searchPath, _ := cmd.Flags().GetString("search-path")
+	preferRegion, _ := cmd.Flags().GetString("prefer-region")
+	preferLanguageRaw, _ := cmd.Flags().GetString("prefer-language")
+	exact, _ := cmd.Flags().GetBool("exact")
+	includeNonRetail, _ := cmd.Flags().GetBool("include-nonretail")
+	matchLimit, _ := cmd.Flags().GetInt("match-limit")
+	return &queryResolver{
+		c:                c,
+		searchPath:       normalizeListPath(searchPath),
+		preferRegion:     preferRegion,
+		preferLanguages:  parsePreferredLanguages(preferLanguageRaw),
+		exact:            exact,
+		includeNonRetail: includeNonRetail,
+		matchLimit:       matchLimit,
+		listings:         map[string][]client.Entry{},
+	}
+}
+
+// matches returns the ranked matches for query, best first. It fails when
+// nothing matches.
+func (r *queryResolver) matches(query string) ([]client.Entry, error) {
+	scored, err := r.scoredMatches(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(scored) == 0 {
+		return nil, fmt.Errorf("no matches found for %q in /%s", query, r.searchPath)
+	}
+	matches := make([]client.Entry, len(scored))
+	for i, s := range scored {
+		matches[i] = s.entry
+	}
+	return matches, nil
+}
+
+// scoredMatches returns the matches for query in the search path with their
+// scores, best first, or none when nothing matches.
+func (r *queryResolver) scoredMatches(query string) ([]scoredEntry, error) {
+	entries, ok := r.listings[r.searchPath]
+	if !ok {
+		var err error
+		entries, err = r.c.ListDirectory(context.Background(), r.searchPath)
+		if err != nil {
+			return nil, fmt.Errorf("listing search path %q: %w", r.searchPath, err)
+		}
+		r.listings[r.searchPath] = entries
+	}
+	matches := scoreMatches(entries, query, r.preferRegion, r.preferLanguages, r.exact)
+	if !r.includeNonRetail {
+		filtered := make([]scoredEntry, 0, len(matches))
+		for _, m := range matches {
+			if isNonRetail(strings.ToLower(m.entry.Name)) {
+				continue
+			}
+			filtered = append(filtered, m)
+		}
+		matches = filtered
+	}
+	if r.matchLimit > 0 && r.matchLimit < len(matches) {
+		matches = matches[:r.matchLimit]
+	}
+	return matches, nil
+}
+
 func rankMatches(entries []client.Entry, query, preferRegion string, preferLanguages []string, exact bool) []client.Entry {
+	scored := scoreMatches(entries, query, preferRegion, preferLanguages, exact)
+	res := make([]client.Entry, 0, len(scored))
+	for _, s := range scored {
+		res = append(res, s.entry)
+	}
+	return res
+}
+
+// scoredEntry is a file matched by a query, with its ranking score.
+type scoredEntry struct {
+	entry client.Entry
+	score int
+}
+
+// scoreMatches scores entries against query and returns the matches, best
+// first.
+func scoreMatches(entries []client.Entry, query, preferRegion string, preferLanguages []string, exact bool) []scoredEntry {
 	tokens := tokenize(query)
 	prefer := strings.ToLower(strings.TrimSpace(preferRegion))
 	queryLower := strings.ToLower(strings.TrimSpace(query))
 
-	type scored struct {
-		entry client.Entry
-		score int
-	}
-	var scoredEntries []scored
+	var scoredEntries []scoredEntry
 
 	for _, e := range entries {
 		if e.IsDir {
@@ -809,7 +1516,7 @@ func rankMatches(entries []client.Entry, query, preferRegion string, preferLangu
 		}
 
 		if score > 0 {
-			scoredEntries = append(scoredEntries, scored{entry: e, score: score})
+			scoredEntries = append(scoredEntries, scoredEntry{entry: e, score: score})
 		}
 	}
 
@@ -819,12 +1526,7 @@ func rankMatches(entries []client.Entry, query, preferRegion string, preferLangu
 		}
 		return scoredEntries[i].score > scoredEntries[j].score
 	})
-
-	res := make([]client.Entry, 0, len(scoredEntries))
-	for _, s := range scoredEntries {
-		res = append(res, s.entry)
-	}
-	return res
+	return scoredEntries
 }
 
 func parsePreferredLanguages(raw string) []string {
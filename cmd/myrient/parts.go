@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/daemon"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+	"github.com/JohnDeved/myrient-cli/internal/parts"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+)
+
+// interruptedDests returns the destinations saved for "download --resume".
+// Their .part files are not orphaned.
+func interruptedDests() map[string]bool {
+	dests := map[string]bool{}
+	st, err := loadInterrupted()
+	if err != nil {
+		return dests
+	}
+	for _, r := range st.Requests {
+		dests[filepath.Join(r.Dir, filepath.FromSlash(r.Subdir), r.Name)] = true
+	}
+	return dests
+}
+
+// findParts scans dir for .part files not in known and matches them through
+// the local index, if there is one.
+func findParts(dir string, known map[string]bool) ([]parts.Part, error) {
+	found, err := parts.Scan(dir, func(dest string) bool { return known[dest] })
+	if err != nil || len(found) == 0 {
+		return found, err
+	}
+	if _, err := os.Stat(config.DBPath()); err != nil {
+		return found, nil
+	}
+	db, err := index.OpenDB(config.DBPath())
+	if err != nil {
+		return found, fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	return found, parts.Match(db, dir, found)
+}
+
+// adoptParts queues the orphaned partials below dir as paused downloads in
+// dlm, so they can be resumed from the queue. It returns how many were
+// adopted and how many could not be matched to a remote file.
+func adoptParts(dlm *downloader.Manager, dir string) (adopted, unmatched int, err error) {
+	found, err := findParts(dir, interruptedDests())
+	if err != nil {
+		return 0, 0, err
+	}
+	adopted = parts.Adopt(dlm, found)
+	for _, p := range found {
+		if !p.Matched() {
+			unmatched++
+		}
+	}
+	return adopted, unmatched, nil
+}
+
+func runParts(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	dir := cfg.DownloadDir
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return err
+	}
+	cmd.SilenceUsage = true
+	if err := applyProgressFlag(cmd, cfg); err != nil {
+		return err
+	}
+
+	// Partials owned by a saved batch or by the daemon's queue are not
+	// orphaned.
+	known := interruptedDests()
+	saved := 0
+	for dest := range known {
+		if _, err := os.Stat(dest + parts.Suffix); err == nil {
+			saved++
+		}
+	}
+	dc, _ := daemon.Dial(config.SocketPath())
+	if dc != nil {
+		items, _, err := dc.List()
+		if err != nil {
+			return fmt.Errorf("listing daemon queue: %w", err)
+		}
+		for _, it := range items {
+			known[it.DestPath] = true
+		}
+	}
+
+	found, err := findParts(dir, known)
+	if err != nil {
+		return err
+	}
+
+	resume, _ := cmd.Flags().GetBool("resume")
+	requeue, _ := cmd.Flags().GetBool("requeue")
+	del, _ := cmd.Flags().GetBool("delete")
+	if jsonMode, _ := cmd.Flags().GetBool("json"); jsonMode && !resume && !requeue && !del {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if found == nil {
+			found = []parts.Part{}
+		}
+		return enc.Encode(found)
+	}
+
+	if saved > 0 {
+		fmt.Fprintf(os.Stderr, "%d partial download(s) belong to an interrupted batch; run 'myrient download --resume'\n", saved)
+	}
+	if len(found) == 0 {
+		fmt.Fprintf(os.Stderr, "No orphaned partial downloads under %s\n", dir)
+		return nil
+	}
+	printParts(dir, found)
+
+	action := ""
+	switch {
+	case resume:
+		action = "resume"
+	case requeue:
+		action = "requeue"
+	case del:
+		action = "delete"
+	case isInteractiveTerminal():
+		action = askPartsAction()
+	}
+
+	var matched []parts.Part
+	for _, p := range found {
+		if p.Matched() {
+			matched = append(matched, p)
+		}
+	}
+	switch action {
+	case "resume", "requeue":
+		if len(matched) == 0 {
+			return errors.New("no partial download could be matched to a remote file; run 'myrient index' and try again")
+		}
+		if skipped := len(found) - len(matched); skipped > 0 {
+			fmt.Fprintf(os.Stderr, "Skipping %d unmatched partial download(s)\n", skipped)
+		}
+		return continueParts(cmd, cfg, dc, matched, action == "requeue")
+	case "delete":
+		yes, _ := cmd.Flags().GetBool("yes")
+		if !yes && (!isInteractiveTerminal() || !confirm(fmt.Sprintf("Delete %d partial download(s)?", len(found)))) {
+			return errors.New("not deleting without confirmation; pass --yes")
+		}
+		var failed []string
+		for _, p := range found {
+			if err := os.Remove(p.Path); err != nil {
+				failed = append(failed, err.Error())
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Deleted %d partial download(s)\n", len(found)-len(failed))
+		if len(failed) > 0 {
+			return fmt.Errorf("%d deletion(s) failed:\n- %s", len(failed), strings.Join(failed, "\n- "))
+		}
+	}
+	return nil
+}
+
+func printParts(dir string, found []parts.Part) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MATCH\tHAVE\tREMOTE SIZE\tMODIFIED\tFILE")
+	for _, p := range found {
+		match := "matched"
+		switch {
+		case p.Matched():
+		case p.Candidates > 0:
+			match = fmt.Sprintf("ambiguous (%d)", p.Candidates)
+		default:
+			match = "unknown"
+		}
+		rel, err := filepath.Rel(dir, p.Dest)
+		if err != nil {
+			rel = p.Dest
+		}
+		remoteSize := p.RemoteSize
+		if remoteSize == "" {
+			remoteSize = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", match, util.FormatBytes(p.Size), remoteSize,
+			p.ModTime.Format("2006-01-02 15:04"), rel)
+	}
+	tw.Flush()
+}
+
+// askPartsAction asks what to do with the listed partials.
+func askPartsAction() string {
+	fmt.Fprint(os.Stderr, "[r]esume matched, re-[q]ueue matched from scratch, [d]elete all, or do [n]othing? [n] ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "r", "resume":
+		return "resume"
+	case "q", "requeue":
+		return "requeue"
+	case "d", "delete":
+		return "delete"
+	}
+	return ""
+}
+
+// continueParts downloads matched partials, through the daemon when one is
+// running. With fromScratch the .part files are deleted first.
+func continueParts(cmd *cobra.Command, cfg *config.Config, dc *daemon.Client, found []parts.Part, fromScratch bool) error {
+	reqs := make([]downloader.Request, 0, len(found))
+	for _, p := range found {
+		if fromScratch {
+			if err := os.Remove(p.Path); err != nil {
+				return err
+			}
+		}
+		reqs = append(reqs, p.Request())
+	}
+	if dc != nil {
+		results, err := submitToDaemon(cmd, dc, cfg, cfg.DownloadDir, reqs)
+		if err != nil || results == nil {
+			return err
+		}
+		return batchError(results)
+	}
+	c := client.New(cfg.BaseURL, cfg.RequestsPerSecond)
+	return batchError(downloadBatch(c, cfg, cfg.DownloadDir, reqs))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/rompatch"
+)
+
+// maxPatchSize bounds patches fetched over HTTP; real ones are far smaller.
+const maxPatchSize = 256 << 20
+
+// softPatch is a patch loaded for applying, with the name of the file
+// inside any archive it came in.
+type softPatch struct {
+	Name string
+	Data []byte
+}
+
+func runPatch(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	c := client.New(cfg.BaseURL, cfg.RequestsPerSecond)
+	output, _ := cmd.Flags().GetString("output")
+	force, _ := cmd.Flags().GetBool("force")
+
+	cmd.SilenceUsage = true
+	p, err := loadPatch(c, args[1])
+	if err != nil {
+		return err
+	}
+	_, err = applyPatch(args[0], p, output, force)
+	return err
+}
+
+// loadPatch reads a patch from a local file or a URL, unpacking it from a
+// ZIP archive if needed, and checks that its format can be applied.
+func loadPatch(c *client.Client, arg string) (softPatch, error) {
+	var name string
+	var data []byte
+	if isFileURL(arg) {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return softPatch{}, fmt.Errorf("invalid URL: %q", arg)
+		}
+		name = path.Base(u.Path)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		body, _, _, err := c.DownloadFile(ctx, arg, 0)
+		if err != nil {
+			return softPatch{}, fmt.Errorf("fetching patch: %w", err)
+		}
+		defer body.Close()
+		data, err = io.ReadAll(io.LimitReader(body, maxPatchSize+1))
+		if err != nil {
+			return softPatch{}, fmt.Errorf("fetching patch: %w", err)
+		}
+		if len(data) > maxPatchSize {
+			return softPatch{}, fmt.Errorf("patch %s is larger than %d MiB", name, maxPatchSize>>20)
+		}
+	} else {
+		var err error
+		data, err = os.ReadFile(arg)
+		if err != nil {
+			return softPatch{}, fmt.Errorf("reading patch: %w", err)
+		}
+		name = filepath.Base(arg)
+	}
+
+	name, data, err := rompatch.PatchFile(name, data)
+	if err != nil {
+		return softPatch{}, err
+	}
+	if _, err := rompatch.Detect(data); err != nil {
+		return softPatch{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return softPatch{Name: name, Data: data}, nil
+}
+
+// applyPatch patches the ROM at romPath, which may be a ZIP archive, and
+// writes the result to output, or next to the ROM when output is empty. It
+// returns the path written.
+func applyPatch(romPath string, p softPatch, output string, force bool) (string, error) {
+	data, err := os.ReadFile(romPath)
+	if err != nil {
+		return "", fmt.Errorf("reading ROM: %w", err)
+	}
+	romName, rom, err := rompatch.ROMFile(filepath.Base(romPath), data)
+	if err != nil {
+		return "", err
+	}
+	format, _ := rompatch.Detect(p.Data)
+	patched, err := rompatch.Apply(p.Data, rom)
+	if err != nil {
+		if errors.Is(err, rompatch.ErrSourceMismatch) {
+			return "", fmt.Errorf("%s: %w (CRC32 %08x); the patch was made for a different version", romName, err, crc32.ChecksumIEEE(rom))
+		}
+		return "", fmt.Errorf("applying %s: %w", p.Name, err)
+	}
+
+	if output == "" {
+		output = filepath.Join(filepath.Dir(romPath), rompatch.OutputName(romName, p.Name))
+	}
+	if !force {
+		if _, err := os.Stat(output); err == nil {
+			return "", fmt.Errorf("%s already exists (use --force to replace it)", output)
+		}
+	}
+	tmp := output + ".tmp"
+	if err := os.WriteFile(tmp, patched, 0o644); err != nil {
+		return "", fmt.Errorf("writing patched ROM: %w", err)
+	}
+	if err := os.Rename(tmp, output); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("writing patched ROM: %w", err)
+	}
+	checked := ""
+	if format != rompatch.FormatIPS {
+		checked = ", checksums verified"
+	}
+	fmt.Fprintf(os.Stderr, "Patched: %s (%s%s, CRC32 %08x)\n", output, strings.ToUpper(format.String()), checked, crc32.ChecksumIEEE(patched))
+	return output, nil
+}
+
+// extractedROM returns the largest file in a folder an archive was
+// extracted to, which is the ROM for cartridge sets. Output of an earlier
+// run with the same patch is skipped.
+func extractedROM(dir string, p softPatch) (string, error) {
+	sizes := map[string]int64{}
+	err := filepath.WalkDir(dir, func(file string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".myrient-") || rompatch.IsPatchFile(d.Name()) {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		sizes[file] = info.Size()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	var outputs []string
+	for file := range sizes {
+		name := filepath.Base(file)
+		if strings.HasSuffix(strings.TrimSuffix(name, filepath.Ext(name)), " (Patched)") {
+			outputs = append(outputs, file)
+			continue
+		}
+		outputs = append(outputs, filepath.Join(filepath.Dir(file), rompatch.OutputName(name, p.Name)))
+	}
+	for _, file := range outputs {
+		delete(sizes, file)
+	}
+	var best string
+	for file, size := range sizes {
+		if best == "" || size > sizes[best] || size == sizes[best] && file < best {
+			best = file
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no ROM found in %s", dir)
+	}
+	return best, nil
+}
+
+// downloadAndPatch downloads one file in this process and applies the patch
+// to it, or to the ROM extracted from it. The patch is loaded first, so a
+// bad patch fails before the download starts.
+func downloadAndPatch(cmd *cobra.Command, c *client.Client, cfg *config.Config, outDir string, tmpl *destpath.Template, fileURL, patchArg string) error {
+	p, err := loadPatch(c, patchArg)
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("detach") {
+		fmt.Fprintln(os.Stderr, "Note: --detach is ignored; --patch downloads in this process")
+	}
+	cmd.SilenceUsage = true
+	if err := downloadOne(c, cfg, outDir, tmpl, fileURL); err != nil {
+		if errors.Is(err, errInterrupted) {
+			return saveUnstarted(c, cfg, outDir, tmpl, nil)
+		}
+		return err
+	}
+	romPath := destFor(c, cfg, outDir, tmpl, fileURL)
+	if cfg.ExtractArchives && strings.EqualFold(filepath.Ext(romPath), ".zip") {
+		if romPath, err = extractedROM(downloader.ExtractDir(romPath), p); err != nil {
+			return err
+		}
+	}
+	// The patched ROM is derived from the download, so it is rewritten
+	// rather than treated as an existing file.
+	_, err = applyPatch(romPath, p, "", true)
+	return err
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+)
+
+// strongMargin is how far, in ranking score, a match may trail the best one
+// and still be offered as a choice. It covers the region bonus, so releases
+// of the same title for other regions are offered too.
+const strongMargin = 30
+
+// errPickCancelled is returned when the user quits the pick prompt.
+var errPickCancelled = errors.New("cancelled")
+
+// interactivePick reports whether query matches should be picked by the
+// user: with --interactive, or by default on a terminal.
+func interactivePick(cmd *cobra.Command) bool {
+	if cmd.Flags().Changed("interactive") {
+		on, _ := cmd.Flags().GetBool("interactive")
+		return on
+	}
+	return isInteractiveTerminal()
+}
+
+// strongMatches returns the leading matches that score close to the best
+// one, at most limit of them.
+func strongMatches(matches []scoredEntry, limit int) []scoredEntry {
+	n := 0
+	for n < len(matches) && matches[n].score >= matches[0].score-strongMargin {
+		n++
+	}
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return matches[:n]
+}
+
+// printChoices lists matches as a numbered table with their region,
+// language and other tags and size.
+func printChoices(w io.Writer, query string, matches []scoredEntry) {
+	fmt.Fprintf(w, "Several files match %q:\n", query)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  #\tNAME\tREGION\tLANGUAGES\tTAGS\tSIZE")
+	for i, m := range matches {
+		info := destpath.ParseName(m.entry.Name)
+		fmt.Fprintf(tw, "  %d\t%s\t%s\t%s\t%s\t%s\n", i+1, m.entry.Name,
+			orDash(strings.Join(info.Regions, ", ")),
+			orDash(strings.Join(info.Languages, ", ")),
+			orDash(strings.Join(info.Tags, ", ")),
+			orDash(m.entry.Size))
+	}
+	tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// promptPicks shows matches and reads the user's choice from in: one or
+// more numbers or ranges such as "1", "1,3" or "2-4", "a" for all of them,
+// Enter for the first and "q" to cancel. Invalid answers are asked again.
+func promptPicks(in io.Reader, out io.Writer, query string, matches []scoredEntry) ([]client.Entry, error) {
+	printChoices(out, query, matches)
+	r := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "Pick one or more (e.g. 1, 1,3 or 2-%d; a for all; Enter for 1; q to cancel): ", len(matches))
+		line, err := r.ReadString('\n')
+		if err != nil && line == "" {
+			return nil, errPickCancelled
+		}
+		picks, perr := parsePicks(line, len(matches))
+		if errors.Is(perr, errPickCancelled) {
+			return nil, perr
+		}
+		if perr != nil {
+			fmt.Fprintf(out, "%v\n", perr)
+			if err != nil {
+				return nil, errPickCancelled
+			}
+			continue
+		}
+		entries := make([]client.Entry, len(picks))
+		for i, p := range picks {
+			entries[i] = matches[p].entry
+		}
+		return entries, nil
+	}
+}
+
+// parsePicks parses an answer to the pick prompt into zero-based indexes
+// below n, in the order given and without duplicates.
+func parsePicks(answer string, n int) ([]int, error) {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	switch answer {
+	case "":
+		return []int{0}, nil
+	case "q", "quit":
+		return nil, errPickCancelled
+	case "a", "all":
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	}
+	var picks []int
+	seen := map[int]bool{}
+	for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' }) {
+		lo, hi, isRange := strings.Cut(field, "-")
+		first, err := strconv.Atoi(lo)
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(hi)
+		}
+		if err != nil || first < 1 || last > n || first > last {
+			return nil, fmt.Errorf("invalid choice %q: enter numbers from 1 to %d", field, n)
+		}
+		for i := first - 1; i < last; i++ {
+			if !seen[i] {
+				seen[i] = true
+				picks = append(picks, i)
+			}
+		}
+	}
+	return picks, nil
+}
+
+// pickQueryMatches lets the user choose among the strong matches for query
+// when there is more than one; otherwise, or when not interactive, it
+// returns the best match.
+func pickQueryMatches(cmd *cobra.Command, resolver *queryResolver, query string) ([]client.Entry, error) {
+	scored, err := resolver.scoredMatches(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(scored) == 0 {
+		return nil, fmt.Errorf("no matches found for %q in /%s", query, resolver.searchPath)
+	}
+	limit, _ := cmd.Flags().GetInt("choices")
+	strong := strongMatches(scored, limit)
+	if len(strong) < 2 || !interactivePick(cmd) {
+		return []client.Entry{scored[0].entry}, nil
+	}
+	// Cancelling is a choice, not a usage error.
+	cmd.SilenceUsage = true
+	return promptPicks(os.Stdin, os.Stderr, query, strong)
+}
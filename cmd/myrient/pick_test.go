@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+)
+
+func TestParsePicks(t *testing.T) {
+	for answer, want := range map[string][]int{
+		"":        {0},
+		"2\n":     {1},
+		"3, 1 3":  {2, 0},
+		"2-4":     {1, 2, 3},
+		"a":       {0, 1, 2, 3},
+		" 4,1-2 ": {3, 0, 1},
+	} {
+		got, err := parsePicks(answer, 4)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("parsePicks(%q) = %v, %v; want %v", answer, got, err, want)
+		}
+	}
+	for _, answer := range []string{"0", "5", "3-2", "x", "1-"} {
+		if _, err := parsePicks(answer, 4); err == nil {
+			t.Errorf("parsePicks(%q) accepted an invalid choice", answer)
+		}
+	}
+	if _, err := parsePicks("q", 4); !errors.Is(err, errPickCancelled) {
+		t.Errorf("q: err = %v, want errPickCancelled", err)
+	}
+}
+
+func TestPromptPicks_AsksAgain(t *testing.T) {
+	matches := []scoredEntry{
+		{entry: client.Entry{Name: "Game (USA).zip"}, score: 60},
+		{entry: client.Entry{Name: "Game (Japan) (En,Ja).zip"}, score: 50},
+	}
+	var out bytes.Buffer
+	got, err := promptPicks(strings.NewReader("7\n2\n"), &out, "game", matches)
+	if err != nil || len(got) != 1 || got[0].Name != "Game (Japan) (En,Ja).zip" {
+		t.Fatalf("promptPicks = %v, %v", got, err)
+	}
+	if !strings.Contains(out.String(), "invalid choice") || !strings.Contains(out.String(), "En, Ja") {
+		t.Errorf("output misses the retry or the language column:\n%s", out.String())
+	}
+	if _, err := promptPicks(strings.NewReader(""), &out, "game", matches); !errors.Is(err, errPickCancelled) {
+		t.Errorf("EOF: err = %v, want errPickCancelled", err)
+	}
+}
+
+func TestStrongMatches(t *testing.T) {
+	matches := []scoredEntry{{score: 80}, {score: 60}, {score: 50}, {score: 49}, {score: 10}}
+	if got := strongMatches(matches, 0); len(got) != 3 {
+		t.Errorf("got %d strong matches, want 3", len(got))
+	}
+	if got := strongMatches(matches, 2); len(got) != 2 {
+		t.Errorf("limit: got %d, want 2", len(got))
+	}
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+)
+
+// Progress modes accepted by --progress and the progress config setting.
+const (
+	progressAuto  = "auto"
+	progressBar   = "bar"
+	progressPlain = "plain"
+	progressJSON  = "json"
+)
+
+const (
+	// plainInterval is how often plain mode prints a progress line per file.
+	plainInterval = 5 * time.Second
+	// jsonInterval throttles progress events per file in JSON mode.
+	jsonInterval = time.Second
+	// barInterval throttles redraws of the bar view.
+	barInterval = 100 * time.Millisecond
+	// maxBars caps how many files the bar view draws at once.
+	maxBars = 8
+)
+
+// applyProgressFlag overrides the configured progress mode with --progress
+// and validates the result.
+func applyProgressFlag(cmd *cobra.Command, cfg *config.Config) error {
+	if cmd.Flags().Changed("progress") {
+		cfg.Progress, _ = cmd.Flags().GetString("progress")
+	}
+	cfg.Progress = strings.ToLower(strings.TrimSpace(cfg.Progress))
+	switch cfg.Progress {
+	case "", progressAuto, progressBar, progressPlain, progressJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown progress mode %q (want auto, bar, plain or json)", cfg.Progress)
+}
+
+// progressView renders download events while the CLI waits for downloads.
+type progressView interface {
+	// handle reports one event; name is how the file is shown to the user.
+	handle(ev downloader.Event, name string)
+	// close finishes the output, e.g. after following was interrupted.
+	close()
+}
+
+// newProgressView returns the view for mode, following total downloads.
+// Human-readable views write to stderr, JSON goes to stdout.
+func newProgressView(mode string, total int) progressView {
+	switch mode {
+	case progressJSON:
+		return &jsonProgress{enc: json.NewEncoder(os.Stdout), last: map[int]time.Time{}}
+	case progressPlain:
+		return &plainProgress{w: os.Stderr, total: total, last: map[int]time.Time{}}
+	case progressBar:
+		return newBarProgress(os.Stderr, total)
+	}
+	if term.IsTerminal(os.Stderr.Fd()) {
+		return newBarProgress(os.Stderr, total)
+	}
+	return &plainProgress{w: os.Stderr, total: total, last: map[int]time.Time{}}
+}
+
+// eventETA estimates the time left for one file, if its speed is known.
+func eventETA(ev downloader.Event) (time.Duration, bool) {
+	if ev.Speed <= 0 || ev.TotalBytes <= 0 || ev.DoneBytes > ev.TotalBytes {
+		return 0, false
+	}
+	return time.Duration(float64(ev.TotalBytes-ev.DoneBytes) / ev.Speed * float64(time.Second)), true
+}
+
+// counter prefixes finish lines with "[n/total] " when following several
+// files.
+func counter(n, total int) string {
+	if total <= 1 {
+		return ""
+	}
+	return fmt.Sprintf("[%d/%d] ", n, total)
+}
+
+// finishLine describes a completed, failed or retrying download, or returns
+// "" for other events. finished is the number of files done including this
+// one.
+func finishLine(ev downloader.Event, name string, finished, total int) string {
+	switch ev.Type {
+	case downloader.EventCompleted:
+		if ev.Status == downloader.StatusPresent {
+			return counter(finished, total) + "Already present: " + name
+		}
+		return counter(finished, total) + "Downloaded: " + name
+	case downloader.EventFailed:
+		return fmt.Sprintf("%sFailed: %s: %v", counter(finished, total), name, ev.Err)
+	case downloader.EventRetrying:
+		return fmt.Sprintf("  %s error on %s, retrying: %v", ev.Class, name, ev.Err)
+	}
+	return ""
+}
+
+func isFinished(ev downloader.Event) bool {
+	return ev.Type == downloader.EventCompleted || ev.Type == downloader.EventFailed
+}
+
+// plainProgress prints newline-terminated lines only, for logs and CI.
+type plainProgress struct {
+	w        io.Writer
+	total    int
+	finished int
+	last     map[int]time.Time
+}
+
+func (p *plainProgress) handle(ev downloader.Event, name string) {
+	switch ev.Type {
+	case downloader.EventStarted:
+		line := "Started: " + name
+		if ev.TotalBytes > 0 {
+			line += " (" + util.FormatBytes(ev.TotalBytes) + ")"
+		}
+		fmt.Fprintln(p.w, line)
+		p.last[ev.ItemID] = time.Now()
+	case downloader.EventProgress:
+		if time.Since(p.last[ev.ItemID]) < plainInterval {
+			return
+		}
+		p.last[ev.ItemID] = time.Now()
+		fmt.Fprintf(p.w, "  %s: %s\n", name, progressText(ev))
+	default:
+		if isFinished(ev) {
+			p.finished++
+			delete(p.last, ev.ItemID)
+		}
+		if line := finishLine(ev, name, p.finished, p.total); line != "" {
+			fmt.Fprintln(p.w, line)
+		}
+	}
+}
+
+func (p *plainProgress) close() {}
+
+// progressText summarizes a progress event, e.g.
+// "45.2% of 1.2 GiB, 3.0 MiB/s, ETA 1m".
+func progressText(ev downloader.Event) string {
+	var sb strings.Builder
+	if ev.TotalBytes > 0 {
+		fmt.Fprintf(&sb, "%.1f%% of %s", float64(ev.DoneBytes)/float64(ev.TotalBytes)*100, util.FormatBytes(ev.TotalBytes))
+	} else {
+		sb.WriteString(util.FormatBytes(ev.DoneBytes))
+	}
+	fmt.Fprintf(&sb, ", %s/s", util.FormatBytes(int64(ev.Speed)))
+	if eta, ok := eventETA(ev); ok {
+		sb.WriteString(", ETA " + util.FormatDuration(eta))
+	}
+	return sb.String()
+}
+
+// jsonEvent is one NDJSON line of --progress=json output.
+type jsonEvent struct {
+	// Event is "start", "progress", "done" or "error".
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	URL        string    `json:"url,omitempty"`
+	Dest       string    `json:"dest,omitempty"`
+	DoneBytes  int64     `json:"done_bytes"`
+	TotalBytes int64     `json:"total_bytes,omitempty"`
+	Speed      float64   `json:"speed,omitempty"`
+	ETASeconds float64   `json:"eta_seconds,omitempty"`
+	// Present is set on "done" when the file already existed.
+	Present bool   `json:"present,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Retrying is set on "error" when the download will be retried.
+	Retrying bool `json:"retrying,omitempty"`
+}
+
+// jsonProgress writes one JSON object per line.
+type jsonProgress struct {
+	enc  *json.Encoder
+	last map[int]time.Time
+}
+
+func (p *jsonProgress) handle(ev downloader.Event, name string) {
+	out := jsonEvent{
+		Time:       ev.Time,
+		ID:         ev.ItemID,
+		Name:       name,
+		URL:        ev.URL,
+		Dest:       ev.DestPath,
+		DoneBytes:  ev.DoneBytes,
+		TotalBytes: ev.TotalBytes,
+	}
+	if out.Time.IsZero() {
+		out.Time = time.Now()
+	}
+	switch ev.Type {
+	case downloader.EventStarted:
+		out.Event = "start"
+		p.last[ev.ItemID] = time.Now()
+	case downloader.EventProgress:
+		if time.Since(p.last[ev.ItemID]) < jsonInterval {
+			return
+		}
+		p.last[ev.ItemID] = time.Now()
+		out.Event = "progress"
+		out.Speed = ev.Speed
+		if eta, ok := eventETA(ev); ok {
+			out.ETASeconds = eta.Seconds()
+		}
+	case downloader.EventCompleted:
+		out.Event = "done"
+		out.Present = ev.Status == downloader.StatusPresent
+		delete(p.last, ev.ItemID)
+	case downloader.EventFailed, downloader.EventRetrying:
+		out.Event = "error"
+		out.Retrying = ev.Type == downloader.EventRetrying
+		if ev.Err != nil {
+			out.Error = ev.Err.Error()
+		}
+		if !out.Retrying {
+			delete(p.last, ev.ItemID)
+		}
+	default:
+		return
+	}
+	_ = p.enc.Encode(out)
+}
+
+func (p *jsonProgress) close() {}
+
+// barProgress redraws a block of progress bars below the finished lines,
+// one bar per active file plus a total line when following several files.
+type barProgress struct {
+	w        io.Writer
+	total    int
+	finished int
+	active   []int
+	state    map[int]barState
+	drawn    int
+	lastDraw time.Time
+}
+
+type barState struct {
+	name string
+	ev   downloader.Event
+}
+
+func newBarProgress(w io.Writer, total int) *barProgress {
+	return &barProgress{w: w, total: total, state: map[int]barState{}}
+}
+
+func (p *barProgress) handle(ev downloader.Event, name string) {
+	switch ev.Type {
+	case downloader.EventStarted, downloader.EventProgress:
+		if _, ok := p.state[ev.ItemID]; !ok {
+			p.active = append(p.active, ev.ItemID)
+		}
+		p.state[ev.ItemID] = barState{name: name, ev: ev}
+		if ev.Type == downloader.EventProgress && time.Since(p.lastDraw) < barInterval {
+			return
+		}
+		p.redraw("")
+	default:
+		if isFinished(ev) {
+			p.finished++
+			p.remove(ev.ItemID)
+		}
+		// A paused or retrying file is not transferring; its last speed
+		// must not count in the total.
+		if ev.Type == downloader.EventPaused || ev.Type == downloader.EventRetrying {
+			p.remove(ev.ItemID)
+		}
+		p.redraw(finishLine(ev, name, p.finished, p.total))
+	}
+}
+
+func (p *barProgress) remove(id int) {
+	delete(p.state, id)
+	for i, a := range p.active {
+		if a == id {
+			p.active = append(p.active[:i], p.active[i+1:]...)
+			return
+		}
+	}
+}
+
+// redraw clears the block, prints line above it if not empty and draws the
+// block again.
+func (p *barProgress) redraw(line string) {
+	var sb strings.Builder
+	if p.drawn > 0 {
+		fmt.Fprintf(&sb, "\033[%dA", p.drawn)
+	}
+	sb.WriteString("\r\033[J")
+	if line != "" {
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+
+	width := 80
+	if w, _, err := term.GetSize(os.Stderr.Fd()); err == nil && w > 20 {
+		width = w
+	}
+	lines := p.lines(width - 1)
+	for _, l := range lines {
+		sb.WriteString(l)
+		sb.WriteString("\n")
+	}
+	p.drawn = len(lines)
+	p.lastDraw = time.Now()
+	io.WriteString(p.w, sb.String())
+}
+
+func (p *barProgress) lines(width int) []string {
+	var lines []string
+	var speed float64
+	var remaining int64
+	for i, id := range p.active {
+		st := p.state[id]
+		speed += st.ev.Speed
+		if st.ev.TotalBytes > st.ev.DoneBytes {
+			remaining += st.ev.TotalBytes - st.ev.DoneBytes
+		}
+		if i < maxBars {
+			lines = append(lines, barLine(st.ev, st.name, width))
+		}
+	}
+	if len(p.active) > maxBars {
+		lines = append(lines, fmt.Sprintf("  ... and %d more", len(p.active)-maxBars))
+	}
+	if p.total > 1 {
+		eta := "--"
+		if speed > 0 {
+			eta = util.FormatDuration(time.Duration(float64(remaining) / speed * float64(time.Second)))
+		}
+		lines = append(lines, fmt.Sprintf("  %d/%d files done, %d active, %s/s, ETA %s",
+			p.finished, p.total, len(p.active), util.FormatBytes(int64(speed)), eta))
+	}
+	return lines
+}
+
+// barLine renders "  [#####-----]  45.2%  3.0 MiB/s  ETA 1m  name", cutting
+// the name to fit width.
+func barLine(ev downloader.Event, name string, width int) string {
+	const barWidth = 20
+	fraction := 0.0
+	if ev.TotalBytes > 0 {
+		fraction = min(1, float64(ev.DoneBytes)/float64(ev.TotalBytes))
+	}
+	filled := int(fraction * barWidth)
+	eta := "--"
+	if d, ok := eventETA(ev); ok {
+		eta = util.FormatDuration(d)
+	}
+	prefix := fmt.Sprintf("  [%s%s] %5.1f%%  %s/s  ETA %s  ",
+		strings.Repeat("#", filled), strings.Repeat("-", barWidth-filled),
+		fraction*100, util.FormatBytes(int64(ev.Speed)), eta)
+	room := width - len(prefix)
+	if room < 4 {
+		return prefix
+	}
+	if r := []rune(name); len(r) > room {
+		name = string(r[:room-3]) + "..."
+	}
+	return prefix + name
+}
+
+func (p *barProgress) close() {
+	if p.drawn > 0 {
+		fmt.Fprintf(p.w, "\033[%dA\r\033[J", p.drawn)
+		p.drawn = 0
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+func TestPlainProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := &plainProgress{w: &out, total: 2, last: map[int]time.Time{}}
+	for _, tc := range []struct {
+		ev   downloader.Event
+		name string
+		// due makes a progress event arrive after the print interval.
+		due  bool
+		want string
+	}{
+		{downloader.Event{Type: downloader.EventStarted, ItemID: 1, TotalBytes: 2048}, "a.zip", false,
+			"Started: a.zip (2.0 KB)\n"},
+		{downloader.Event{Type: downloader.EventProgress, ItemID: 1, DoneBytes: 512, TotalBytes: 2048}, "a.zip", false,
+			""},
+		{downloader.Event{Type: downloader.EventProgress, ItemID: 1, DoneBytes: 1024, TotalBytes: 2048, Speed: 512}, "a.zip", true,
+			"  a.zip: 50.0% of 2.0 KB, 512 B/s, ETA 2s\n"},
+		{downloader.Event{Type: downloader.EventRetrying, ItemID: 1, Class: downloader.ClassServer, Err: errors.New("503")}, "a.zip", false,
+			"  server error on a.zip, retrying: 503\n"},
+		{downloader.Event{Type: downloader.EventCompleted, ItemID: 1, Status: downloader.StatusCompleted}, "a.zip", false,
+			"[1/2] Downloaded: a.zip\n"},
+		{downloader.Event{Type: downloader.EventFailed, ItemID: 2, Err: errors.New("not found")}, "b.zip", false,
+			"[2/2] Failed: b.zip: not found\n"},
+		{downloader.Event{Type: downloader.EventPaused, ItemID: 3}, "c.zip", false,
+			""},
+	} {
+		out.Reset()
+		if tc.due {
+			p.last[tc.ev.ItemID] = time.Now().Add(-plainInterval)
+		}
+		p.handle(tc.ev, tc.name)
+		if got := out.String(); got != tc.want {
+			t.Errorf("%v %s: got %q, want %q", tc.ev.Type, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestJSONProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := &jsonProgress{enc: json.NewEncoder(&out), last: map[int]time.Time{}}
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		ev   downloader.Event
+		due  bool
+		want string
+	}{
+		{downloader.Event{Type: downloader.EventStarted, Time: at, ItemID: 1, URL: "u", DestPath: "d", TotalBytes: 100},
+			false, `{"event":"start","time":"2024-01-02T03:04:05Z","id":1,"name":"a.zip","url":"u","dest":"d","done_bytes":0,"total_bytes":100}`},
+		{downloader.Event{Type: downloader.EventProgress, Time: at, ItemID: 1, DoneBytes: 10, TotalBytes: 100, Speed: 10},
+			false, ``},
+		{downloader.Event{Type: downloader.EventProgress, Time: at, ItemID: 1, DoneBytes: 50, TotalBytes: 100, Speed: 10},
+			true, `{"event":"progress","time":"2024-01-02T03:04:05Z","id":1,"name":"a.zip","done_bytes":50,"total_bytes":100,"speed":10,"eta_seconds":5}`},
+		{downloader.Event{Type: downloader.EventRetrying, Time: at, ItemID: 1, Err: errors.New("reset")},
+			false, `{"event":"error","time":"2024-01-02T03:04:05Z","id":1,"name":"a.zip","done_bytes":0,"error":"reset","retrying":true}`},
+		{downloader.Event{Type: downloader.EventCompleted, Time: at, ItemID: 1, Status: downloader.StatusPresent, DoneBytes: 100, TotalBytes: 100},
+			false, `{"event":"done","time":"2024-01-02T03:04:05Z","id":1,"name":"a.zip","done_bytes":100,"total_bytes":100,"present":true}`},
+		{downloader.Event{Type: downloader.EventFailed, Time: at, ItemID: 1, Err: errors.New("gone")},
+			false, `{"event":"error","time":"2024-01-02T03:04:05Z","id":1,"name":"a.zip","done_bytes":0,"error":"gone"}`},
+		{downloader.Event{Type: downloader.EventEnqueued, Time: at, ItemID: 1},
+			false, ``},
+	} {
+		out.Reset()
+		if tc.due {
+			p.last[tc.ev.ItemID] = time.Now().Add(-jsonInterval)
+		}
+		p.handle(tc.ev, "a.zip")
+		if got := strings.TrimSuffix(out.String(), "\n"); got != tc.want {
+			t.Errorf("%v:\n got %s\nwant %s", tc.ev.Type, got, tc.want)
+		}
+	}
+}
+
+func TestBarProgress_RetryingLeavesTotal(t *testing.T) {
+	var out bytes.Buffer
+	p := newBarProgress(&out, 2)
+	p.handle(downloader.Event{Type: downloader.EventProgress, ItemID: 1, DoneBytes: 10, TotalBytes: 100, Speed: 1024}, "a.zip")
+	p.handle(downloader.Event{Type: downloader.EventProgress, ItemID: 2, DoneBytes: 10, TotalBytes: 100, Speed: 2048}, "b.zip")
+	p.handle(downloader.Event{Type: downloader.EventRetrying, ItemID: 2, Class: downloader.ClassNetwork, Err: errors.New("reset")}, "b.zip")
+
+	lines := p.lines(80)
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "a.zip") {
+		t.Fatalf("lines = %q", lines)
+	}
+	if want := "0/2 files done, 1 active, 1.0 KB/s"; !strings.Contains(lines[1], want) {
+		t.Errorf("total line %q, want %q", lines[1], want)
+	}
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+)
+
+// Resolution statuses of a wishlist title.
+const (
+	wishOK        = "ok"
+	wishAmbiguous = "ambiguous"
+	wishLow       = "low"
+	wishMissing   = "missing"
+	wishError     = "error"
+)
+
+// ambiguityMargin is how close, in confidence points, a match for another
+// title has to come to the best one for the title to count as ambiguous.
+const ambiguityMargin = 10
+
+// wish is one title of a wishlist.
+type wish struct {
+	N     int
+	Title string
+	// System is the row's own system column, if the wishlist has one.
+	System string
+}
+
+// readWishlist reads titles from a CSV or TSV file with an optional header
+// naming a title and a system column, or from plain text with one title per
+// line. Blank rows and rows starting with "#" are skipped.
+func readWishlist(r io.Reader, name string) ([]wish, error) {
+	var comma rune
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		comma = ','
+	case ".tsv":
+		comma = '\t'
+	default:
+		return readWishLines(r)
+	}
+	cr := csv.NewReader(r)
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.Comment = '#'
+
+	titleCol, systemCol := 0, -1
+	var wishes []wish
+	for first := true; ; first = false {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return wishes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && isWishHeader(row) {
+			for i, cell := range row {
+				switch strings.ToLower(strings.TrimSpace(cell)) {
+				case "title", "name", "game":
+					titleCol = i
+				case "system", "platform", "console":
+					systemCol = i
+				}
+			}
+			continue
+		}
+		if titleCol >= len(row) {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		w := wish{N: line, Title: strings.TrimSpace(row[titleCol])}
+		if systemCol >= 0 && systemCol < len(row) {
+			w.System = strings.TrimSpace(row[systemCol])
+		}
+		if w.Title != "" {
+			wishes = append(wishes, w)
+		}
+	}
+}
+
+// isWishHeader reports whether a wishlist row is a header naming the title
+// column.
+func isWishHeader(row []string) bool {
+	for _, cell := range row {
+		switch strings.ToLower(strings.TrimSpace(cell)) {
+		case "title", "name", "game":
+			return true
+		}
+	}
+	return false
+}
+
+func readWishLines(r io.Reader) ([]wish, error) {
+	var wishes []wish
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		title := strings.TrimSpace(sc.Text())
+		if title == "" || strings.HasPrefix(title, "#") {
+			continue
+		}
+		wishes = append(wishes, wish{N: n, Title: title})
+	}
+	return wishes, sc.Err()
+}
+
+// candidate is a file matched for a wishlist title.
+type candidate struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Score is the query ranking score, which includes region and
+	// language preferences.
+	Score int `json:"score"`
+	// Confidence is how well the file's title matches the wished title,
+	// from 0 to 100.
+	Confidence int `json:"confidence"`
+}
+
+// resolution is the outcome of resolving one wishlist title.
+type resolution struct {
+	Line      int         `json:"line"`
+	Title     string      `json:"title"`
+	Path      string      `json:"path,omitempty"`
+	Status    string      `json:"status"`
+	Best      *candidate  `json:"best,omitempty"`
+	RunnersUp []candidate `json:"runners_up,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// accepted reports whether the title resolved well enough to download.
+func (r resolution) accepted(acceptFlagged bool) bool {
+	switch r.Status {
+	case wishOK:
+		return true
+	case wishAmbiguous, wishLow:
+		return acceptFlagged
+	}
+	return false
+}
+
+// titleConfidence rates from 0 to 100 how well the title of a file name
+// matches a wished title, by the overlap of their words.
+func titleConfidence(wished, name string) int {
+	want := tokenize(wished)
+	have := tokenize(destpath.ParseName(name).Title)
+	if len(want) == 0 || len(have) == 0 {
+		return 0
+	}
+	counts := map[string]int{}
+	for _, t := range have {
+		counts[t]++
+	}
+	common := 0
+	for _, t := range want {
+		if counts[t] > 0 {
+			counts[t]--
+			common++
+		}
+	}
+	return 200 * common / (len(want) + len(have))
+}
+
+// resolveWish ranks the matches for w in the resolver's search path and
+// judges the best one: missing when nothing matches, low when the best
+// match's title is too different, ambiguous when a different title comes
+// close.
+func resolveWish(r *queryResolver, w wish, runnersUp, minConfidence int) resolution {
+	res := resolution{Line: w.N, Title: w.Title, Path: r.searchPath}
+	scored, err := r.scoredMatches(w.Title)
+	if err != nil {
+		res.Status, res.Error = wishError, err.Error()
+		return res
+	}
+	if len(scored) == 0 {
+		res.Status = wishMissing
+		return res
+	}
+	cands := make([]candidate, len(scored))
+	for i, s := range scored {
+		cands[i] = candidate{Name: s.entry.Name, URL: s.entry.URL, Score: s.score, Confidence: titleConfidence(w.Title, s.entry.Name)}
+	}
+	// Title confidence comes first; the ranking score then picks among
+	// releases of the same title by region and language.
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].Confidence > cands[j].Confidence })
+
+	best := cands[0]
+	res.Best = &best
+	res.Status = wishOK
+	bestTitle := strings.ToLower(destpath.ParseName(best.Name).Title)
+	for _, c := range cands[1:] {
+		if strings.ToLower(destpath.ParseName(c.Name).Title) != bestTitle && c.Confidence >= best.Confidence-ambiguityMargin {
+			res.Status = wishAmbiguous
+			break
+		}
+	}
+	if best.Confidence < minConfidence {
+		res.Status = wishLow
+	}
+	if n := min(runnersUp, len(cands)-1); n > 0 {
+		res.RunnersUp = cands[1 : 1+n]
+	}
+	return res
+}
+
+// systemDirs resolves --system values and system columns to directory
+// paths through the local index. Values containing "/" are taken as paths.
+type systemDirs struct {
+	db    *index.DB
+	paths map[string]string
+}
+
+func (s *systemDirs) dir(system string) (string, error) {
+	if strings.Contains(system, "/") {
+		return normalizeListPath(system), nil
+	}
+	if p, ok := s.paths[system]; ok {
+		return p, nil
+	}
+	if s.db == nil {
+		return "", fmt.Errorf("system %q needs the local index to be found; run 'myrient index' or pass its path, e.g. No-Intro/%s", system, system)
+	}
+	dirs, err := s.db.DirectoriesNamed(system)
+	if err != nil {
+		return "", fmt.Errorf("looking up system %q: %w", system, err)
+	}
+	if len(dirs) == 0 {
+		return "", fmt.Errorf("system %q not found in the local index; pass its path, e.g. No-Intro/%s", system, system)
+	}
+	if len(dirs) > 1 {
+		fmt.Fprintf(os.Stderr, "Note: %q is in %d places, using /%s (pass a path to pick another)\n", system, len(dirs), dirs[0])
+	}
+	s.paths[system] = dirs[0]
+	return dirs[0], nil
+}
+
+// indexListing returns the files directly in dir from the local index, as
+// directory entries.
+func indexListing(db *index.DB, dir string) ([]client.Entry, error) {
+	files, err := db.FilesUnder(dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []client.Entry
+	for _, f := range files {
+		if strings.Contains(strings.TrimPrefix(f.Path, dir), "/") {
+			continue
+		}
+		entries = append(entries, client.Entry{Name: f.Name, URL: f.URL, Size: f.Size, Date: f.Date})
+	}
+	return entries, nil
+}
+
+func runResolve(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	c := client.New(cfg.BaseURL, cfg.RequestsPerSecond)
+
+	in, err := openInput(args[0])
+	if err != nil {
+		return err
+	}
+	wishes, err := readWishlist(in, args[0])
+	in.Close()
+	if err != nil {
+		return fmt.Errorf("reading wishlist: %w", err)
+	}
+	if len(wishes) == 0 {
+		return errors.New("wishlist is empty")
+	}
+
+	system, _ := cmd.Flags().GetString("system")
+	useIndex, _ := cmd.Flags().GetBool("use-index")
+	runnersUp, _ := cmd.Flags().GetInt("runners-up")
+	minConfidence, _ := cmd.Flags().GetInt("min-confidence")
+	acceptFlagged, _ := cmd.Flags().GetBool("accept-ambiguous")
+	writePath, _ := cmd.Flags().GetString("write")
+	download, _ := cmd.Flags().GetBool("download")
+	jsonMode, _ := cmd.Flags().GetBool("json")
+
+	systems := &systemDirs{paths: map[string]string{}}
+	if _, err := os.Stat(config.DBPath()); err == nil {
+		if systems.db, err = index.OpenDB(config.DBPath()); err != nil {
+			return fmt.Errorf("opening database: %w", err)
+		}
+		defer systems.db.Close()
+	}
+	if useIndex && systems.db == nil {
+		return errors.New("--use-index needs the local index; run 'myrient index' first")
+	}
+	cmd.SilenceUsage = true
+
+	resolver := newQueryResolver(cmd, c)
+	if system != "" {
+		// Fail once for a bad --system instead of once per title.
+		if resolver.searchPath, err = systems.dir(system); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Resolving %d title(s)...\n", len(wishes))
+	results := make([]resolution, 0, len(wishes))
+	for _, w := range wishes {
+		s := w.System
+		if s == "" {
+			s = system
+		}
+		if s != "" {
+			dir, err := systems.dir(s)
+			if err != nil {
+				results = append(results, resolution{Line: w.N, Title: w.Title, Status: wishError, Error: err.Error()})
+				continue
+			}
+			resolver.searchPath = dir
+		} else if resolver.searchPath == "" {
+			results = append(results, resolution{Line: w.N, Title: w.Title, Status: wishError, Error: "no system given; pass --system or --search-path"})
+			continue
+		}
+		if _, ok := resolver.listings[resolver.searchPath]; !ok && useIndex {
+			entries, err := indexListing(systems.db, resolver.searchPath)
+			if err != nil {
+				return fmt.Errorf("reading index: %w", err)
+			}
+			resolver.listings[resolver.searchPath] = entries
+		}
+		results = append(results, resolveWish(resolver, w, runnersUp, minConfidence))
+	}
+
+	if jsonMode {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		printResolutions(os.Stdout, results)
+	}
+	summarizeResolutions(results)
+
+	if writePath != "" {
+		if err := writeResolvedList(writePath, results, acceptFlagged); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote URL list: %s (download it with 'myrient download --input %s')\n", writePath, writePath)
+	}
+	if download {
+		if err := downloadResolved(cmd, c, cfg, results, acceptFlagged); err != nil {
+			return err
+		}
+	}
+	return resolveError(results)
+}
+
+// printResolutions prints one row per title, followed by its runners-up.
+func printResolutions(w io.Writer, results []resolution) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LINE\tSTATUS\tCONF\tTITLE\tMATCH")
+	for _, r := range results {
+		switch {
+		case r.Best != nil:
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n", r.Line, r.Status, r.Best.Confidence, r.Title, r.Best.Name)
+		case r.Error != "":
+			fmt.Fprintf(tw, "%d\t%s\t-\t%s\t%s\n", r.Line, r.Status, r.Title, r.Error)
+		default:
+			fmt.Fprintf(tw, "%d\t%s\t-\t%s\tno match in /%s\n", r.Line, r.Status, r.Title, r.Path)
+		}
+		for _, c := range r.RunnersUp {
+			fmt.Fprintf(tw, "\t\t%d\t\t  or %s\n", c.Confidence, c.Name)
+		}
+	}
+	tw.Flush()
+}
+
+func summarizeResolutions(results []resolution) {
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	parts := []string{fmt.Sprintf("%d ok", counts[wishOK])}
+	for _, status := range []string{wishAmbiguous, wishLow, wishMissing, wishError} {
+		if counts[status] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Resolved %d title(s): %s\n", len(results), strings.Join(parts, ", "))
+}
+
+// writeResolvedList writes the accepted URLs in the format read by
+// "download --input". Titles that were not accepted are kept as comments,
+// so they can be fixed by hand.
+func writeResolvedList(path string, results []resolution, acceptFlagged bool) error {
+	var sb strings.Builder
+	for _, r := range results {
+		if r.accepted(acceptFlagged) {
+			sb.WriteString(r.Best.URL + "\n")
+			continue
+		}
+		fmt.Fprintf(&sb, "# %s: %s", r.Status, r.Title)
+		if r.Best != nil {
+			fmt.Fprintf(&sb, " (best: %s)", r.Best.URL)
+		}
+		sb.WriteString("\n")
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		return fmt.Errorf("writing URL list: %w", err)
+	}
+	return nil
+}
+
+// downloadResolved downloads the accepted matches, through the daemon when
+// one is running.
+func downloadResolved(cmd *cobra.Command, c *client.Client, cfg *config.Config, results []resolution, acceptFlagged bool) error {
+	outDir, _ := cmd.Flags().GetString("output")
+	if outDir == "" {
+		outDir = cfg.DownloadDir
+	}
+	tmpl, err := pathTemplateFromFlags(cmd, cfg)
+	if err != nil {
+		return err
+	}
+	if err := applyProgressFlag(cmd, cfg); err != nil {
+		return err
+	}
+
+	var reqs []downloader.Request
+	for _, r := range results {
+		if !r.accepted(acceptFlagged) {
+			continue
+		}
+		req, err := fileRequest(c, tmpl, r.Best.URL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %v\n", r.Title, err)
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	if len(reqs) == 0 {
+		fmt.Fprintln(os.Stderr, "Nothing to download")
+		return nil
+	}
+
+	var batch []batchResult
+	if dc := daemonFromFlags(cmd); dc != nil {
+		batch, err = submitToDaemon(cmd, dc, cfg, outDir, reqs)
+		if err != nil || batch == nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Downloading %d file(s)\n", len(reqs))
+		batch = downloadBatch(c, cfg, outDir, reqs)
+	}
+	return batchError(batch)
+}
+
+// resolveError returns an error whose exit status reflects whether some or
+// all titles could not be matched.
+func resolveError(results []resolution) error {
+	unmatched := 0
+	for _, r := range results {
+		if r.Best == nil {
+			unmatched++
+		}
+	}
+	switch {
+	case unmatched == 0:
+		return nil
+	case unmatched == len(results):
+		return &exitError{exitFailed, fmt.Errorf("none of the %d title(s) matched", unmatched)}
+	default:
+		return &exitError{exitPartial, fmt.Errorf("%d of %d title(s) did not match", unmatched, len(results))}
+	}
+}
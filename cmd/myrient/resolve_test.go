@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadWishlist(t *testing.T) {
+	in := "Notes,Game,System\n" +
+		"rpg,Golden Sun,Nintendo - Game Boy Advance\n" +
+		"# skipped\n" +
+		",,\n" +
+		"\"with, comma\",\"Legend of Zelda, The\",\n"
+	wishes, err := readWishlist(strings.NewReader(in), "list.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []wish{
+		{N: 2, Title: "Golden Sun", System: "Nintendo - Game Boy Advance"},
+		{N: 5, Title: "Legend of Zelda, The"},
+	}
+	if len(wishes) != len(want) {
+		t.Fatalf("got %+v, want %+v", wishes, want)
+	}
+	for i := range want {
+		if wishes[i] != want[i] {
+			t.Errorf("wish %d: got %+v, want %+v", i, wishes[i], want[i])
+		}
+	}
+
+	// Without a header the first column holds the titles; plain text files
+	// hold one title per line.
+	wishes, _ = readWishlist(strings.NewReader("Tetris,1989\n"), "list.csv")
+	if len(wishes) != 1 || wishes[0].Title != "Tetris" {
+		t.Errorf("headerless CSV: %+v", wishes)
+	}
+	wishes, _ = readWishlist(strings.NewReader("Tetris, DX\n\nKirby\n"), "list.txt")
+	if len(wishes) != 2 || wishes[0].Title != "Tetris, DX" || wishes[1].N != 3 {
+		t.Errorf("text list: %+v", wishes)
+	}
+}
+
+func TestTitleConfidence(t *testing.T) {
+	for _, tc := range []struct {
+		wished, name string
+		want         int
+	}{
+		{"Golden Sun", "Golden Sun (USA, Europe).zip", 100},
+		{"golden sun", "Golden Sun - The Lost Age (USA, Europe).zip", 66},
+		{"Metroid", "Golden Sun (USA).zip", 0},
+	} {
+		if got := titleConfidence(tc.wished, tc.name); got != tc.want {
+			t.Errorf("titleConfidence(%q, %q) = %d, want %d", tc.wished, tc.name, got, tc.want)
+		}
+	}
+}
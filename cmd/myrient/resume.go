@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+// errInterrupted marks downloads paused by Ctrl+C. They are saved for
+// "myrient download --resume".
+var errInterrupted = errors.New("interrupted")
+
+// interruptedState is the content of config.InterruptedPath.
+type interruptedState struct {
+	SavedAt  time.Time            `json:"saved_at"`
+	Requests []interruptedRequest `json:"requests"`
+}
+
+// interruptedRequest is a paused download. Dir is always absolute so the
+// batch can be resumed from any working directory.
+type interruptedRequest struct {
+	downloader.Request
+	// ExistingFiles is the existing-file policy the batch ran with.
+	ExistingFiles string `json:"existing_files,omitempty"`
+}
+
+func loadInterrupted() (*interruptedState, error) {
+	data, err := os.ReadFile(config.InterruptedPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return &interruptedState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st interruptedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", config.InterruptedPath(), err)
+	}
+	return &st, nil
+}
+
+// writeInterrupted replaces the saved state, removing the file when there
+// is nothing left to resume.
+func writeInterrupted(reqs []interruptedRequest) error {
+	path := config.InterruptedPath()
+	if len(reqs) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(interruptedState{SavedAt: time.Now(), Requests: reqs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// saveInterrupted adds paused requests to the saved state, replacing
+// earlier entries for the same URL. Requests without a Dir are saved below
+// outDir.
+func saveInterrupted(cfg *config.Config, outDir string, reqs []downloader.Request) error {
+	absDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return err
+	}
+	st, err := loadInterrupted()
+	if err != nil {
+		return err
+	}
+	added := make(map[string]bool, len(reqs))
+	var merged []interruptedRequest
+	for _, req := range reqs {
+		if req.Dir == "" {
+			req.Dir = absDir
+		}
+		added[req.URL] = true
+		merged = append(merged, interruptedRequest{Request: req, ExistingFiles: cfg.ExistingFiles})
+	}
+	for _, r := range st.Requests {
+		if !added[r.URL] {
+			merged = append(merged, r)
+		}
+	}
+	return writeInterrupted(merged)
+}
+
+// pauseBatch pauses everything still running in dlm after an interrupt,
+// settles the results of items that were not followed to the end and saves
+// the paused ones for --resume.
+func pauseBatch(dlm *downloader.Manager, cfg *config.Config, outDir string, byID map[int]int, results []batchResult) {
+	fmt.Fprintln(os.Stderr, "\nInterrupted, pausing downloads...")
+	dlm.PauseAll()
+
+	var paused []downloader.Request
+	for _, it := range dlm.Items() {
+		i, tracked := byID[it.ID]
+		if !tracked {
+			continue
+		}
+		it.Mu.Lock()
+		status, itemErr := it.Status, it.Error
+		it.Mu.Unlock()
+		switch status {
+		case downloader.StatusCompleted:
+		case downloader.StatusPresent:
+			results[i].Present = true
+		case downloader.StatusPaused:
+			results[i].Err = errInterrupted
+			paused = append(paused, results[i].Req)
+		default:
+			results[i].Err = itemErr
+		}
+	}
+	if len(paused) == 0 {
+		return
+	}
+	if err := saveInterrupted(cfg, outDir, paused); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save interrupted downloads: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Paused %d download(s); run 'myrient download --resume' to continue\n", len(paused))
+}
+
+// interrupted reports whether a batch was stopped by Ctrl+C.
+func interrupted(results []batchResult) bool {
+	for _, r := range results {
+		if errors.Is(r.Err, errInterrupted) {
+			return true
+		}
+	}
+	return false
+}
+
+// runResume continues the downloads saved by earlier interrupted runs. The
+// saved state is only rewritten once this run ends, so killing it loses
+// nothing.
+func runResume(cmd *cobra.Command, c *client.Client, cfg *config.Config) error {
+	cmd.SilenceUsage = true
+	st, err := loadInterrupted()
+	if err != nil {
+		return err
+	}
+	if len(st.Requests) == 0 {
+		return errors.New("no interrupted downloads to resume")
+	}
+	fmt.Fprintf(os.Stderr, "Resuming %d download(s) interrupted %s\n", len(st.Requests), st.SavedAt.Format("2006-01-02 15:04"))
+
+	// Requests keep the existing-file policy of the batch they came from.
+	var policies []string
+	groups := map[string][]interruptedRequest{}
+	for _, r := range st.Requests {
+		if _, ok := groups[r.ExistingFiles]; !ok {
+			policies = append(policies, r.ExistingFiles)
+		}
+		groups[r.ExistingFiles] = append(groups[r.ExistingFiles], r)
+	}
+
+	var all []batchResult
+	var left []interruptedRequest
+	for _, policy := range policies {
+		group := groups[policy]
+		if len(all) > 0 && interrupted(all) {
+			left = append(left, group...)
+			continue
+		}
+		reqs := make([]downloader.Request, len(group))
+		for i, r := range group {
+			reqs[i] = r.Request
+		}
+		groupCfg := *cfg
+		if policy != "" {
+			groupCfg.ExistingFiles = policy
+		}
+		results := downloadBatch(c, &groupCfg, "", reqs)
+		for i, r := range results {
+			if errors.Is(r.Err, errInterrupted) {
+				left = append(left, group[i])
+			}
+		}
+		all = append(all, results...)
+	}
+
+	if err := writeInterrupted(left); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not update interrupted downloads: %v\n", err)
+	}
+	return batchError(all)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/mirror"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+	"github.com/JohnDeved/myrient-cli/internal/walk"
+)
+
+func runSync(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	c := client.New(cfg.BaseURL, cfg.RequestsPerSecond)
+
+	dirPath := remoteDirPath(c.BaseURL(), args[0])
+	if dirPath == "" {
+		return fmt.Errorf("refusing to sync the whole archive; pass a directory path")
+	}
+	localDir, err := filepath.Abs(args[1])
+	if err != nil {
+		return err
+	}
+	deleteRemoved, _ := cmd.Flags().GetBool("delete")
+	quarantine, _ := cmd.Flags().GetBool("quarantine")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if err := applyProgressFlag(cmd, cfg); err != nil {
+		return err
+	}
+	profile, err := applyFSProfileFlag(cmd, cfg)
+	if err != nil {
+		return err
+	}
+
+	filter, err := walkFilterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	files, err := walkFromFlags(cmd, c, dirPath)
+	if err != nil {
+		return err
+	}
+	files, remoteNames := storedFiles(files, profile)
+	plan, err := mirror.Compute(files, localDir, filter)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		printSyncPlan(plan, deleteRemoved, quarantine)
+		return nil
+	}
+	printSyncSummary(plan, deleteRemoved, quarantine)
+	if plan.Empty() {
+		return nil
+	}
+
+	cmd.SilenceUsage = true
+	var errs []error
+	if fetch := syncFetchList(plan); len(fetch) > 0 {
+		// Changed files must replace their stale copies.
+		cfg.ExistingFiles = downloader.ExistingOverwrite.String()
+		// Archives are mirrored as they are so they compare next time.
+		cfg.ExtractArchives = false
+		reqs := make([]downloader.Request, len(fetch))
+		for i, f := range fetch {
+			// Requests carry remote names; the manager applies the profile.
+			rel := remoteNames[f.RelPath]
+			subdir := path.Dir(rel)
+			if subdir == "." {
+				subdir = ""
+			}
+			reqs[i] = downloader.Request{
+				Name:   path.Base(rel),
+				URL:    f.URL,
+				Dir:    localDir,
+				Subdir: filepath.FromSlash(subdir),
+				Size:   f.Size,
+			}
+		}
+		fmt.Fprintf(os.Stderr, "To: %s\n", localDir)
+		results := downloadBatch(c, cfg, localDir, reqs)
+		for i, r := range results {
+			if r.Err == nil {
+				stampRemoteDate(localDir, fetch[i])
+			}
+		}
+		if err := batchError(results); err != nil {
+			// Leave removed files alone until the sync has finished.
+			if interrupted(results) {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	if len(plan.Removed) > 0 && (deleteRemoved || quarantine) {
+		batch := time.Now().Format("20060102-150405")
+		for _, rel := range plan.Removed {
+			var err error
+			if quarantine {
+				err = mirror.Quarantine(localDir, batch, rel)
+			} else {
+				err = mirror.Remove(localDir, rel)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("removing %s: %w", rel, err))
+			}
+		}
+		if quarantine {
+			fmt.Fprintf(os.Stderr, "Moved %d removed file(s) to %s\n", len(plan.Removed),
+				filepath.Join(localDir, mirror.QuarantineDir, batch))
+		} else {
+			fmt.Fprintf(os.Stderr, "Deleted %d removed file(s)\n", len(plan.Removed))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// storedFiles rewrites the relative paths of the remote files to the names
+// the filesystem profile stores them under, so they compare with the local
+// folder. The returned map leads from stored back to remote paths.
+func storedFiles(files []walk.File, profile downloader.FSProfile) ([]walk.File, map[string]string) {
+	remoteNames := make(map[string]string, len(files))
+	stored := make([]walk.File, len(files))
+	for i, f := range files {
+		rel := f.RelPath
+		f.RelPath = profile.Subdir(f.RelPath)
+		remoteNames[f.RelPath] = rel
+		stored[i] = f
+	}
+	return stored, remoteNames
+}
+
+// syncFetchList returns the new and changed files, in that order.
+func syncFetchList(plan *mirror.Plan) []walk.File {
+	files := append([]walk.File(nil), plan.New...)
+	for _, ch := range plan.Changed {
+		files = append(files, ch.File)
+	}
+	return files
+}
+
+// stampRemoteDate sets a synced file's modification time to its listing
+// date so the next run sees it as current.
+func stampRemoteDate(localDir string, f walk.File) {
+	date, ok := util.ParseListingDate(f.Date)
+	if !ok {
+		return
+	}
+	_ = os.Chtimes(filepath.Join(localDir, filepath.FromSlash(f.RelPath)), time.Now(), date)
+}
+
+func removedAction(deleteRemoved, quarantine bool) string {
+	switch {
+	case quarantine:
+		return "quarantine"
+	case deleteRemoved:
+		return "delete"
+	default:
+		return "keep"
+	}
+}
+
+// printSyncPlan prints every planned action on stdout, one per line.
+func printSyncPlan(plan *mirror.Plan, deleteRemoved, quarantine bool) {
+	for _, f := range plan.New {
+		fmt.Printf("+ %s\t%s\n", f.RelPath, f.SizeText)
+	}
+	for _, ch := range plan.Changed {
+		fmt.Printf("~ %s\t%s\n", ch.File.RelPath, ch.Reason)
+	}
+	action := removedAction(deleteRemoved, quarantine)
+	for _, rel := range plan.Removed {
+		fmt.Printf("- %s\t%s\n", rel, action)
+	}
+	printSyncSummary(plan, deleteRemoved, quarantine)
+}
+
+func printSyncSummary(plan *mirror.Plan, deleteRemoved, quarantine bool) {
+	var bytes int64
+	for _, f := range syncFetchList(plan) {
+		bytes += f.Size
+	}
+	fmt.Fprintf(os.Stderr, "New %d, changed %d (%s to download), removed upstream %d (%s), unchanged %d\n",
+		len(plan.New), len(plan.Changed), util.FormatBytes(bytes),
+		len(plan.Removed), removedAction(deleteRemoved, quarantine), plan.Unchanged)
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -22,6 +23,20 @@ type Entry struct {
 	IsDir bool
 }
 
+// HTTPError reports an unexpected HTTP status for a request.
+type HTTPError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d downloading %s", e.StatusCode, e.URL)
+}
+
+// ErrHTMLResponse is returned when the server answers a file request with an
+// HTML page, which usually means a block or error page rather than the file.
+var ErrHTMLResponse = errors.New("refusing HTML response")
+
 // Client handles HTTP requests to Myrient.
 type Client struct {
 	listHTTP *http.Client // Short timeout for directory listings
@@ -117,7 +132,7 @@ func (c *Client) DownloadFile(ctx context.Context, fileURL string, resumeFrom in
 	resumed := resp.StatusCode == http.StatusPartialContent
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		resp.Body.Close()
-		return nil, 0, false, fmt.Errorf("HTTP %d downloading %s", resp.StatusCode, fileURL)
+		return nil, 0, false, &HTTPError{StatusCode: resp.StatusCode, URL: fileURL}
 	}
 
 	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
@@ -126,7 +141,7 @@ func (c *Client) DownloadFile(ctx context.Context, fileURL string, resumeFrom in
 		!strings.HasSuffix(lowerURL, ".html") &&
 		!strings.HasSuffix(lowerURL, ".htm") {
 		resp.Body.Close()
-		return nil, 0, false, fmt.Errorf("refusing HTML response for file URL %s", fileURL)
+		return nil, 0, false, fmt.Errorf("%w for file URL %s", ErrHTMLResponse, fileURL)
 	}
 
 	return resp.Body, resp.ContentLength, resumed, nil
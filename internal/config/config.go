@@ -26,6 +26,44 @@ type Config struct {
 	IndexStaleDays int `json:"index_stale_days"`
 	// BaseURL is the root URL for Myrient's file listings.
 	BaseURL string `json:"base_url"`
+	// MinFreeSpaceMB is how much space must stay free on the download
+	// filesystem after accounting for queued downloads.
+	MinFreeSpaceMB int64 `json:"min_free_space_mb"`
+	// PreallocateFiles reserves the full file size on disk when a download starts.
+	PreallocateFiles bool `json:"preallocate_files"`
+	// WriteBufferKB is the buffer size used when writing downloads to disk.
+	WriteBufferKB int `json:"write_buffer_kb"`
+	// MaxRetries is how many times a download is retried automatically after
+	// a transient failure (timeouts, connection resets, 5xx responses).
+	MaxRetries int `json:"max_retries"`
+	// RetryBackoffSeconds is the delay before the first retry; it doubles
+	// after each further attempt.
+	RetryBackoffSeconds int `json:"retry_backoff_seconds"`
+	// PathTemplate decides where files are saved below the download
+	// directory, e.g. "{collection}/{system}/{title}/{name}". See the
+	// destpath package for the available tokens.
+	PathTemplate string `json:"path_template"`
+	// ExistingFiles decides what happens when a destination file already
+	// exists: "skip" (matching size), "verify" (matching hash when known)
+	// or "overwrite".
+	ExistingFiles string `json:"existing_files"`
+	// FSProfile adapts stored file names to the destination filesystem:
+	// "posix", "windows", "fat32" or "exfat". Renamed files are listed in a
+	// .myrient-names.tsv file next to them, and fat32 refuses files of
+	// 4 GiB or more.
+	FSProfile string `json:"fs_profile"`
+	// ExtractArchives extracts ZIP archives into a folder named after them
+	// while they download, instead of saving the archive.
+	ExtractArchives bool `json:"extract_archives"`
+	// DiscSetFolder puts each multi-disc set downloaded as a whole into a
+	// folder named after the game, next to its .m3u playlist.
+	DiscSetFolder bool `json:"disc_set_folder"`
+	// ExportFormat is the format the TUI uses when exporting a download
+	// list: "aria2", "wget", "curl" or "metalink".
+	ExportFormat string `json:"export_format"`
+	// Progress is how command-line downloads report progress: "auto" (bars
+	// on a terminal, plain lines otherwise), "bar", "plain" or "json".
+	Progress string `json:"progress"`
 }
 
 // DefaultConfig returns sensible defaults.
@@ -37,6 +75,16 @@ func DefaultConfig() *Config {
 		RequestsPerSecond:      5.0,
 		IndexStaleDays:         7,
 		BaseURL:                "https://myrient.erista.me/files/",
+		MinFreeSpaceMB:         256,
+		PreallocateFiles:       true,
+		WriteBufferKB:          256,
+		MaxRetries:             3,
+		RetryBackoffSeconds:    5,
+		PathTemplate:           "{path}/{name}",
+		ExistingFiles:          "skip",
+		FSProfile:              "posix",
+		ExportFormat:           "aria2",
+		Progress:               "auto",
 	}
 }
 
@@ -54,6 +102,38 @@ func DBPath() string {
 	return filepath.Join(ConfigDir(), "index.db")
 }
 
+// HistoryDBPath returns the path of the download history database. It is
+// kept apart from the index so clearing the index keeps the history.
+func HistoryDBPath() string {
+	return filepath.Join(ConfigDir(), "history.db")
+}
+
+// LibraryDBPath returns the path of the database of local files matched to
+// remote ones by "myrient library scan".
+func LibraryDBPath() string {
+	return filepath.Join(ConfigDir(), "library.db")
+}
+
+// DATDBPath returns the path of the database of imported DATs. It is kept
+// apart from the index so clearing the index keeps the DATs.
+func DATDBPath() string {
+	return filepath.Join(ConfigDir(), "dats.db")
+}
+
+// InterruptedPath returns the path of the file that keeps downloads paused
+// by interrupting the CLI, for "myrient download --resume".
+func InterruptedPath() string {
+	return filepath.Join(ConfigDir(), "interrupted.json")
+}
+
+// SocketPath returns the path of the download daemon's control socket.
+func SocketPath() string {
+	if p := os.Getenv("MYRIENT_SOCKET"); p != "" {
+		return p
+	}
+	return filepath.Join(ConfigDir(), "daemon.sock")
+}
+
 // ConfigPath returns the path to the config file.
 func ConfigPath() string {
 	return filepath.Join(ConfigDir(), "config.json")
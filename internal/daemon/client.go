@@ -0,0 +1,180 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+// callTimeout bounds a single request/response exchange.
+const callTimeout = 10 * time.Second
+
+// Client talks to a running daemon.
+type Client struct {
+	path string
+}
+
+// Dial checks that a daemon answers on the socket at path.
+func Dial(path string) (*Client, error) {
+	c := &Client{path: path}
+	if _, err := c.call(Request{Op: OpPing}); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	return c, nil
+}
+
+func (c *Client) connect() (net.Conn, error) {
+	return net.DialTimeout("unix", c.path, 2*time.Second)
+}
+
+func (c *Client) call(req Request) (*Response, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(callTimeout))
+
+	if err := sendRequest(conn, req); err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading daemon response: %w", err)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("decoding daemon response: %w", err)
+	}
+	if !resp.OK {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+func sendRequest(conn net.Conn, req Request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("sending daemon request: %w", err)
+	}
+	return nil
+}
+
+// Add submits downloads and returns their queue entries in order.
+func (c *Client) Add(reqs []downloader.Request) ([]Added, error) {
+	resp, err := c.call(Request{Op: OpAdd, Downloads: reqs})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Added, nil
+}
+
+// List returns every queue item and the queue statistics.
+func (c *Client) List() ([]downloader.Snapshot, *Stats, error) {
+	resp, err := c.call(Request{Op: OpList})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Items, resp.Stats, nil
+}
+
+// Pause pauses the given items and returns how many changed state.
+func (c *Client) Pause(ids ...int) (int, error) { return c.count(OpPause, ids) }
+
+// Resume resumes the given paused items.
+func (c *Client) Resume(ids ...int) (int, error) { return c.count(OpResume, ids) }
+
+// Cancel cancels the given items.
+func (c *Client) Cancel(ids ...int) (int, error) { return c.count(OpCancel, ids) }
+
+// Retry restarts the given failed or held items.
+func (c *Client) Retry(ids ...int) (int, error) { return c.count(OpRetry, ids) }
+
+// CancelAll cancels every pending item.
+func (c *Client) CancelAll() error {
+	_, err := c.call(Request{Op: OpCancelAll})
+	return err
+}
+
+// ClearFinished removes finished items and returns how many were removed.
+func (c *Client) ClearFinished() (int, error) { return c.count(OpClear, nil) }
+
+// Shutdown asks the daemon to exit.
+func (c *Client) Shutdown() error {
+	_, err := c.call(Request{Op: OpShutdown})
+	return err
+}
+
+func (c *Client) count(op string, ids []int) (int, error) {
+	resp, err := c.call(Request{Op: op, IDs: ids})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+// Watch streams the daemon's download events until ctx is cancelled or the
+// connection drops, then closes the channel.
+func (c *Client) Watch(ctx context.Context) (<-chan downloader.Event, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	if err := sendRequest(conn, Request{Op: OpWatch}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(callTimeout))
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading daemon response: %w", err)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil || !resp.OK {
+		conn.Close()
+		return nil, fmt.Errorf("daemon refused watch: %s", resp.Error)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	out := make(chan downloader.Event, 64)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	go func() {
+		defer close(out)
+		defer close(done)
+		defer conn.Close()
+		for {
+			line, err := r.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			var ev Event
+			if err := json.Unmarshal(line, &ev); err != nil {
+				continue
+			}
+			select {
+			case out <- ev.Downloader():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
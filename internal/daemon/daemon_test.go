@@ -0,0 +1,54 @@
+package daemon
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+func TestServerRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	sock := filepath.Join(dir, "d.sock")
+	ln, err := Listen(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := NewServer(downloader.NewManager(nil, dir, 1), dir)
+	srv.SetShutdown(cancel)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, ln) }()
+
+	dc, err := Dial(sock)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	items, stats, err := dc.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(items) != 0 || stats == nil || stats.DownloadDir != dir {
+		t.Fatalf("List = %v, %+v", items, stats)
+	}
+	if n, err := dc.Pause(42); err != nil || n != 0 {
+		t.Fatalf("Pause(unknown) = %d, %v", n, err)
+	}
+	if _, err := dc.Add([]downloader.Request{{Name: "x", URL: "http://example/x", Dir: "relative"}}); err == nil {
+		t.Fatal("Add with relative dir succeeded")
+	}
+	if _, err := Listen(sock); err == nil {
+		t.Fatal("second Listen on a live socket succeeded")
+	}
+
+	if err := dc.Shutdown(); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	if _, err := Dial(sock); err == nil {
+		t.Fatal("Dial succeeded after shutdown")
+	}
+}
@@ -0,0 +1,123 @@
+// Package daemon runs a download manager in the background and exposes it
+// over a local Unix socket. Each connection carries one JSON request line
+// and gets one JSON response line back, except for the watch operation,
+// which streams events until the client disconnects.
+package daemon
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+// Operations understood by the daemon.
+const (
+	OpPing      = "ping"
+	OpAdd       = "add"
+	OpList      = "list"
+	OpPause     = "pause"
+	OpResume    = "resume"
+	OpCancel    = "cancel"
+	OpCancelAll = "cancel-all"
+	OpRetry     = "retry"
+	OpClear     = "clear"
+	OpWatch     = "watch"
+	OpShutdown  = "shutdown"
+)
+
+// ErrNotRunning is returned by Dial when no daemon answers on the socket.
+var ErrNotRunning = errors.New("daemon not running")
+
+// Request is a single call to the daemon.
+type Request struct {
+	Op        string               `json:"op"`
+	IDs       []int                `json:"ids,omitempty"`
+	Downloads []downloader.Request `json:"downloads,omitempty"`
+}
+
+// Response answers a Request. Fields are filled depending on the operation.
+type Response struct {
+	OK    bool                  `json:"ok"`
+	Error string                `json:"error,omitempty"`
+	Items []downloader.Snapshot `json:"items,omitempty"`
+	Added []Added               `json:"added,omitempty"`
+	Stats *Stats                `json:"stats,omitempty"`
+	// Count is the number of items an operation affected.
+	Count int `json:"count,omitempty"`
+}
+
+// Added reports the queue entry for one submitted download.
+type Added struct {
+	ID      int  `json:"id"`
+	Created bool `json:"created"`
+}
+
+// Stats summarizes the daemon's queue.
+type Stats struct {
+	DownloadDir string        `json:"download_dir"`
+	Throughput  float64       `json:"throughput"`
+	ETA         time.Duration `json:"eta,omitempty"`
+	ETAKnown    bool          `json:"eta_known,omitempty"`
+	// FreeBytes is the free space on the download filesystem, or -1 when
+	// it could not be determined.
+	FreeBytes int64 `json:"free_bytes"`
+}
+
+// Event is the wire form of a downloader.Event.
+type Event struct {
+	Type       downloader.EventType  `json:"type"`
+	Time       time.Time             `json:"time"`
+	ItemID     int                   `json:"item_id"`
+	Name       string                `json:"name"`
+	URL        string                `json:"url"`
+	DestPath   string                `json:"dest_path"`
+	Status     downloader.Status     `json:"status"`
+	DoneBytes  int64                 `json:"done_bytes"`
+	TotalBytes int64                 `json:"total_bytes"`
+	Speed      float64               `json:"speed"`
+	Error      string                `json:"error,omitempty"`
+	Class      downloader.ErrorClass `json:"class,omitempty"`
+}
+
+func eventToWire(ev downloader.Event) Event {
+	w := Event{
+		Type:       ev.Type,
+		Time:       ev.Time,
+		ItemID:     ev.ItemID,
+		Name:       ev.Name,
+		URL:        ev.URL,
+		DestPath:   ev.DestPath,
+		Status:     ev.Status,
+		DoneBytes:  ev.DoneBytes,
+		TotalBytes: ev.TotalBytes,
+		Speed:      ev.Speed,
+		Class:      ev.Class,
+	}
+	if ev.Err != nil {
+		w.Error = ev.Err.Error()
+	}
+	return w
+}
+
+// Downloader converts the wire event back into a downloader.Event. The
+// error keeps only its message.
+func (e Event) Downloader() downloader.Event {
+	ev := downloader.Event{
+		Type:       e.Type,
+		Time:       e.Time,
+		ItemID:     e.ItemID,
+		Name:       e.Name,
+		URL:        e.URL,
+		DestPath:   e.DestPath,
+		Status:     e.Status,
+		DoneBytes:  e.DoneBytes,
+		TotalBytes: e.TotalBytes,
+		Speed:      e.Speed,
+		Class:      e.Class,
+	}
+	if e.Error != "" {
+		ev.Err = errors.New(e.Error)
+	}
+	return ev
+}
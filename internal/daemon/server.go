@@ -0,0 +1,240 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+// Server answers daemon requests on behalf of a download manager.
+type Server struct {
+	mgr         *downloader.Manager
+	downloadDir string
+
+	mu       sync.Mutex
+	shutdown func()
+}
+
+// NewServer creates a server for mgr. downloadDir is reported in stats.
+func NewServer(mgr *downloader.Manager, downloadDir string) *Server {
+	return &Server{mgr: mgr, downloadDir: downloadDir}
+}
+
+// SetShutdown registers the function called when a client asks the daemon
+// to stop.
+func (s *Server) SetShutdown(fn func()) {
+	s.mu.Lock()
+	s.shutdown = fn
+	s.mu.Unlock()
+}
+
+// Listen opens the control socket at path. A leftover socket file from a
+// daemon that is no longer running is removed first; if another daemon is
+// still answering, Listen fails.
+func Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating socket directory: %w", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		if _, err := Dial(path); err == nil {
+			return nil, fmt.Errorf("a daemon is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", path, err)
+	}
+	// Only the owner may control downloads.
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("securing socket: %w", err)
+	}
+	return ln, nil
+}
+
+// Serve accepts connections until ctx is cancelled or the listener fails.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(callTimeout))
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		writeJSON(conn, Response{Error: fmt.Sprintf("malformed request: %v", err)})
+		return
+	}
+	if req.Op == OpWatch {
+		s.watch(ctx, conn)
+		return
+	}
+	writeJSON(conn, s.dispatch(req))
+}
+
+func (s *Server) dispatch(req Request) Response {
+	switch req.Op {
+	case OpPing:
+		return Response{OK: true}
+
+	case OpAdd:
+		added := make([]Added, 0, len(req.Downloads))
+		for _, dl := range req.Downloads {
+			if dl.Dir != "" && !filepath.IsAbs(dl.Dir) {
+				return Response{Error: fmt.Sprintf("download directory %q is not absolute", dl.Dir)}
+			}
+		}
+		for _, dl := range req.Downloads {
+			item, created := s.mgr.Add(dl)
+			added = append(added, Added{ID: item.ID, Created: created})
+		}
+		return Response{OK: true, Added: added, Count: len(added)}
+
+	case OpList:
+		return Response{OK: true, Items: s.mgr.Snapshots(), Stats: s.stats()}
+
+	case OpPause, OpResume, OpCancel, OpRetry:
+		count := 0
+		for _, id := range req.IDs {
+			if s.apply(req.Op, id) {
+				count++
+			}
+		}
+		return Response{OK: true, Count: count}
+
+	case OpCancelAll:
+		s.mgr.CancelAll()
+		return Response{OK: true}
+
+	case OpClear:
+		return Response{OK: true, Count: s.mgr.ClearFinished()}
+
+	case OpShutdown:
+		s.mu.Lock()
+		fn := s.shutdown
+		s.mu.Unlock()
+		if fn == nil {
+			return Response{Error: "shutdown not supported"}
+		}
+		go fn()
+		return Response{OK: true}
+	}
+	return Response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+}
+
+func (s *Server) apply(op string, id int) bool {
+	switch op {
+	case OpPause:
+		return s.mgr.Pause(id)
+	case OpResume:
+		return s.mgr.Resume(id)
+	case OpRetry:
+		return s.mgr.Retry(id)
+	case OpCancel:
+		for _, snap := range s.mgr.Snapshots() {
+			if snap.ID == id && snap.Status.Pending() {
+				s.mgr.Cancel(id)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) stats() *Stats {
+	st := &Stats{DownloadDir: s.downloadDir, Throughput: s.mgr.Throughput()}
+	st.ETA, st.ETAKnown = s.mgr.QueueETA()
+	free, err := s.mgr.FreeSpace()
+	if err != nil {
+		free = -1
+	}
+	st.FreeBytes = free
+	return st
+}
+
+// watch streams events to the client until it disconnects or the daemon
+// stops. The initial OK response tells the client the stream is live.
+func (s *Server) watch(ctx context.Context, conn net.Conn) {
+	events, unsubscribe := s.mgr.Subscribe(64)
+	defer unsubscribe()
+	if err := writeJSON(conn, Response{OK: true}); err != nil {
+		return
+	}
+
+	// Reading only ever returns on disconnect; use it to stop early.
+	gone := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				close(gone)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gone:
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeJSON(conn, eventToWire(ev)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeJSON(conn net.Conn, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	conn.SetWriteDeadline(time.Now().Add(callTimeout))
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,270 @@
+package dat
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Audit statuses.
+const (
+	// StatusHave is a game all of whose ROMs were found.
+	StatusHave = "have"
+	// StatusMiss is a game with ROMs that were not found.
+	StatusMiss = "miss"
+	// StatusBad is a local file named like a ROM whose checksum or size
+	// differs, e.g. a bad or modified dump.
+	StatusBad = "bad"
+	// StatusUnknown is a local file no DAT knows.
+	StatusUnknown = "unknown"
+)
+
+// Row is one line of an audit report.
+type Row struct {
+	Status string `json:"status"`
+	DAT    string `json:"dat,omitempty"`
+	Game   string `json:"game,omitempty"`
+	// Path is the local file, with archive members as "archive.zip/member".
+	Path   string `json:"path,omitempty"`
+	Detail string `json:"detail,omitempty"`
+	// URL is where a missing game can be downloaded, when known.
+	URL string `json:"url,omitempty"`
+}
+
+// DATSummary counts the games of one DAT by status.
+type DATSummary struct {
+	DAT   string `json:"dat"`
+	Games int    `json:"games"`
+	Have  int    `json:"have"`
+	Miss  int    `json:"miss"`
+}
+
+// Report is the result of an audit.
+type Report struct {
+	Root    string       `json:"root"`
+	DATs    []DATSummary `json:"dats"`
+	Bad     int          `json:"bad"`
+	Unknown int          `json:"unknown"`
+	Rows    []Row        `json:"rows"`
+}
+
+type romRef struct{ dat, game, rom int }
+
+type crcKey struct {
+	crc  string
+	size int64
+}
+
+// Auditor checks local files against DATs by CRC32 and size.
+type Auditor struct {
+	dats       []*Datafile
+	byCRC      map[crcKey][]romRef
+	byName     map[string][]romRef
+	sizes      map[int64]bool
+	allMissing bool
+}
+
+// NewAuditor returns an auditor for dats.
+func NewAuditor(dats []*Datafile) *Auditor {
+	a := &Auditor{
+		dats:   dats,
+		byCRC:  map[crcKey][]romRef{},
+		byName: map[string][]romRef{},
+		sizes:  map[int64]bool{},
+	}
+	for di, d := range dats {
+		for gi, g := range d.Games {
+			for ri, r := range g.ROMs {
+				ref := romRef{di, gi, ri}
+				if r.CRC != "" {
+					a.byCRC[crcKey{r.CRC, r.Size}] = append(a.byCRC[crcKey{r.CRC, r.Size}], ref)
+					a.sizes[r.Size] = true
+				}
+				name := strings.ToLower(r.Name)
+				a.byName[name] = append(a.byName[name], ref)
+			}
+		}
+	}
+	return a
+}
+
+// SetAllMissing makes the report list the missing games of every DAT,
+// not only of the DATs the folder holds files from.
+func (a *Auditor) SetAllMissing(on bool) {
+	a.allMissing = on
+}
+
+// Audit walks root and reports which games are complete, which are
+// missing, and which local files are bad or unknown. ZIP archives are
+// audited by their members' recorded CRC32s without extracting them.
+func (a *Auditor) Audit(ctx context.Context, root string) (*Report, error) {
+	rep := &Report{Root: root}
+	found := map[romRef]string{}
+	touched := map[int]bool{}
+
+	check := func(p, name string, size int64, crc func() (string, error)) error {
+		sum := ""
+		if a.sizes[size] {
+			var err error
+			if sum, err = crc(); err != nil {
+				return err
+			}
+			if refs := a.byCRC[crcKey{sum, size}]; len(refs) > 0 {
+				for _, ref := range refs {
+					if _, ok := found[ref]; !ok {
+						found[ref] = p
+					}
+					touched[ref.dat] = true
+				}
+				return nil
+			}
+		}
+		if refs := a.byName[strings.ToLower(name)]; len(refs) > 0 {
+			ref := refs[0]
+			r := a.dats[ref.dat].Games[ref.game].ROMs[ref.rom]
+			touched[ref.dat] = true
+			detail := fmt.Sprintf("size %d, expected %d", size, r.Size)
+			if size == r.Size {
+				detail = fmt.Sprintf("CRC %s, expected %s", sum, r.CRC)
+			}
+			rep.Rows = append(rep.Rows, Row{
+				Status: StatusBad,
+				DAT:    a.dats[ref.dat].Header.Name,
+				Game:   a.dats[ref.dat].Games[ref.game].Name,
+				Path:   p,
+				Detail: detail,
+			})
+			rep.Bad++
+			return nil
+		}
+		rep.Rows = append(rep.Rows, Row{Status: StatusUnknown, Path: p})
+		rep.Unknown++
+		return nil
+	}
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if strings.HasPrefix(d.Name(), ".") && p != root {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(p), ".zip") {
+			if zr, err := zip.OpenReader(p); err == nil {
+				defer zr.Close()
+				for _, zf := range zr.File {
+					if zf.FileInfo().IsDir() {
+						continue
+					}
+					sum := fmt.Sprintf("%08x", zf.CRC32)
+					member := p + "/" + zf.Name
+					if err := check(member, filepath.Base(zf.Name), int64(zf.UncompressedSize64), func() (string, error) { return sum, nil }); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return check(p, d.Name(), info.Size(), func() (string, error) { return fileCRC(p) })
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for di, d := range a.dats {
+		sum := DATSummary{DAT: d.Header.Name, Games: len(d.Games)}
+		report := a.allMissing || touched[di]
+		for gi, g := range d.Games {
+			var paths, missing []string
+			need := 0
+			for ri, r := range g.ROMs {
+				if r.Status == "nodump" {
+					continue
+				}
+				need++
+				if p, ok := found[romRef{di, gi, ri}]; ok {
+					paths = append(paths, p)
+				} else {
+					missing = append(missing, r.Name)
+				}
+			}
+			if need > 0 && len(missing) == 0 {
+				sum.Have++
+				rep.Rows = append(rep.Rows, Row{Status: StatusHave, DAT: d.Header.Name, Game: g.Name, Path: commonPath(paths)})
+				continue
+			}
+			sum.Miss++
+			if !report {
+				continue
+			}
+			row := Row{Status: StatusMiss, DAT: d.Header.Name, Game: g.Name}
+			if len(paths) > 0 {
+				row.Path = commonPath(paths)
+				row.Detail = fmt.Sprintf("%d of %d ROMs missing: %s", len(missing), need, strings.Join(missing, ", "))
+			}
+			rep.Rows = append(rep.Rows, row)
+		}
+		if report {
+			rep.DATs = append(rep.DATs, sum)
+		}
+	}
+	sort.SliceStable(rep.Rows, func(i, j int) bool { return statusOrder(rep.Rows[i].Status) < statusOrder(rep.Rows[j].Status) })
+	return rep, nil
+}
+
+func statusOrder(status string) int {
+	return map[string]int{StatusHave: 0, StatusMiss: 1, StatusBad: 2, StatusUnknown: 3}[status]
+}
+
+// commonPath returns the single path of paths, or the directory or archive
+// that holds them all, e.g. the folder of a multi-track disc.
+func commonPath(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	prefix := paths[0]
+	for _, p := range paths[1:] {
+		for !strings.HasPrefix(p, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(paths) > 1 {
+		if i := strings.LastIndexAny(prefix, `/\`); i > 0 {
+			prefix = prefix[:i]
+		}
+	}
+	return prefix
+}
+
+func fileCRC(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := crc32.NewIEEE()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashing %s: %w", p, err)
+	}
+	return fmt.Sprintf("%08x", h.Sum32()), nil
+}
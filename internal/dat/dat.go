@@ -0,0 +1,250 @@
+// Package dat reads Logiqx XML DAT files, as published by No-Intro, Redump
+// and TOSEC, and audits local folders against them.
+package dat
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrNoDAT is returned for a ZIP archive without a .dat or .xml file.
+var ErrNoDAT = errors.New("no .dat or .xml file in archive")
+
+// Datafile is a parsed DAT.
+type Datafile struct {
+	Header Header `json:"header"`
+	Games  []Game `json:"games"`
+}
+
+// Header describes a DAT, e.g. Name "Nintendo - Game Boy Advance" and
+// Version "20240101-000000".
+type Header struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Homepage    string `json:"homepage,omitempty"`
+}
+
+// Game is a DAT entry: one release and the ROMs or tracks it consists of.
+type Game struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Serial is the game's product code, e.g. "SLUS-00594", taken from a
+	// <serial> element or the first ROM's serial attribute.
+	Serial string `json:"serial,omitempty"`
+	ROMs   []ROM  `json:"roms"`
+}
+
+// ROM is one file of a game. Checksums are lowercase hex; any may be empty.
+type ROM struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	CRC    string `json:"crc,omitempty"`
+	MD5    string `json:"md5,omitempty"`
+	SHA1   string `json:"sha1,omitempty"`
+	Serial string `json:"serial,omitempty"`
+	// Status is "baddump", "nodump" or "verified" when the DAT says so.
+	Status string `json:"status,omitempty"`
+}
+
+// Load reads the DAT at p, which may be a ZIP archive holding it as
+// distributed by No-Intro and Redump. An archive with several DATs yields
+// one Datafile per DAT.
+func Load(p string) ([]*Datafile, error) {
+	if !strings.EqualFold(path.Ext(p), ".zip") {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		d, err := Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		return []*Datafile{d}, nil
+	}
+
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var dats []*Datafile
+	for _, zf := range zr.File {
+		ext := strings.ToLower(path.Ext(zf.Name))
+		if zf.FileInfo().IsDir() || (ext != ".dat" && ext != ".xml") {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		d, err := Parse(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", p, zf.Name, err)
+		}
+		dats = append(dats, d)
+	}
+	if len(dats) == 0 {
+		return nil, fmt.Errorf("%s: %w", p, ErrNoDAT)
+	}
+	return dats, nil
+}
+
+type xmlHeader struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description"`
+	Version     string `xml:"version"`
+	Author      string `xml:"author"`
+	Homepage    string `xml:"homepage"`
+}
+
+type xmlGame struct {
+	Name        string   `xml:"name,attr"`
+	Description string   `xml:"description"`
+	Serial      string   `xml:"serial"`
+	ROMs        []xmlROM `xml:"rom"`
+}
+
+type xmlROM struct {
+	Name   string `xml:"name,attr"`
+	Size   string `xml:"size,attr"`
+	CRC    string `xml:"crc,attr"`
+	MD5    string `xml:"md5,attr"`
+	SHA1   string `xml:"sha1,attr"`
+	Serial string `xml:"serial,attr"`
+	Status string `xml:"status,attr"`
+}
+
+// Parse reads a Logiqx XML DAT. Games may be <game> or <machine> elements.
+func Parse(r io.Reader) (*Datafile, error) {
+	dec := xml.NewDecoder(r)
+	// DATs declare UTF-8 or plain ASCII; anything else is read as is.
+	dec.CharsetReader = func(_ string, in io.Reader) (io.Reader, error) { return in, nil }
+	d := &Datafile{}
+	root := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing DAT: %w", err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch se.Name.Local {
+		case "datafile":
+			root = true
+		case "header":
+			var h xmlHeader
+			if err := dec.DecodeElement(&h, &se); err != nil {
+				return nil, fmt.Errorf("parsing DAT header: %w", err)
+			}
+			d.Header = Header{
+				Name:        strings.TrimSpace(h.Name),
+				Description: strings.TrimSpace(h.Description),
+				Version:     strings.TrimSpace(h.Version),
+				Author:      strings.TrimSpace(h.Author),
+				Homepage:    strings.TrimSpace(h.Homepage),
+			}
+		case "game", "machine":
+			var g xmlGame
+			if err := dec.DecodeElement(&g, &se); err != nil {
+				return nil, fmt.Errorf("parsing DAT entry: %w", err)
+			}
+			d.Games = append(d.Games, g.game())
+		}
+	}
+	if !root {
+		return nil, errors.New("not a Logiqx XML DAT (no <datafile> element)")
+	}
+	if d.Header.Name == "" {
+		d.Header.Name = d.Header.Description
+	}
+	if d.Header.Name == "" {
+		return nil, errors.New("DAT header has no name")
+	}
+	return d, nil
+}
+
+func (g xmlGame) game() Game {
+	out := Game{
+		Name:        g.Name,
+		Description: strings.TrimSpace(g.Description),
+		Serial:      strings.TrimSpace(g.Serial),
+		ROMs:        make([]ROM, 0, len(g.ROMs)),
+	}
+	for _, r := range g.ROMs {
+		size, _ := strconv.ParseInt(r.Size, 10, 64)
+		out.ROMs = append(out.ROMs, ROM{
+			Name:   r.Name,
+			Size:   size,
+			CRC:    normalizeHash(r.CRC, 8),
+			MD5:    normalizeHash(r.MD5, 32),
+			SHA1:   normalizeHash(r.SHA1, 40),
+			Serial: strings.TrimSpace(r.Serial),
+			Status: r.Status,
+		})
+		if out.Serial == "" {
+			out.Serial = strings.TrimSpace(r.Serial)
+		}
+	}
+	return out
+}
+
+// normalizeHash lowercases a hex checksum and pads it to n digits, since
+// some DATs drop leading zeros from CRCs. Malformed values are dropped.
+func normalizeHash(s string, n int) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || len(s) > n {
+		return ""
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return ""
+		}
+	}
+	return strings.Repeat("0", n-len(s)) + s
+}
+
+// NormalizeHash checks a user-supplied checksum of the given kind ("crc",
+// "md5" or "sha1") and returns it as stored for DAT ROMs.
+func NormalizeHash(kind, s string) (string, error) {
+	n := map[string]int{"crc": 8, "md5": 32, "sha1": 40}[kind]
+	if n == 0 {
+		return "", fmt.Errorf("unknown checksum kind %q", kind)
+	}
+	s = strings.TrimSpace(s)
+	if len(s) > 2 && strings.EqualFold(s[:2], "0x") {
+		s = s[2:]
+	}
+	h := normalizeHash(s, n)
+	if h == "" || (kind != "crc" && len(strings.TrimSpace(s)) != n) {
+		return "", fmt.Errorf("invalid %s checksum %q", strings.ToUpper(kind), s)
+	}
+	return h, nil
+}
+
+// NormalizeSerial returns a serial in the form serials are compared in:
+// upper case letters and digits only, e.g. "SLUS00594" for "slus-00594".
+func NormalizeSerial(s string) string {
+	var sb strings.Builder
+	for _, c := range strings.ToUpper(s) {
+		if (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,189 @@
+package dat
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testDAT = `<?xml version="1.0"?>
+<!DOCTYPE datafile PUBLIC "-//Logiqx//DTD ROM Management Datafile//EN" "http://www.logiqx.com/dtds/datafile.dtd">
+<datafile>
+	<header>
+		<name>Test - System</name>
+		<version>20240101</version>
+	</header>
+	<game name="Alpha (USA)">
+		<description>Alpha (USA)</description>
+		<rom name="Alpha (USA).bin" size="2" crc="46EA081F" serial="AAAE"/>
+	</game>
+	<machine name="Beta (Europe)">
+		<serial>SLES-00001</serial>
+		<rom name="Beta (Europe) (Track 1).bin" size="4" crc="3a1c8a3"/>
+		<rom name="Beta (Europe) (Track 2).bin" size="4" crc="bogus!"/>
+	</machine>
+</datafile>
+`
+
+func TestParse(t *testing.T) {
+	d, err := Parse(strings.NewReader(testDAT))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Header.Name != "Test - System" || d.Header.Version != "20240101" || len(d.Games) != 2 {
+		t.Fatalf("parsed %+v", d)
+	}
+	alpha, beta := d.Games[0], d.Games[1]
+	if alpha.Serial != "AAAE" || alpha.ROMs[0].CRC != "46ea081f" || alpha.ROMs[0].Size != 2 {
+		t.Errorf("alpha = %+v", alpha)
+	}
+	if beta.Serial != "SLES-00001" || len(beta.ROMs) != 2 || beta.ROMs[0].CRC != "03a1c8a3" || beta.ROMs[1].CRC != "" {
+		t.Errorf("beta = %+v", beta)
+	}
+
+	if _, err := Parse(strings.NewReader("<html></html>")); err == nil {
+		t.Error("Parse accepted a file without <datafile>")
+	}
+}
+
+func TestAudit(t *testing.T) {
+	d, err := Parse(strings.NewReader(testDAT))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := t.TempDir()
+	zf, err := os.Create(filepath.Join(root, "Alpha (USA).zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zf)
+	w, _ := zw.Create("Alpha (USA).bin")
+	w.Write([]byte("x\n"))
+	zw.Close()
+	zf.Close()
+	for name, data := range map[string]string{
+		"Beta (Europe) (Track 1).bin": "abcd",
+		"other.txt":                   "?",
+		".hidden":                     "x\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rep, err := NewAuditor([]*Datafile{d}).Audit(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, row := range rep.Rows {
+		got[row.Status+" "+row.Game] = row.Path
+	}
+	want := map[string]string{
+		"have Alpha (USA)":   filepath.Join(root, "Alpha (USA).zip") + "/Alpha (USA).bin",
+		"miss Beta (Europe)": "",
+		"bad Beta (Europe)":  filepath.Join(root, "Beta (Europe) (Track 1).bin"),
+		"unknown ":           filepath.Join(root, "other.txt"),
+	}
+	if len(got) != len(want) {
+		t.Errorf("rows = %+v", rep.Rows)
+	}
+	for k, v := range want {
+		if p, ok := got[k]; !ok || p != v {
+			t.Errorf("%s: got %q (present %v), want %q", k, p, ok, v)
+		}
+	}
+	if len(rep.DATs) != 1 || rep.DATs[0].Have != 1 || rep.DATs[0].Miss != 1 || rep.Bad != 1 || rep.Unknown != 1 {
+		t.Errorf("summary = %+v bad %d unknown %d", rep.DATs, rep.Bad, rep.Unknown)
+	}
+
+	only := rep.Filter(map[string]bool{StatusMiss: true})
+	if len(only.Rows) != 1 || only.Rows[0].Game != "Beta (Europe)" {
+		t.Errorf("filtered rows = %+v", only.Rows)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		kind, in, want string
+		ok             bool
+	}{
+		{"crc", "1A2B3C4D", "1a2b3c4d", true},
+		{"crc", "0x3a1c8a3", "03a1c8a3", true},
+		{"crc", "xyz", "", false},
+		{"md5", "401B30E3B8B5D629635A5C613CDB7919", "401b30e3b8b5d629635a5c613cdb7919", true},
+		{"sha1", "1234", "", false},
+	} {
+		got, err := NormalizeHash(tc.kind, tc.in)
+		if got != tc.want || (err == nil) != tc.ok {
+			t.Errorf("NormalizeHash(%q, %q) = %q, %v", tc.kind, tc.in, got, err)
+		}
+	}
+	if got := NormalizeSerial(" slus-00594 "); got != "SLUS00594" {
+		t.Errorf("NormalizeSerial = %q", got)
+	}
+}
+
+func TestDB_ImportAndFind(t *testing.T) {
+	d, err := Parse(strings.NewReader(testDAT))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open(filepath.Join(t.TempDir(), "dats.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// A second import replaces the first.
+	for i := 0; i < 2; i++ {
+		info, err := db.Import(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Name != "Test - System" || info.Games != 2 || info.ROMs != 3 {
+			t.Fatalf("import %d = %+v", i, info)
+		}
+	}
+	if infos, err := db.List(); err != nil || len(infos) != 1 {
+		t.Fatalf("List = %+v, %v", infos, err)
+	}
+
+	loaded, err := db.Load([]string{"system"})
+	if err != nil || len(loaded) != 1 || len(loaded[0].Games) != 2 || len(loaded[0].Games[1].ROMs) != 2 {
+		t.Fatalf("Load = %+v, %v", loaded, err)
+	}
+	if _, err := db.Load([]string{"Arcade"}); err == nil {
+		t.Error("Load accepted an unknown DAT")
+	}
+
+	for _, tc := range []struct {
+		kind, value, game string
+	}{
+		{"crc", "03a1c8a3", "Beta (Europe)"},
+		{"serial", "sles00001", "Beta (Europe)"},
+		{"serial", "AAA-E", "Alpha (USA)"},
+	} {
+		got, err := db.FindROMs(tc.kind, tc.value)
+		if err != nil || len(got) == 0 || got[0].Game != tc.game || got[0].DAT != "Test - System" {
+			t.Errorf("FindROMs(%q, %q) = %+v, %v", tc.kind, tc.value, got, err)
+		}
+	}
+
+	// A game's serials may be a list; each matches all of its ROMs.
+	if _, err := db.Import(&Datafile{Header: Header{Name: "Other"}, Games: []Game{{
+		Name:   "Gamma (USA)",
+		Serial: "SLUS-00594, SLUS-00595",
+		ROMs:   []ROM{{Name: "Gamma (Track 1).bin"}, {Name: "Gamma (Track 2).bin", Serial: "SLUS-00595"}},
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := db.FindROMs("serial", "slus 00595"); err != nil || len(got) != 2 || got[1].ROM.Name != "Gamma (Track 2).bin" {
+		t.Errorf("FindROMs(serial) = %+v, %v", got, err)
+	}
+	if got, err := db.FindROMs("serial", "SLUS"); err != nil || len(got) != 0 {
+		t.Errorf("partial serial matched %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,415 @@
+package dat
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// DB stores imported DATs.
+type DB struct {
+	db *sql.DB
+}
+
+// Info summarizes an imported DAT.
+type Info struct {
+	ID          int64
+	Name        string
+	Description string
+	Version     string
+	ImportedAt  time.Time
+	Games       int
+	ROMs        int
+}
+
+// Open opens or creates the DAT database at the given path.
+func Open(dbPath string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return nil, fmt.Errorf("creating db directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=journal_mode(wal)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("opening DAT database: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating DAT database: %w", err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS dats (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT DEFAULT '',
+		version TEXT DEFAULT '',
+		author TEXT DEFAULT '',
+		homepage TEXT DEFAULT '',
+		imported_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS games (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		dat_id INTEGER NOT NULL REFERENCES dats(id),
+		name TEXT NOT NULL,
+		description TEXT DEFAULT '',
+		serial TEXT DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_games_dat ON games(dat_id);
+
+	CREATE TABLE IF NOT EXISTS roms (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		game_id INTEGER NOT NULL REFERENCES games(id),
+		name TEXT NOT NULL,
+		size INTEGER DEFAULT 0,
+		crc TEXT DEFAULT '',
+		md5 TEXT DEFAULT '',
+		sha1 TEXT DEFAULT '',
+		serial TEXT DEFAULT '',
+		status TEXT DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_roms_game ON roms(game_id);
+	CREATE INDEX IF NOT EXISTS idx_roms_crc ON roms(crc);
+	CREATE INDEX IF NOT EXISTS idx_roms_md5 ON roms(md5);
+	CREATE INDEX IF NOT EXISTS idx_roms_sha1 ON roms(sha1);
+
+	-- serials holds every serial of a game (rom_id NULL) or ROM, normalized
+	-- like NormalizeSerial, one per row.
+	CREATE TABLE IF NOT EXISTS serials (
+		serial TEXT NOT NULL,
+		game_id INTEGER NOT NULL REFERENCES games(id),
+		rom_id INTEGER REFERENCES roms(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_serials_serial ON serials(serial);
+	CREATE INDEX IF NOT EXISTS idx_serials_game ON serials(game_id);
+	`)
+	return err
+}
+
+// Import stores df, replacing an earlier import of a DAT with the same
+// name, and returns its summary.
+func (d *DB) Import(df *Datafile) (Info, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return Info{}, err
+	}
+	defer tx.Rollback()
+
+	if err := deleteDAT(tx, df.Header.Name); err != nil {
+		return Info{}, err
+	}
+	h := df.Header
+	res, err := tx.Exec(
+		`INSERT INTO dats (name, description, version, author, homepage, imported_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		h.Name, h.Description, h.Version, h.Author, h.Homepage, time.Now(),
+	)
+	if err != nil {
+		return Info{}, err
+	}
+	datID, err := res.LastInsertId()
+	if err != nil {
+		return Info{}, err
+	}
+
+	gameStmt, err := tx.Prepare(`INSERT INTO games (dat_id, name, description, serial) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return Info{}, err
+	}
+	defer gameStmt.Close()
+	romStmt, err := tx.Prepare(
+		`INSERT INTO roms (game_id, name, size, crc, md5, sha1, serial, status)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return Info{}, err
+	}
+	defer romStmt.Close()
+	serialStmt, err := tx.Prepare(`INSERT INTO serials (serial, game_id, rom_id) VALUES (?, ?, ?)`)
+	if err != nil {
+		return Info{}, err
+	}
+	defer serialStmt.Close()
+
+	for _, g := range df.Games {
+		res, err := gameStmt.Exec(datID, g.Name, g.Description, g.Serial)
+		if err != nil {
+			return Info{}, err
+		}
+		gameID, err := res.LastInsertId()
+		if err != nil {
+			return Info{}, err
+		}
+		for _, serial := range serialKeys(g.Serial) {
+			if _, err := serialStmt.Exec(serial, gameID, nil); err != nil {
+				return Info{}, err
+			}
+		}
+		for _, r := range g.ROMs {
+			res, err := romStmt.Exec(gameID, r.Name, r.Size, r.CRC, r.MD5, r.SHA1, r.Serial, r.Status)
+			if err != nil {
+				return Info{}, err
+			}
+			if r.Serial == "" {
+				continue
+			}
+			romID, err := res.LastInsertId()
+			if err != nil {
+				return Info{}, err
+			}
+			for _, serial := range serialKeys(r.Serial) {
+				if _, err := serialStmt.Exec(serial, gameID, romID); err != nil {
+					return Info{}, err
+				}
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Info{}, err
+	}
+
+	infos, err := d.infos(`WHERE d.id = ?`, datID)
+	if err != nil || len(infos) == 0 {
+		return Info{}, err
+	}
+	return infos[0], nil
+}
+
+func deleteDAT(tx *sql.Tx, name string) error {
+	var id int64
+	err := tx.QueryRow(`SELECT id FROM dats WHERE name = ?`, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, q := range []string{
+		`DELETE FROM serials WHERE game_id IN (SELECT id FROM games WHERE dat_id = ?)`,
+		`DELETE FROM roms WHERE game_id IN (SELECT id FROM games WHERE dat_id = ?)`,
+		`DELETE FROM games WHERE dat_id = ?`,
+		`DELETE FROM dats WHERE id = ?`,
+	} {
+		if _, err := tx.Exec(q, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns the imported DATs ordered by name.
+func (d *DB) List() ([]Info, error) {
+	return d.infos("")
+}
+
+func (d *DB) infos(where string, args ...any) ([]Info, error) {
+	rows, err := d.db.Query(`
+		SELECT d.id, d.name, d.description, d.version, d.imported_at,
+			(SELECT COUNT(*) FROM games g WHERE g.dat_id = d.id),
+			(SELECT COUNT(*) FROM roms r JOIN games g ON g.id = r.game_id WHERE g.dat_id = d.id)
+		FROM dats d `+where+`
+		ORDER BY d.name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var infos []Info
+	for rows.Next() {
+		var i Info
+		if err := rows.Scan(&i.ID, &i.Name, &i.Description, &i.Version, &i.ImportedAt, &i.Games, &i.ROMs); err != nil {
+			return nil, err
+		}
+		infos = append(infos, i)
+	}
+	return infos, rows.Err()
+}
+
+// GameNames returns the names of the games in the imported DAT with the
+// given ID.
+func (d *DB) GameNames(id int64) ([]string, error) {
+	rows, err := d.db.Query(`SELECT name FROM games WHERE dat_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// Load returns the imported DATs with the given names, or all of them when
+// names is empty. A name may also be a unique part of a DAT name, e.g.
+// "Game Boy Advance".
+func (d *DB) Load(names []string) ([]*Datafile, error) {
+	infos, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+	selected := infos
+	if len(names) > 0 {
+		selected = nil
+		for _, name := range names {
+			info, err := pickDAT(infos, name)
+			if err != nil {
+				return nil, err
+			}
+			selected = append(selected, info)
+		}
+	}
+
+	var dats []*Datafile
+	for _, info := range selected {
+		df, err := d.load(info)
+		if err != nil {
+			return nil, fmt.Errorf("loading DAT %s: %w", info.Name, err)
+		}
+		dats = append(dats, df)
+	}
+	return dats, nil
+}
+
+func pickDAT(infos []Info, name string) (Info, error) {
+	var matches []Info
+	for _, info := range infos {
+		if strings.EqualFold(info.Name, name) {
+			return info, nil
+		}
+		if strings.Contains(strings.ToLower(info.Name), strings.ToLower(name)) {
+			matches = append(matches, info)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Info{}, fmt.Errorf("no imported DAT named %q", name)
+	case 1:
+		return matches[0], nil
+	}
+	var names []string
+	for _, m := range matches {
+		names = append(names, m.Name)
+	}
+	return Info{}, fmt.Errorf("%q matches several DATs: %s", name, strings.Join(names, "; "))
+}
+
+func (d *DB) load(info Info) (*Datafile, error) {
+	df := &Datafile{Header: Header{Name: info.Name, Description: info.Description, Version: info.Version}}
+	rows, err := d.db.Query(`
+		SELECT g.id, g.name, g.description, g.serial, r.name, r.size, r.crc, r.md5, r.sha1, r.serial, r.status
+		FROM games g
+		JOIN roms r ON r.game_id = g.id
+		WHERE g.dat_id = ?
+		ORDER BY g.id, r.id`, info.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lastID int64
+	for rows.Next() {
+		var id int64
+		var g Game
+		var r ROM
+		if err := rows.Scan(&id, &g.Name, &g.Description, &g.Serial, &r.Name, &r.Size, &r.CRC, &r.MD5, &r.SHA1, &r.Serial, &r.Status); err != nil {
+			return nil, err
+		}
+		if id != lastID || len(df.Games) == 0 {
+			df.Games = append(df.Games, g)
+			lastID = id
+		}
+		last := &df.Games[len(df.Games)-1]
+		last.ROMs = append(last.ROMs, r)
+	}
+	return df, rows.Err()
+}
+
+// ROMMatch is a DAT ROM with the game and DAT it belongs to.
+type ROMMatch struct {
+	DAT  string
+	Game string
+	// Serial is the game's serial.
+	Serial string
+	ROM    ROM
+}
+
+// FindROMs returns the DAT ROMs whose checksum of the given kind ("crc",
+// "md5" or "sha1") equals value, which must be normalized lowercase hex, or
+// with kind "serial" the ROMs whose own or whose game's serial is value.
+// Serials are compared as NormalizeSerial returns them, and may be listed
+// comma-separated in the DAT.
+func (d *DB) FindROMs(kind, value string) ([]ROMMatch, error) {
+	var from string
+	switch kind {
+	case "crc", "md5", "sha1":
+		from = `roms r
+		JOIN games g ON g.id = r.game_id
+		JOIN dats d ON d.id = g.dat_id
+		WHERE r.` + kind + ` = ?`
+	case "serial":
+		value = NormalizeSerial(value)
+		if value == "" {
+			return nil, nil
+		}
+		// A game's serial matches all of its ROMs.
+		from = `serials s
+		JOIN roms r ON r.id = s.rom_id OR (s.rom_id IS NULL AND r.game_id = s.game_id)
+		JOIN games g ON g.id = r.game_id
+		JOIN dats d ON d.id = g.dat_id
+		WHERE s.serial = ?`
+	default:
+		return nil, fmt.Errorf("unknown ROM lookup %q", kind)
+	}
+	rows, err := d.db.Query(`
+		SELECT DISTINCT d.name, g.name, g.serial, r.id, r.name, r.size, r.crc, r.md5, r.sha1, r.serial, r.status
+		FROM `+from+`
+		ORDER BY d.name, g.name, r.id`, value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []ROMMatch
+	for rows.Next() {
+		var m ROMMatch
+		var id int64
+		if err := rows.Scan(&m.DAT, &m.Game, &m.Serial, &id, &m.ROM.Name, &m.ROM.Size, &m.ROM.CRC, &m.ROM.MD5, &m.ROM.SHA1, &m.ROM.Serial, &m.ROM.Status); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// serialKeys splits a comma-separated serial list such as
+// "SLUS-00594, SLUS-00595" into normalized serials.
+func serialKeys(s string) []string {
+	var keys []string
+	for _, part := range strings.Split(s, ",") {
+		if key := NormalizeSerial(part); key != "" && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
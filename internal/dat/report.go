@@ -0,0 +1,67 @@
+package dat
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Filter returns the report with only the rows whose status is in
+// statuses; an empty set keeps every row.
+func (r *Report) Filter(statuses map[string]bool) *Report {
+	if len(statuses) == 0 {
+		return r
+	}
+	out := *r
+	out.Rows = nil
+	for _, row := range r.Rows {
+		if statuses[row.Status] {
+			out.Rows = append(out.Rows, row)
+		}
+	}
+	return &out
+}
+
+// WriteText writes the report as a table followed by a summary per DAT.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tGAME\tPATH\tDETAIL")
+	for _, row := range r.Rows {
+		detail := row.Detail
+		if row.URL != "" {
+			if detail != "" {
+				detail += "; "
+			}
+			detail += row.URL
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", row.Status, orDash(row.Game), orDash(row.Path), detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	for _, d := range r.DATs {
+		fmt.Fprintf(w, "%s: have %d of %d games, %d missing\n", d.DAT, d.Have, d.Games, d.Miss)
+	}
+	_, err := fmt.Fprintf(w, "%d bad and %d unknown file(s) in %s\n", r.Bad, r.Unknown, r.Root)
+	return err
+}
+
+// WriteCSV writes the report rows as CSV with a header line.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"status", "dat", "game", "path", "detail", "url"})
+	for _, row := range r.Rows {
+		cw.Write([]string{row.Status, row.DAT, row.Game, row.Path, row.Detail, row.URL})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
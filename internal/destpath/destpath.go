@@ -0,0 +1,377 @@
+// Package destpath resolves where a remote file is stored locally using a
+// small template language, e.g. "{collection}/{system}/{title}/{name}".
+//
+// Tokens are written as {token} or {token|fallback}; the fallback is used
+// when the token resolves to an empty string. Path segments that end up
+// empty are dropped, so "{region}/{name}" simply yields "{name}" for files
+// without a region tag.
+package destpath
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultTemplate mirrors the remote directory layout below the download
+// directory.
+const DefaultTemplate = "{path}/{name}"
+
+// tokens lists every supported token with a short description, in the order
+// shown by Tokens.
+var tokens = []struct {
+	name string
+	help string
+}{
+	{"name", "file name as listed, e.g. Tetris (World) (Rev 1).zip"},
+	{"basename", "file name without extension"},
+	{"ext", "file extension without the dot"},
+	{"title", "file name up to the first (...) or [...] tag"},
+	{"letter", "first letter of the title, or # for digits and symbols"},
+	{"region", "first region tag, e.g. USA"},
+	{"regions", "all region tags joined with \", \""},
+	{"language", "first language tag, e.g. En"},
+	{"languages", "all language tags joined with \",\""},
+	{"tags", "all remaining (...) tags joined with \" \""},
+	{"collection", "first remote directory, e.g. No-Intro"},
+	{"system", "second remote directory, e.g. Nintendo - Game Boy"},
+	{"dir", "remote directory containing the file"},
+	{"path", "full remote directory path"},
+}
+
+// Tokens returns the supported token names with a description of each.
+func Tokens() [][2]string {
+	out := make([][2]string, 0, len(tokens))
+	for _, t := range tokens {
+		out = append(out, [2]string{t.name, t.help})
+	}
+	return out
+}
+
+func knownToken(name string) bool {
+	for _, t := range tokens {
+		if t.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type part struct {
+	literal  string
+	token    string
+	fallback string
+}
+
+// Template is a parsed destination path template.
+type Template struct {
+	raw      string
+	segments [][]part
+}
+
+// Parse validates and compiles a template. An empty string yields the
+// default template.
+func Parse(raw string) (*Template, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		raw = DefaultTemplate
+	}
+	if strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "\\") {
+		return nil, fmt.Errorf("template %q must be relative to the download directory", raw)
+	}
+
+	t := &Template{raw: raw}
+	for _, seg := range strings.Split(strings.ReplaceAll(raw, "\\", "/"), "/") {
+		if seg == "" {
+			continue
+		}
+		if seg == "." || seg == ".." {
+			return nil, fmt.Errorf("template %q must not contain %q", raw, seg)
+		}
+		parts, err := parseSegment(seg)
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %w", raw, err)
+		}
+		t.segments = append(t.segments, parts)
+	}
+	if len(t.segments) == 0 {
+		return nil, fmt.Errorf("template %q is empty", raw)
+	}
+	return t, nil
+}
+
+func parseSegment(seg string) ([]part, error) {
+	var parts []part
+	for seg != "" {
+		open := strings.IndexAny(seg, "{}")
+		if open < 0 {
+			parts = append(parts, part{literal: seg})
+			break
+		}
+		if seg[open] == '}' {
+			return nil, fmt.Errorf("unexpected '}' in %q", seg)
+		}
+		if open > 0 {
+			parts = append(parts, part{literal: seg[:open]})
+		}
+		end := strings.IndexByte(seg[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated '{' in %q", seg)
+		}
+		body := seg[open+1 : open+end]
+		if strings.ContainsRune(body, '{') {
+			return nil, fmt.Errorf("nested '{' in %q", seg)
+		}
+		name, fallback, _ := strings.Cut(body, "|")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !knownToken(name) {
+			return nil, fmt.Errorf("unknown token {%s}", name)
+		}
+		parts = append(parts, part{token: name, fallback: fallback})
+		seg = seg[open+end+1:]
+	}
+	return parts, nil
+}
+
+// String returns the template source.
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Resolve expands the template for a file at remotePath (relative to the
+// archive root, e.g. "No-Intro/Nintendo - Game Boy/Tetris (World).zip") and
+// returns the local subdirectory and file name, both slash-separated.
+// If the template yields no file name, the original name is used.
+func (t *Template) Resolve(remotePath string) (subdir, name string) {
+	vars := varsFor(remotePath)
+	var segs []string
+	for _, seg := range t.segments {
+		var sb strings.Builder
+		for _, p := range seg {
+			if p.token == "" {
+				sb.WriteString(p.literal)
+				continue
+			}
+			v := vars[p.token]
+			if v == "" {
+				v = p.fallback
+			}
+			if p.token == "path" {
+				// {path} may span several directories; keep its separators.
+				sb.WriteString(v)
+				continue
+			}
+			sb.WriteString(cleanSegment(v))
+		}
+		for _, s := range strings.Split(sb.String(), "/") {
+			if s = cleanSegment(s); s != "" {
+				segs = append(segs, s)
+			}
+		}
+	}
+	if len(segs) == 0 {
+		return "", cleanSegment(vars["name"])
+	}
+	return strings.Join(segs[:len(segs)-1], "/"), segs[len(segs)-1]
+}
+
+// cleanSegment makes a single path component safe to join: separators are
+// replaced and "." / ".." are discarded.
+func cleanSegment(s string) string {
+	s = strings.NewReplacer("/", "-", "\\", "-").Replace(s)
+	s = strings.TrimSpace(s)
+	if s == "." || s == ".." {
+		return ""
+	}
+	return s
+}
+
+// RemotePath converts a file URL into a path relative to baseURL, decoding
+// percent-escapes. URLs outside baseURL yield just the decoded file name.
+func RemotePath(baseURL, fileURL string) string {
+	base := strings.TrimRight(baseURL, "/") + "/"
+	rel := fileURL
+	if strings.HasPrefix(fileURL, base) {
+		rel = strings.TrimPrefix(fileURL, base)
+	} else if u, err := url.Parse(fileURL); err == nil {
+		rel = path.Base(u.Path)
+	}
+	if decoded, err := url.PathUnescape(rel); err == nil {
+		rel = decoded
+	}
+	return strings.Trim(rel, "/")
+}
+
+func varsFor(remotePath string) map[string]string {
+	remotePath = strings.Trim(remotePath, "/")
+	dir, file := path.Split(remotePath)
+	dir = strings.Trim(dir, "/")
+	info := ParseName(file)
+
+	vars := map[string]string{
+		"name":      file,
+		"basename":  info.Basename,
+		"ext":       info.Ext,
+		"title":     info.Title,
+		"letter":    info.Letter(),
+		"regions":   strings.Join(info.Regions, ", "),
+		"languages": strings.Join(info.Languages, ","),
+		"tags":      strings.Join(info.Tags, " "),
+		"path":      dir,
+	}
+	if len(info.Regions) > 0 {
+		vars["region"] = info.Regions[0]
+	}
+	if len(info.Languages) > 0 {
+		vars["language"] = info.Languages[0]
+	}
+	if dir != "" {
+		dirs := strings.Split(dir, "/")
+		vars["collection"] = dirs[0]
+		if len(dirs) > 1 {
+			vars["system"] = dirs[1]
+		}
+		vars["dir"] = dirs[len(dirs)-1]
+	}
+	return vars
+}
+
+// NameInfo holds the parts of a No-Intro/Redump style file name.
+type NameInfo struct {
+	Basename  string
+	Ext       string
+	Title     string
+	Regions   []string
+	Languages []string
+	// Tags holds every other parenthesised tag, without parentheses.
+	Tags []string
+}
+
+// Letter returns the upper-cased first letter of the title, or "#" when the
+// title starts with anything else.
+func (n NameInfo) Letter() string {
+	for _, r := range n.Title {
+		if r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		if r >= 'A' && r <= 'Z' {
+			return string(r)
+		}
+		return "#"
+	}
+	return ""
+}
+
+var regionNames = map[string]string{}
+
+func init() {
+	for _, r := range []string{
+		"World", "USA", "Europe", "Japan", "Asia", "Australia", "Brazil",
+		"Canada", "China", "Denmark", "Finland", "France", "Germany",
+		"Greece", "Hong Kong", "India", "Ireland", "Israel", "Italy", "Korea",
+		"Latin America", "Mexico", "Netherlands", "New Zealand", "Norway",
+		"Poland", "Portugal", "Russia", "Scandinavia", "South Africa", "Spain",
+		"Sweden", "Switzerland", "Taiwan", "Turkey", "UK", "United Kingdom",
+		"Unknown",
+	} {
+		regionNames[strings.ToLower(r)] = r
+	}
+}
+
+// ParseName splits a file name into title, region, language and other tags.
+func ParseName(name string) NameInfo {
+	info := NameInfo{Basename: name}
+	if i := strings.LastIndexByte(name, '.'); i > 0 && !strings.ContainsAny(name[i:], " )]") {
+		info.Basename = name[:i]
+		info.Ext = name[i+1:]
+	}
+
+	base := info.Basename
+	cut := len(base)
+	if i := strings.IndexAny(base, "(["); i >= 0 {
+		cut = i
+	}
+	info.Title = strings.TrimSpace(base[:cut])
+
+	rest := base[cut:]
+	for {
+		open := strings.IndexByte(rest, '(')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[open:], ')')
+		if end < 0 {
+			break
+		}
+		tag := strings.TrimSpace(rest[open+1 : open+end])
+		rest = rest[open+end+1:]
+		if tag == "" {
+			continue
+		}
+		if regions, ok := parseRegions(tag); ok && len(info.Regions) == 0 {
+			info.Regions = regions
+			continue
+		}
+		if langs, ok := parseLanguages(tag); ok && len(info.Languages) == 0 {
+			info.Languages = langs
+			continue
+		}
+		info.Tags = append(info.Tags, tag)
+	}
+	return info
+}
+
+func parseRegions(tag string) ([]string, bool) {
+	var out []string
+	for _, p := range strings.Split(tag, ",") {
+		r, ok := regionNames[strings.ToLower(strings.TrimSpace(p))]
+		if !ok {
+			return nil, false
+		}
+		out = append(out, r)
+	}
+	return out, true
+}
+
+// parseLanguages accepts tags like "En", "En,Fr,De" or "En+Ja" and
+// "Pt-BR" style variants.
+func parseLanguages(tag string) ([]string, bool) {
+	fields := strings.FieldsFunc(tag, func(r rune) bool { return r == ',' || r == '+' })
+	if len(fields) == 0 {
+		return nil, false
+	}
+	var out []string
+	seen := map[string]bool{}
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		code, variant, hasVariant := strings.Cut(f, "-")
+		if !isLanguageCode(code) || (hasVariant && !isUpperAlpha(variant)) {
+			return nil, false
+		}
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	return out, true
+}
+
+func isLanguageCode(s string) bool {
+	if len(s) != 2 {
+		return false
+	}
+	return s[0] >= 'A' && s[0] <= 'Z' && s[1] >= 'a' && s[1] <= 'z'
+}
+
+func isUpperAlpha(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package destpath
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseName(t *testing.T) {
+	got := ParseName("Pokemon - Black Version (USA, Europe) (En,Fr,De) (Rev 1) [b].zip")
+	want := NameInfo{
+		Basename:  "Pokemon - Black Version (USA, Europe) (En,Fr,De) (Rev 1) [b]",
+		Ext:       "zip",
+		Title:     "Pokemon - Black Version",
+		Regions:   []string{"USA", "Europe"},
+		Languages: []string{"En", "Fr", "De"},
+		Tags:      []string{"Rev 1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseName = %+v, want %+v", got, want)
+	}
+	if got.Letter() != "P" {
+		t.Fatalf("Letter = %q", got.Letter())
+	}
+	if l := ParseName("1942 (Japan).zip").Letter(); l != "#" {
+		t.Fatalf("Letter for digit title = %q", l)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	remote := "No-Intro/Nintendo - Game Boy/Tetris (World) (Rev 1).zip"
+	tests := []struct {
+		tmpl       string
+		wantSubdir string
+		wantName   string
+	}{
+		{"", "No-Intro/Nintendo - Game Boy", "Tetris (World) (Rev 1).zip"},
+		{"{name}", "", "Tetris (World) (Rev 1).zip"},
+		{"{collection}/{system}/{title}/{name}", "No-Intro/Nintendo - Game Boy/Tetris", "Tetris (World) (Rev 1).zip"},
+		{"{system}/{language}/{name}", "Nintendo - Game Boy", "Tetris (World) (Rev 1).zip"},
+		{"{system}/{language|Unknown}/{title}.{ext}", "Nintendo - Game Boy/Unknown", "Tetris.zip"},
+		{"{region}/{letter}/{basename}", "World/T", "Tetris (World) (Rev 1)"},
+		{"{dir}", "", "Nintendo - Game Boy"},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.tmpl)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.tmpl, err)
+		}
+		subdir, name := tmpl.Resolve(remote)
+		if subdir != tt.wantSubdir || name != tt.wantName {
+			t.Errorf("Resolve with %q = (%q, %q), want (%q, %q)", tt.tmpl, subdir, name, tt.wantSubdir, tt.wantName)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, raw := range []string{"/abs/{name}", "{nope}/{name}", "{name", "name}", "../{name}", "{{name}}"} {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestRemotePath(t *testing.T) {
+	got := RemotePath("https://myrient.erista.me/files/", "https://myrient.erista.me/files/No-Intro/Nintendo%20-%20Game%20Boy/Tetris%20%28World%29.zip")
+	if got != "No-Intro/Nintendo - Game Boy/Tetris (World).zip" {
+		t.Fatalf("RemotePath = %q", got)
+	}
+	if got := RemotePath("https://myrient.erista.me/files", "https://example.com/a/b%20c.zip"); got != "b c.zip" {
+		t.Fatalf("RemotePath outside base = %q", got)
+	}
+}
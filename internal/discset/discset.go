@@ -0,0 +1,139 @@
+// Package discset recognizes multi-disc releases such as Redump's
+// "Game (USA) (Disc 1).zip", "Game (USA) (Disc 2).zip" and writes M3U
+// playlists that let emulators swap between the discs.
+package discset
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// discTag matches "(Disc 1)", "(Disc 2 of 3)" and "(Disc A)" style tags.
+var discTag = regexp.MustCompile(`(?i)\s*\(Disc ([0-9]+|[A-Z])(?: of [0-9]+)?\)`)
+
+// Disc describes one file of a set.
+type Disc struct {
+	// Title is the file name without its disc tag and extension; every
+	// disc of a set shares it.
+	Title string
+	// Number is the disc number, counting lettered discs A=1, B=2, ...
+	Number int
+}
+
+// Parse reports whether name carries a disc tag and returns its set title
+// and disc number.
+func Parse(name string) (Disc, bool) {
+	loc := discTag.FindStringSubmatchIndex(name)
+	if loc == nil {
+		return Disc{}, false
+	}
+	label := name[loc[2]:loc[3]]
+	n, err := strconv.Atoi(label)
+	if err != nil {
+		n = int(strings.ToUpper(label)[0]-'A') + 1
+	}
+	title := name[:loc[0]] + name[loc[1]:]
+	if ext := path.Ext(title); ext != "" && !strings.ContainsAny(ext, " )]") {
+		title = strings.TrimSuffix(title, ext)
+	}
+	return Disc{Title: strings.Join(strings.Fields(title), " "), Number: n}, true
+}
+
+// Set is a multi-disc release found in a list of names.
+type Set struct {
+	Title string
+	// Indices point into the list the set was found in, ordered by disc.
+	Indices []int
+}
+
+// Sets groups the names that form multi-disc releases. A title with a
+// single disc on the list is not a set. Sets are ordered by title.
+func Sets(names []string) []Set {
+	type member struct{ index, number int }
+	byTitle := map[string][]member{}
+	for i, name := range names {
+		if d, ok := Parse(name); ok {
+			byTitle[d.Title] = append(byTitle[d.Title], member{i, d.Number})
+		}
+	}
+	var sets []Set
+	for title, members := range byTitle {
+		if len(members) < 2 {
+			continue
+		}
+		sort.SliceStable(members, func(a, b int) bool { return members[a].number < members[b].number })
+		set := Set{Title: title}
+		for _, mb := range members {
+			set.Indices = append(set.Indices, mb.index)
+		}
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(a, b int) bool { return sets[a].Title < sets[b].Title })
+	return sets
+}
+
+// Find returns the set names[i] belongs to.
+func Find(names []string, i int) (Set, bool) {
+	d, ok := Parse(names[i])
+	if !ok {
+		return Set{}, false
+	}
+	for _, set := range Sets(names) {
+		if set.Title == d.Title {
+			return set, true
+		}
+	}
+	return Set{}, false
+}
+
+// PlaylistName returns the file name of the playlist for a set.
+func PlaylistName(title string) string {
+	return title + ".m3u"
+}
+
+// imageExts lists the files a playlist can point at inside an extracted
+// disc, most preferred first: descriptor files that reference the track
+// data come before the raw images.
+var imageExts = []string{".cue", ".gdi", ".ccd", ".mds", ".chd", ".iso", ".cdi", ".img", ".bin"}
+
+// ImageFile returns the path, relative to dir, of the file that stands for
+// the disc extracted into dir, or "" when there is none.
+func ImageFile(dir string) string {
+	best, bestRank := "", len(imageExts)
+	filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(p))
+		for rank, want := range imageExts {
+			if ext == want && rank < bestRank {
+				rel, err := filepath.Rel(dir, p)
+				if err == nil {
+					best, bestRank = filepath.ToSlash(rel), rank
+				}
+			}
+		}
+		return nil
+	})
+	return best
+}
+
+// WritePlaylist writes an M3U playlist listing entries, which are paths
+// relative to the playlist's directory.
+func WritePlaylist(path string, entries []string) error {
+	var sb strings.Builder
+	for _, e := range entries {
+		sb.WriteString(filepath.ToSlash(e))
+		sb.WriteString("\n")
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,64 @@
+package discset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name   string
+		title  string
+		number int
+		ok     bool
+	}{
+		{"Final Fantasy VII (USA) (Disc 2).zip", "Final Fantasy VII (USA)", 2, true},
+		{"Riven (Europe) (Disc 3 of 5) (Rev 1).zip", "Riven (Europe) (Rev 1)", 3, true},
+		{"Game (Japan) (Disc B).chd", "Game (Japan)", 2, true},
+		{"Tetris (World).zip", "", 0, false},
+	}
+	for _, tc := range cases {
+		d, ok := Parse(tc.name)
+		if ok != tc.ok || d.Title != tc.title || d.Number != tc.number {
+			t.Errorf("Parse(%q) = %+v, %v; want %q disc %d", tc.name, d, ok, tc.title, tc.number)
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	names := []string{
+		"Alone (USA) (Disc 2).zip",
+		"Alone (USA) (Disc 1).zip",
+		"Single (USA) (Disc 1).zip",
+		"Alone (Europe) (Disc 1).zip",
+		"Alone (Europe) (Disc 2).zip",
+		"Other (USA).zip",
+	}
+	sets := Sets(names)
+	if len(sets) != 2 {
+		t.Fatalf("Sets = %+v, want 2 sets", sets)
+	}
+	if sets[1].Title != "Alone (USA)" || sets[1].Indices[0] != 1 || sets[1].Indices[1] != 0 {
+		t.Errorf("USA set = %+v, want discs ordered 1, 0", sets[1])
+	}
+	if _, ok := Find(names, 2); ok {
+		t.Error("a lone disc was treated as a set")
+	}
+	if set, ok := Find(names, 4); !ok || set.Title != "Alone (Europe)" {
+		t.Errorf("Find = %+v, %v", set, ok)
+	}
+}
+
+func TestImageFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"Game (Track 01).bin", "Game (Track 02).bin", "Game.cue"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0o644)
+	}
+	if got := ImageFile(dir); got != "Game.cue" {
+		t.Errorf("ImageFile = %q, want the cue sheet", got)
+	}
+	if got := ImageFile(t.TempDir()); got != "" {
+		t.Errorf("ImageFile(empty) = %q", got)
+	}
+}
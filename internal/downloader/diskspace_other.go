@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || dragonfly || windows)
+
+package downloader
+
+import "errors"
+
+func freeSpace(dir string) (int64, error) {
+	return 0, errFreeSpaceUnsupported
+}
+
+func isDiskFull(err error) bool {
+	return false
+}
+
+var errFreeSpaceUnsupported = errors.New("free space query not supported on this platform")
@@ -0,0 +1,22 @@
+//go:build linux || darwin || freebsd || dragonfly
+
+package downloader
+
+import (
+	"errors"
+	"syscall"
+)
+
+// freeSpace returns the bytes available to unprivileged users on the
+// filesystem containing dir.
+func freeSpace(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(existingAncestor(dir), &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
+
+func isDiskFull(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT)
+}
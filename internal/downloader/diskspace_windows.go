@@ -0,0 +1,27 @@
+//go:build windows
+
+package downloader
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// freeSpace returns the bytes available to the current user on the volume
+// containing dir.
+func freeSpace(dir string) (int64, error) {
+	p, err := windows.UTF16PtrFromString(existingAncestor(dir))
+	if err != nil {
+		return 0, err
+	}
+	var avail, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &avail, &total, &totalFree); err != nil {
+		return 0, err
+	}
+	return int64(avail), nil
+}
+
+func isDiskFull(err error) bool {
+	return errors.Is(err, windows.ERROR_DISK_FULL) || errors.Is(err, windows.ERROR_HANDLE_DISK_FULL)
+}
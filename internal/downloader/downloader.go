@@ -23,6 +23,9 @@ const (
 	StatusPaused
 	StatusCompleted
 	StatusFailed
+	StatusNoSpace
+	StatusRetryWait
+	StatusPresent
 )
 
 func (s Status) String() string {
@@ -37,51 +40,89 @@ func (s Status) String() string {
 		return "Completed"
 	case StatusFailed:
 		return "Failed"
+	case StatusNoSpace:
+		return "Insufficient space"
+	case StatusRetryWait:
+		return "Waiting to retry"
+	case StatusPresent:
+		return "Already present"
 	default:
 		return "Unknown"
 	}
 }
 
+// Pending reports whether the item still has work to do: queued, running,
+// paused, held for space or waiting for an automatic retry.
+func (s Status) Pending() bool {
+	switch s {
+	case StatusQueued, StatusActive, StatusPaused, StatusNoSpace, StatusRetryWait:
+		return true
+	default:
+		return false
+	}
+}
+
 // Item represents a single download.
 type Item struct {
-	ID          int
-	Name        string
-	URL         string
-	DestPath    string
+	ID       int
+	Name     string
+	URL      string
+	DestPath string
+	// Extract is set for ZIP archives that are extracted while they
+	// download; see downloadExtract.
+	Extract bool
+	// Playlist is the path of the disc set playlist written once the item
+	// and the other discs of its set have finished; empty for other files.
+	Playlist string
+	// OriginalName is the remote file name when the filesystem profile
+	// stored the file under a different Name; empty otherwise.
+	OriginalName string
+	// Hash is the expected checksum as "algo:hex", or empty when unknown.
+	Hash string
+	// Hashes holds the checksums computed while downloading; it is set once
+	// the download completes.
+	Hashes      Hashes
 	TotalBytes  int64
 	DoneBytes   atomic.Int64
 	Status      Status
 	Error       error
 	StartedAt   time.Time
 	CompletedAt time.Time
-	cancel      context.CancelFunc
-	Mu          sync.Mutex
+	// Attempts counts transfer attempts since the item was queued or
+	// manually retried; History keeps every failed attempt.
+	Attempts  int
+	History   []Attempt
+	NextRetry time.Time
+	cancel    context.CancelFunc
+	meter     rateMeter
+	// preallocated is set while the .part file of the running transfer
+	// holds blocks for the whole file, which free space already excludes.
+	preallocated atomic.Bool
+	Mu           sync.Mutex
 }
 
-// Progress returns a snapshot of the download's progress.
-func (it *Item) Progress() float64 {
-	total := it.TotalBytes
-	done := it.DoneBytes.Load()
-	if total <= 0 {
-		return 0
-	}
-	return float64(done) / float64(total)
+// total returns the expected size, which a running transfer may update.
+func (it *Item) total() int64 {
+	it.Mu.Lock()
+	defer it.Mu.Unlock()
+	return it.TotalBytes
 }
 
-// Speed returns the approximate download speed in bytes per second.
-func (it *Item) Speed() float64 {
+// setTotal records the expected size learned from the server.
+func (it *Item) setTotal(n int64) {
 	it.Mu.Lock()
-	started := it.StartedAt
+	it.TotalBytes = n
 	it.Mu.Unlock()
+}
 
-	if started.IsZero() {
-		return 0
-	}
-	elapsed := time.Since(started).Seconds()
-	if elapsed < 0.1 {
+// Progress returns a snapshot of the download's progress.
+func (it *Item) Progress() float64 {
+	total := it.total()
+	done := it.DoneBytes.Load()
+	if total <= 0 {
 		return 0
 	}
-	return float64(it.DoneBytes.Load()) / elapsed
+	return float64(done) / float64(total)
 }
 
 // Manager manages concurrent downloads.
@@ -90,16 +131,38 @@ type Manager struct {
 	downloadDir string
 	maxParallel int
 
-	mu         sync.Mutex
-	items      []*Item
-	nextID     int
-	sem        chan struct{}
-	onChange   func()
-	lastNotify time.Time
+	mu       sync.Mutex
+	items    []*Item
+	nextID   int
+	sem      chan struct{}
+	minFree  int64
+	spaceMu  sync.Mutex
+	prealloc bool
+	bufSize  int
+	existing ExistingPolicy
+
+	fsProfile FSProfile
+	extract   bool
+	namesMu   sync.Mutex
+
+	maxRetries   int
+	retryBackoff time.Duration
+
+	finishHook func(Snapshot)
+
+	// transfers counts running downloadFile calls so PauseAll can wait
+	// for .part files to be closed.
+	transfers sync.WaitGroup
+
+	subMu sync.Mutex
+	subs  []*subscriber
 }
 
 var errCancelled = errors.New("cancelled")
 
+// defaultBufferSize is the copy buffer used when none is configured.
+const defaultBufferSize = 32 * 1024
+
 // NewManager creates a download manager.
 func NewManager(c *client.Client, downloadDir string, maxParallel int) *Manager {
 	if maxParallel <= 0 {
@@ -107,48 +170,103 @@ func NewManager(c *client.Client, downloadDir string, maxParallel int) *Manager
 	}
 
 	return &Manager{
-		client:      c,
-		downloadDir: downloadDir,
-		maxParallel: maxParallel,
-		sem:         make(chan struct{}, maxParallel),
+		client:       c,
+		downloadDir:  downloadDir,
+		maxParallel:  maxParallel,
+		sem:          make(chan struct{}, maxParallel),
+		bufSize:      defaultBufferSize,
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
 	}
 }
 
-// SetOnChange sets a callback invoked when any download's state changes.
-func (m *Manager) SetOnChange(fn func()) {
+// SetPreallocate controls whether .part files are preallocated to their
+// expected size before data is written.
+func (m *Manager) SetPreallocate(enabled bool) {
 	m.mu.Lock()
-	m.onChange = fn
+	m.prealloc = enabled
 	m.mu.Unlock()
 }
 
-func (m *Manager) notify(force bool) {
-	m.mu.Lock()
-	fn := m.onChange
-	if !force {
-		now := time.Now()
-		if now.Sub(m.lastNotify) < 100*time.Millisecond {
-			m.mu.Unlock()
-			return
-		}
-		m.lastNotify = now
-	} else {
-		m.lastNotify = time.Now()
+// SetBufferSize sets the read/write buffer size used while copying
+// downloads to disk. Values below 4 KiB fall back to the default.
+func (m *Manager) SetBufferSize(size int) {
+	if size < 4*1024 {
+		size = defaultBufferSize
 	}
+	m.mu.Lock()
+	m.bufSize = size
 	m.mu.Unlock()
-	if fn != nil {
-		fn()
-	}
+}
+
+// Request describes a file to add to the download queue.
+type Request struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Subdir string `json:"subdir,omitempty"`
+	// Dir replaces the manager's download directory for this request when
+	// set. It must be absolute when sent to a daemon.
+	Dir string `json:"dir,omitempty"`
+	// Size is the expected size in bytes, or 0 when unknown. It is used to
+	// check free space before the transfer starts, and may be a rounded
+	// listing size; the server's Content-Length replaces it.
+	Size int64 `json:"size,omitempty"`
+	// Hash is the expected checksum as "algo:hex" (crc32, md5, sha1 or
+	// sha256), used to verify an existing local file. Optional.
+	Hash string `json:"hash,omitempty"`
+	// Extract asks for a ZIP archive to be extracted into a directory named
+	// after it while it downloads. The manager's own setting also applies.
+	Extract bool `json:"extract,omitempty"`
+	// Playlist names the M3U playlist of the disc set this file belongs to.
+	// It is written next to the file once every disc queued with the same
+	// playlist has finished.
+	Playlist string `json:"playlist,omitempty"`
 }
 
 // Enqueue adds a download to the queue and starts processing.
 // Returns the item and whether a new queue entry was created.
 func (m *Manager) Enqueue(name, fileURL, subdir string) (*Item, bool) {
+	return m.Add(Request{Name: name, URL: fileURL, Subdir: subdir})
+}
+
+// Add queues a download described by req and starts processing.
+// Returns the item and whether a new queue entry was created.
+func (m *Manager) Add(req Request) (*Item, bool) {
+	item, created := m.addItem(req, StatusQueued)
+	if created {
+		// Start download in background.
+		go m.processItem(item)
+	}
+	return item, created
+}
+
+// Adopt adds a paused item for a partial download left behind by an earlier
+// session, so Resume continues it from its .part file.
+// Returns the item and whether a new queue entry was created.
+func (m *Manager) Adopt(req Request) (*Item, bool) {
+	return m.addItem(req, StatusPaused)
+}
+
+func (m *Manager) addItem(req Request, status Status) (*Item, bool) {
+	fileURL := req.URL
 	m.mu.Lock()
+	name := m.fsProfile.Name(req.Name)
+	originalName := ""
+	if name != req.Name {
+		originalName = req.Name
+	}
 	destDir := m.downloadDir
-	if subdir != "" {
-		destDir = filepath.Join(destDir, subdir)
+	if req.Dir != "" {
+		destDir = req.Dir
+	}
+	if req.Subdir != "" {
+		destDir = filepath.Join(destDir, filepath.FromSlash(m.fsProfile.Subdir(filepath.ToSlash(req.Subdir))))
 	}
 	destPath := filepath.Join(destDir, name)
+	playlist := ""
+	if req.Playlist != "" {
+		playlist = filepath.Join(destDir, m.fsProfile.Name(req.Playlist))
+	}
 
 	for _, it := range m.items {
 		it.Mu.Lock()
@@ -164,24 +282,34 @@ func (m *Manager) Enqueue(name, fileURL, subdir string) (*Item, bool) {
 	id := m.nextID
 
 	item := &Item{
-		ID:       id,
-		Name:     name,
-		URL:      fileURL,
-		DestPath: destPath,
-		Status:   StatusQueued,
+		ID:           id,
+		Name:         name,
+		OriginalName: originalName,
+		Extract:      (req.Extract || m.extract) && isZipName(name),
+		Playlist:     playlist,
+		URL:          fileURL,
+		DestPath:     destPath,
+		Hash:         req.Hash,
+		TotalBytes:   req.Size,
+		Status:       status,
+	}
+	if status == StatusPaused {
+		if info, err := os.Stat(destPath + ".part"); err == nil {
+			item.DoneBytes.Store(info.Size())
+		}
 	}
 	m.items = append(m.items, item)
 	m.mu.Unlock()
 
-	m.notify(true)
-
-	// Start download in background.
-	go m.processItem(item)
-
+	if status == StatusPaused {
+		m.emit(EventPaused, item)
+	} else {
+		m.emit(EventEnqueued, item)
+	}
 	return item, true
 }
 
-// HasActive returns true when any item is queued, active, or paused.
+// HasActive returns true when any item still has pending work.
 func (m *Manager) HasActive() bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -189,7 +317,7 @@ func (m *Manager) HasActive() bool {
 		it.Mu.Lock()
 		status := it.Status
 		it.Mu.Unlock()
-		if status == StatusQueued || status == StatusActive || status == StatusPaused {
+		if status.Pending() {
 			return true
 		}
 	}
@@ -199,20 +327,23 @@ func (m *Manager) HasActive() bool {
 // CancelAll cancels all active or queued downloads.
 func (m *Manager) CancelAll() {
 	m.mu.Lock()
+	var cancelled []*Item
 	for _, it := range m.items {
 		it.Mu.Lock()
-		switch it.Status {
-		case StatusQueued, StatusActive, StatusPaused:
+		if it.Status.Pending() {
 			if it.cancel != nil {
 				it.cancel()
 			}
 			it.Status = StatusFailed
 			it.Error = errCancelled
+			cancelled = append(cancelled, it)
 		}
 		it.Mu.Unlock()
 	}
 	m.mu.Unlock()
-	m.notify(true)
+	for _, it := range cancelled {
+		m.emit(EventFailed, it)
+	}
 }
 
 // Items returns a snapshot of all download items.
@@ -228,23 +359,23 @@ func (m *Manager) Items() []*Item {
 func (m *Manager) ClearFinished() int {
 	m.mu.Lock()
 	kept := m.items[:0]
-	removed := 0
+	var removed []*Item
 	for _, it := range m.items {
 		it.Mu.Lock()
 		status := it.Status
 		it.Mu.Unlock()
-		if status == StatusCompleted || status == StatusFailed {
-			removed++
+		if status == StatusCompleted || status == StatusFailed || status == StatusPresent {
+			removed = append(removed, it)
 			continue
 		}
 		kept = append(kept, it)
 	}
 	m.items = kept
 	m.mu.Unlock()
-	if removed > 0 {
-		m.notify(true)
+	for _, it := range removed {
+		m.emit(EventRemoved, it)
 	}
-	return removed
+	return len(removed)
 }
 
 // ActiveCount returns the number of currently downloading items.
@@ -265,12 +396,14 @@ func (m *Manager) ActiveCount() int {
 // Cancel cancels a download by ID.
 func (m *Manager) Cancel(id int) {
 	m.mu.Lock()
+	var target *Item
 	for _, it := range m.items {
 		if it.ID == id {
 			it.Mu.Lock()
-			if it.Status == StatusQueued || it.Status == StatusActive || it.Status == StatusPaused {
+			if it.Status.Pending() {
 				it.Status = StatusFailed
 				it.Error = errCancelled
+				target = it
 			}
 			if it.cancel != nil {
 				it.cancel()
@@ -280,32 +413,70 @@ func (m *Manager) Cancel(id int) {
 		}
 	}
 	m.mu.Unlock()
-	m.notify(true)
+	if target != nil {
+		m.emit(EventFailed, target)
+	}
 }
 
-// Pause pauses an active or queued download.
+// Pause pauses an active, queued or retry-waiting download.
 func (m *Manager) Pause(id int) bool {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	var target *Item
 	for _, it := range m.items {
-		if it.ID != id {
-			continue
+		if it.ID == id {
+			target = it
+			break
 		}
+	}
+	m.mu.Unlock()
+	if target == nil {
+		return false
+	}
+
+	target.Mu.Lock()
+	if target.Status != StatusActive && target.Status != StatusQueued && target.Status != StatusRetryWait {
+		target.Mu.Unlock()
+		return false
+	}
+	target.Status = StatusPaused
+	target.NextRetry = time.Time{}
+	target.Error = nil
+	if target.cancel != nil {
+		target.cancel()
+	}
+	target.Mu.Unlock()
+	m.emit(EventPaused, target)
+	return true
+}
+
+// PauseAll pauses every active, queued, retry-waiting or held download and
+// waits until running transfers have stopped writing, so their .part files can
+// be resumed later. Held downloads are paused too, or a transfer unwinding
+// here would release them and start new ones. It returns the paused items.
+func (m *Manager) PauseAll() []*Item {
+	m.mu.Lock()
+	var paused []*Item
+	for _, it := range m.items {
 		it.Mu.Lock()
-		if it.Status != StatusActive && it.Status != StatusQueued {
-			it.Mu.Unlock()
-			return false
-		}
-		it.Status = StatusPaused
-		it.Error = nil
-		if it.cancel != nil {
-			it.cancel()
+		switch it.Status {
+		case StatusActive, StatusQueued, StatusRetryWait, StatusNoSpace:
+			it.Status = StatusPaused
+			it.NextRetry = time.Time{}
+			it.Error = nil
+			if it.cancel != nil {
+				it.cancel()
+			}
+			paused = append(paused, it)
 		}
 		it.Mu.Unlock()
-		go m.notify(true)
-		return true
 	}
-	return false
+	m.mu.Unlock()
+	for _, it := range paused {
+		m.emit(EventPaused, it)
+	}
+	// No item can start a transfer once paused, so the count only drops.
+	m.transfers.Wait()
+	return paused
 }
 
 // Resume resumes a paused download.
@@ -333,12 +504,12 @@ func (m *Manager) Resume(id int) bool {
 	target.cancel = nil
 	target.Mu.Unlock()
 
-	m.notify(true)
+	m.emit(EventEnqueued, target)
 	go m.processItem(target)
 	return true
 }
 
-// Retry restarts a failed download item.
+// Retry restarts a failed download item or one held for insufficient space.
 func (m *Manager) Retry(id int) bool {
 	m.mu.Lock()
 	var target *Item
@@ -355,18 +526,19 @@ func (m *Manager) Retry(id int) bool {
 	}
 
 	target.Mu.Lock()
-	if target.Status != StatusFailed {
+	if target.Status != StatusFailed && target.Status != StatusNoSpace {
 		target.Mu.Unlock()
 		return false
 	}
 	target.Status = StatusQueued
 	target.Error = nil
+	target.Attempts = 0
 	target.StartedAt = time.Time{}
 	target.CompletedAt = time.Time{}
 	target.cancel = nil
 	target.Mu.Unlock()
 
-	m.notify(true)
+	m.emit(EventEnqueued, target)
 	go m.processItem(target)
 	return true
 }
@@ -376,48 +548,166 @@ func (m *Manager) processItem(item *Item) {
 	m.sem <- struct{}{}
 	defer func() { <-m.sem }()
 
+	item.Mu.Lock()
+	queued := item.Status == StatusQueued
+	item.Mu.Unlock()
+	if !queued {
+		return
+	}
+
+	// The filesystem's size limit is only checked against the server's
+	// Content-Length: a listing size such as "4.0 GiB" is rounded and may
+	// exceed the limit when the file does not.
+
+	// Skip files that are already on disk before reserving any space.
+	present, presentErr := m.isPresent(item, item.TotalBytes)
+	if present || presentErr != nil {
+		m.finishEarly(item, present, presentErr)
+		return
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
+
+	// Check and claim space under spaceMu so concurrent starts see each
+	// other's reservations.
+	m.spaceMu.Lock()
+	item.Mu.Lock()
+	if item.Status != StatusQueued {
+		item.Mu.Unlock()
+		m.spaceMu.Unlock()
+		cancel()
+		return
+	}
+	item.Mu.Unlock()
+	spaceErr := m.checkSpace(item, remainingBytes(item))
 	item.Mu.Lock()
-	if item.Status == StatusFailed && errors.Is(item.Error, errCancelled) {
+	if item.Status != StatusQueued {
 		item.Mu.Unlock()
+		m.spaceMu.Unlock()
 		cancel()
 		return
 	}
-	if item.Status == StatusPaused {
+	if spaceErr != nil {
+		item.Status = StatusNoSpace
+		item.Error = spaceErr
 		item.Mu.Unlock()
+		m.spaceMu.Unlock()
 		cancel()
+		m.emit(EventFailed, item)
 		return
 	}
 	item.cancel = cancel
 	item.Status = StatusActive
 	item.StartedAt = time.Now()
 	item.Error = nil
+	item.Attempts++
+	m.transfers.Add(1)
 	item.Mu.Unlock()
-	m.notify(true)
+	m.spaceMu.Unlock()
+	m.emit(EventStarted, item)
 
 	err := m.downloadFile(ctx, item)
+	m.transfers.Done()
+
+	// Read the retry policy before taking item.Mu: the lock order is m.mu
+	// then item.Mu.
+	m.mu.Lock()
+	maxRetries, backoff := m.maxRetries, m.retryBackoff
+	m.mu.Unlock()
 
 	item.Mu.Lock()
+	// Pause and Cancel set the item's status and publish their own event
+	// before the transfer unwinds; only report transitions made here.
+	report := true
 	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			if item.Status != StatusPaused {
+		if errors.Is(err, context.Canceled) || item.Status != StatusActive {
+			// The transfer may also fail for another reason after it was
+			// paused or cancelled; keep the status set there.
+			report = false
+			if item.Status != StatusPaused && item.Status != StatusFailed {
 				item.Status = StatusFailed
 				item.Error = errCancelled
+				report = true
 			}
+		} else if errors.Is(err, ErrInsufficientSpace) {
+			item.Status = StatusNoSpace
+			item.Error = err
+		} else if errors.Is(err, errAlreadyPresent) {
+			item.Status = StatusPresent
+			item.CompletedAt = time.Now()
 		} else {
-			item.Status = StatusFailed
+			class := ClassifyError(err)
+			item.History = append(item.History, Attempt{At: time.Now(), Err: err, Class: class})
 			item.Error = err
+			if !m.scheduleRetry(item, class, maxRetries, backoff) {
+				item.Status = StatusFailed
+			}
 		}
 	} else {
 		item.Status = StatusCompleted
 		item.CompletedAt = time.Now()
 	}
+	held := item.Status == StatusNoSpace
+	completed := item.Status == StatusCompleted
+	final := item.Status
 	item.Mu.Unlock()
 	cancel()
-	m.notify(true)
+	if completed {
+		m.recordStoredName(item)
+	}
+	if completed || final == StatusPresent {
+		m.writePlaylist(item)
+	}
+	if report {
+		m.reportFinished(item)
+		switch final {
+		case StatusCompleted, StatusPresent:
+			m.emit(EventCompleted, item)
+		case StatusRetryWait:
+			m.emit(EventRetrying, item)
+		default:
+			m.emit(EventFailed, item)
+		}
+	}
+
+	// A finished download releases its reservation; give held items another
+	// chance, unless this one was itself held and nothing changed.
+	if !held {
+		m.releaseHeld()
+	}
+}
+
+// finishEarly settles an item whose destination was checked before the
+// transfer started: either it is already present or the check failed.
+func (m *Manager) finishEarly(item *Item, present bool, err error) {
+	item.Mu.Lock()
+	if item.Status != StatusQueued {
+		item.Mu.Unlock()
+		return
+	}
+	if present {
+		item.Status = StatusPresent
+		item.DoneBytes.Store(item.TotalBytes)
+		item.CompletedAt = time.Now()
+	} else {
+		item.Status = StatusFailed
+		item.Error = err
+	}
+	item.Mu.Unlock()
+	if present {
+		m.writePlaylist(item)
+		m.emit(EventCompleted, item)
+	} else {
+		m.reportFinished(item)
+		m.emit(EventFailed, item)
+	}
 }
 
 func (m *Manager) downloadFile(ctx context.Context, item *Item) error {
+	if item.Extract {
+		return m.downloadExtract(ctx, item)
+	}
+
 	// Ensure destination directory exists.
 	dir := filepath.Dir(item.DestPath)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
@@ -440,11 +730,36 @@ func (m *Manager) downloadFile(ctx context.Context, item *Item) error {
 	defer body.Close()
 
 	// Calculate total size.
-	if contentLength > 0 {
+	sizeFromServer := contentLength > 0
+	if sizeFromServer {
+		listed := item.TotalBytes
 		if resumed {
-			item.TotalBytes = resumeFrom + contentLength
+			item.setTotal(resumeFrom + contentLength)
 		} else {
-			item.TotalBytes = contentLength
+			item.setTotal(contentLength)
+		}
+		// The expected size is unknown or a rounded listing size, so the
+		// existing-file check before the request may have missed the file;
+		// repeat it with the size the server told us.
+		if item.TotalBytes != listed {
+			present, err := m.isPresent(item, item.TotalBytes)
+			if err != nil {
+				return err
+			}
+			if present {
+				item.DoneBytes.Store(item.TotalBytes)
+				os.Remove(partPath)
+				return errAlreadyPresent
+			}
+		}
+		m.mu.Lock()
+		profile := m.fsProfile
+		m.mu.Unlock()
+		if err := profile.CheckSize(item.TotalBytes); err != nil {
+			return err
+		}
+		if err := m.checkSpace(item, contentLength); err != nil {
+			return err
 		}
 	}
 
@@ -456,6 +771,7 @@ func (m *Manager) downloadFile(ctx context.Context, item *Item) error {
 		flags |= os.O_TRUNC
 		item.DoneBytes.Store(0)
 	}
+	item.meter.reset(item.DoneBytes.Load(), time.Now())
 
 	f, err := os.OpenFile(partPath, flags, 0o644)
 	if err != nil {
@@ -463,8 +779,33 @@ func (m *Manager) downloadFile(ctx context.Context, item *Item) error {
 	}
 	defer f.Close()
 
+	// Checksums cover the whole file, so a resumed transfer first reads back
+	// what is already on disk. Failing that only loses the checksums.
+	hasher := newFileHasher()
+	if resumed {
+		if err := hasher.seed(partPath, resumeFrom); err != nil {
+			hasher = nil
+		}
+	}
+
+	m.mu.Lock()
+	prealloc, bufSize := m.prealloc, m.bufSize
+	m.mu.Unlock()
+
+	item.preallocated.Store(false)
+	if prealloc && item.TotalBytes > 0 {
+		// Allocation failures other than a full disk are not fatal; some
+		// filesystems simply do not support it.
+		err := preallocate(f, item.TotalBytes)
+		if err != nil && isDiskFull(err) {
+			return fmt.Errorf("preallocating file: %w", ErrInsufficientSpace)
+		}
+		item.preallocated.Store(err == nil)
+	}
+
 	// Copy with progress tracking.
-	buf := make([]byte, 32*1024)
+	buf := make([]byte, bufSize)
+	var lastProgress time.Time
 	for {
 		select {
 		case <-ctx.Done():
@@ -475,10 +816,21 @@ func (m *Manager) downloadFile(ctx context.Context, item *Item) error {
 		n, err := body.Read(buf)
 		if n > 0 {
 			if _, werr := f.Write(buf[:n]); werr != nil {
+				if isDiskFull(werr) {
+					return fmt.Errorf("writing file: %w", ErrInsufficientSpace)
+				}
 				return fmt.Errorf("writing file: %w", werr)
 			}
-			item.DoneBytes.Add(int64(n))
-			m.notify(false)
+			if hasher != nil {
+				hasher.Write(buf[:n])
+			}
+			done := item.DoneBytes.Add(int64(n))
+			now := time.Now()
+			item.meter.sample(done, now)
+			if now.Sub(lastProgress) >= progressInterval {
+				lastProgress = now
+				m.emit(EventProgress, item)
+			}
 		}
 		if err == io.EOF {
 			break
@@ -488,11 +840,51 @@ func (m *Manager) downloadFile(ctx context.Context, item *Item) error {
 		}
 	}
 
-	// Rename .part to final name.
-	f.Close()
-	if err := os.Rename(partPath, item.DestPath); err != nil {
-		return fmt.Errorf("renaming file: %w", err)
+	// Without a Content-Length the expected size is at best a rounded
+	// listing size, so the file is taken as sent.
+	expected := item.TotalBytes
+	if !sizeFromServer {
+		expected = 0
+	}
+	if err := finalizePart(f, partPath, item.DestPath, expected); err != nil {
+		return err
+	}
+	if !sizeFromServer {
+		item.setTotal(item.DoneBytes.Load())
 	}
+	if hasher != nil {
+		item.Mu.Lock()
+		item.Hashes = hasher.sums()
+		item.Mu.Unlock()
+	}
+	return nil
+}
 
+// finalizePart makes a finished .part file durable and moves it into place.
+// The data is flushed before the rename and the directory after it, so a
+// crash leaves either the complete file under its final name or the .part.
+func finalizePart(f *os.File, partPath, destPath string, expected int64) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("checking file: %w", err)
+	}
+	if expected > 0 && info.Size() != expected {
+		return fmt.Errorf("%w: got %d of %d bytes", errIncomplete, info.Size(), expected)
+	}
+	if err := f.Sync(); err != nil {
+		if isDiskFull(err) {
+			return fmt.Errorf("syncing file: %w", ErrInsufficientSpace)
+		}
+		return fmt.Errorf("syncing file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing file: %w", err)
+	}
+	if err := os.Rename(partPath, destPath); err != nil {
+		return fmt.Errorf("renaming file: %w", err)
+	}
+	if err := syncDir(filepath.Dir(destPath)); err != nil {
+		return fmt.Errorf("syncing directory: %w", err)
+	}
 	return nil
 }
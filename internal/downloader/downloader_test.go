@@ -0,0 +1,462 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+)
+
+func TestFinalizePart_RenamesCompleteFile(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "game.zip")
+	part := dest + ".part"
+
+	f, err := os.Create(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := finalizePart(f, part, dest, 5); err != nil {
+		t.Fatalf("finalizePart returned error: %v", err)
+	}
+	if _, err := os.Stat(part); !os.IsNotExist(err) {
+		t.Fatalf("expected .part to be gone, stat err = %v", err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected final file: %q, %v", data, err)
+	}
+}
+
+func TestFinalizePart_RefusesTruncatedFile(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "game.zip")
+	part := dest + ".part"
+
+	f, err := os.Create(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("hel"); err != nil {
+		t.Fatal(err)
+	}
+	if err := finalizePart(f, part, dest, 5); err == nil {
+		t.Fatal("expected error for truncated file")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("expected no final-named file, stat err = %v", err)
+	}
+}
+
+func TestClassifyError_RetryableClasses(t *testing.T) {
+	cases := []struct {
+		err       error
+		class     ErrorClass
+		retryable bool
+	}{
+		{&client.HTTPError{StatusCode: 503}, ClassServer, true},
+		{&client.HTTPError{StatusCode: 404}, ClassNotFound, false},
+		{&client.HTTPError{StatusCode: 403}, ClassHTTP, false},
+		{fmt.Errorf("reading response: %w", io.ErrUnexpectedEOF), ClassNetwork, true},
+		{fmt.Errorf("x: %w", client.ErrHTMLResponse), ClassRefused, false},
+		{fmt.Errorf("writing file: %w", ErrInsufficientSpace), ClassNoSpace, false},
+		{errCancelled, ClassCancelled, false},
+	}
+	for _, tc := range cases {
+		got := ClassifyError(tc.err)
+		if got != tc.class {
+			t.Errorf("ClassifyError(%v) = %v, want %v", tc.err, got, tc.class)
+		}
+		if got.Retryable() != tc.retryable {
+			t.Errorf("%v.Retryable() = %v, want %v", got, got.Retryable(), tc.retryable)
+		}
+	}
+}
+
+func TestRetryDelay_DoublesUpToCap(t *testing.T) {
+	if d := retryDelay(5*time.Second, 1); d != 5*time.Second {
+		t.Fatalf("first retry delay = %v", d)
+	}
+	if d := retryDelay(5*time.Second, 3); d != 20*time.Second {
+		t.Fatalf("third retry delay = %v", d)
+	}
+	if d := retryDelay(5*time.Second, 20); d != maxRetryBackoff {
+		t.Fatalf("expected delay capped at %v, got %v", maxRetryBackoff, d)
+	}
+}
+
+func TestIsPresent_Policies(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "game.zip")
+	if err := os.WriteFile(dest, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// sha1("hello")
+	goodHash := "sha1:aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+	badHash := "sha1:0000000000000000000000000000000000000000"
+
+	tests := []struct {
+		policy ExistingPolicy
+		size   int64
+		hash   string
+		want   bool
+	}{
+		{ExistingSkip, 5, "", true},
+		{ExistingSkip, 6, "", false},
+		{ExistingSkip, 0, "", false},
+		{ExistingSkip, 5, badHash, true},
+		{ExistingVerify, 5, goodHash, true},
+		{ExistingVerify, 0, goodHash, true},
+		{ExistingVerify, 5, badHash, false},
+		{ExistingVerify, 5, "", true},
+		{ExistingOverwrite, 5, goodHash, false},
+	}
+	for _, tt := range tests {
+		m := NewManager(nil, dir, 1)
+		m.SetExistingPolicy(tt.policy)
+		item := &Item{DestPath: dest, Hash: tt.hash}
+		got, err := m.isPresent(item, tt.size)
+		if err != nil {
+			t.Fatalf("%s size=%d hash=%q: %v", tt.policy, tt.size, tt.hash, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s size=%d hash=%q: present = %v, want %v", tt.policy, tt.size, tt.hash, got, tt.want)
+		}
+	}
+
+	m := NewManager(nil, dir, 1)
+	if got, _ := m.isPresent(&Item{DestPath: filepath.Join(dir, "missing.zip")}, 5); got {
+		t.Error("missing file reported as present")
+	}
+}
+
+func TestFileHasher_SeedMatchesSinglePass(t *testing.T) {
+	data := []byte("The quick brown fox jumps over the lazy dog")
+	part := filepath.Join(t.TempDir(), "f.part")
+	if err := os.WriteFile(part, data[:10], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := newFileHasher()
+	if err := resumed.seed(part, 10); err != nil {
+		t.Fatal(err)
+	}
+	resumed.Write(data[10:])
+
+	whole := newFileHasher()
+	whole.Write(data)
+
+	if resumed.sums() != whole.sums() {
+		t.Fatalf("resumed %+v != single pass %+v", resumed.sums(), whole.sums())
+	}
+	if got := whole.sums().CRC32; got != "414fa339" {
+		t.Fatalf("CRC32 = %s", got)
+	}
+}
+
+func TestPauseAll_KeepsPartFile(t *testing.T) {
+	// The server sends part of the file and then stalls until the client
+	// goes away.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Write(make([]byte, 100))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m := NewManager(client.New(srv.URL+"/", 100), dir, 1)
+	m.SetPreallocate(false)
+	events, unsubscribe := m.Subscribe(16)
+	defer unsubscribe()
+	item, _ := m.Add(Request{Name: "game.zip", URL: srv.URL + "/game.zip"})
+
+	deadline := time.After(5 * time.Second)
+	for item.DoneBytes.Load() < 100 {
+		select {
+		case <-events:
+		case <-deadline:
+			t.Fatal("download did not start")
+		}
+	}
+
+	if paused := m.PauseAll(); len(paused) != 1 || paused[0] != item {
+		t.Fatalf("PauseAll returned %v", paused)
+	}
+	item.Mu.Lock()
+	status := item.Status
+	item.Mu.Unlock()
+	if status != StatusPaused {
+		t.Errorf("status = %s, want paused", status)
+	}
+	info, err := os.Stat(filepath.Join(dir, "game.zip.part"))
+	if err != nil || info.Size() != 100 {
+		t.Fatalf("part file = %v, %v; want 100 bytes", info, err)
+	}
+}
+
+func TestRetries_DoNotDeadlockWithQueueQueries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	m := NewManager(client.New(srv.URL+"/", 1000), t.TempDir(), 4)
+	m.SetPreallocate(false)
+	m.SetRetryPolicy(20, time.Millisecond)
+	for i := 0; i < 8; i++ {
+		name := fmt.Sprintf("game%d.zip", i)
+		m.Add(Request{Name: name, URL: srv.URL + "/" + name})
+	}
+
+	// Retries are scheduled while other goroutines walk the queue, which
+	// takes m.mu and then each item's lock.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stop := time.Now().Add(300 * time.Millisecond)
+		for time.Now().Before(stop) {
+			for _, it := range m.Items() {
+				it.Mu.Lock()
+				_ = it.Status
+				it.Mu.Unlock()
+			}
+			m.HasActive()
+		}
+		m.PauseAll()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("queue queries deadlocked with retries")
+	}
+	for _, it := range m.Items() {
+		it.Mu.Lock()
+		status := it.Status
+		it.Mu.Unlock()
+		if status.Pending() && status != StatusPaused {
+			t.Errorf("%s is still %s after PauseAll", it.Name, status)
+		}
+	}
+}
+
+func TestExistingFile_FoundWithRoundedListingSize(t *testing.T) {
+	data := make([]byte, 1300)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "game.zip"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(client.New(srv.URL+"/", 1000), dir, 1)
+	m.SetPreallocate(false)
+	events, unsubscribe := m.Subscribe(16)
+	defer unsubscribe()
+	// The listing shows "1.3 KiB", which parses to 1331 bytes.
+	item, _ := m.Add(Request{Name: "game.zip", URL: srv.URL + "/game.zip", Size: 1331})
+
+	waitIdle(t, m, events)
+	item.Mu.Lock()
+	status := item.Status
+	item.Mu.Unlock()
+	if status != StatusPresent {
+		t.Errorf("status = %s, want present", status)
+	}
+}
+
+func TestDownload_WithoutContentLengthKeepsSentSize(t *testing.T) {
+	data := make([]byte, 1300)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Flushing first makes the response chunked, without a
+		// Content-Length.
+		w.(http.Flusher).Flush()
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m := NewManager(client.New(srv.URL+"/", 1000), dir, 1)
+	m.SetPreallocate(false)
+	m.SetRetryPolicy(0, time.Millisecond)
+	events, unsubscribe := m.Subscribe(16)
+	defer unsubscribe()
+	// The listing shows "1.3 KiB", which parses to 1331 bytes.
+	item, _ := m.Add(Request{Name: "game.zip", URL: srv.URL + "/game.zip", Size: 1331})
+
+	waitIdle(t, m, events)
+	item.Mu.Lock()
+	status, itemErr := item.Status, item.Error
+	item.Mu.Unlock()
+	if status != StatusCompleted {
+		t.Fatalf("status = %s (%v), want completed", status, itemErr)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "game.zip")); err != nil || info.Size() != 1300 {
+		t.Fatalf("file = %v, %v; want 1300 bytes", info, err)
+	}
+	if item.TotalBytes != 1300 {
+		t.Errorf("TotalBytes = %d, want 1300", item.TotalBytes)
+	}
+}
+
+// waitIdle waits until no item of m is pending, reading m's events.
+func waitIdle(t *testing.T, m *Manager, events <-chan Event) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for m.HasActive() {
+		select {
+		case <-events:
+		case <-deadline:
+			t.Fatal("downloads did not finish")
+		}
+	}
+}
+
+func TestFSProfile_SizeLimitUsesContentLength(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/huge.iso" {
+			w.Header().Set("Content-Length", fmt.Sprint(int64(4<<30)))
+			return
+		}
+		w.Header().Set("Content-Length", "100")
+		w.Write(make([]byte, 100))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m := NewManager(client.New(srv.URL+"/", 1000), dir, 2)
+	m.SetPreallocate(false)
+	m.SetRetryPolicy(0, time.Millisecond)
+	m.SetFSProfile(FSFAT32)
+	events, unsubscribe := m.Subscribe(16)
+	defer unsubscribe()
+	// Both are listed as "4.0 GiB"; only the second one really is.
+	fits, _ := m.Add(Request{Name: "game.iso", URL: srv.URL + "/game.iso", Size: 4 << 30})
+	huge, _ := m.Add(Request{Name: "huge.iso", URL: srv.URL + "/huge.iso", Size: 4 << 30})
+	waitIdle(t, m, events)
+
+	fits.Mu.Lock()
+	status, itemErr := fits.Status, fits.Error
+	fits.Mu.Unlock()
+	if status != StatusCompleted {
+		t.Errorf("game.iso: status = %s (%v), want completed", status, itemErr)
+	}
+	huge.Mu.Lock()
+	status, itemErr = huge.Status, huge.Error
+	huge.Mu.Unlock()
+	if status != StatusFailed || !errors.Is(itemErr, ErrTooLarge) {
+		t.Errorf("huge.iso: status = %s (%v), want failed as too large", status, itemErr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "huge.iso.part")); !os.IsNotExist(err) {
+		t.Errorf("huge.iso.part was written: %v", err)
+	}
+}
+
+func TestPauseAll_PausesHeldItems(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Length", "1000")
+		w.Write(make([]byte, 100))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	m := NewManager(client.New(srv.URL+"/", 100), dir, 1)
+	m.SetPreallocate(false)
+	events, unsubscribe := m.Subscribe(16)
+	defer unsubscribe()
+	item, _ := m.Add(Request{Name: "game.zip", URL: srv.URL + "/game.zip"})
+	deadline := time.After(5 * time.Second)
+	for item.DoneBytes.Load() < 100 {
+		select {
+		case <-events:
+		case <-deadline:
+			t.Fatal("download did not start")
+		}
+	}
+	// An item held for disk space would be released when the transfer
+	// above unwinds.
+	held := &Item{ID: 2, Name: "held.zip", URL: srv.URL + "/held.zip", DestPath: filepath.Join(dir, "held.zip"), Status: StatusNoSpace}
+	m.mu.Lock()
+	m.items = append(m.items, held)
+	m.mu.Unlock()
+
+	if paused := m.PauseAll(); len(paused) != 2 {
+		t.Fatalf("PauseAll paused %d items, want 2", len(paused))
+	}
+	time.Sleep(50 * time.Millisecond)
+	held.Mu.Lock()
+	status := held.Status
+	held.Mu.Unlock()
+	if status != StatusPaused {
+		t.Errorf("held item status = %s, want paused", status)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests after PauseAll, want 1", n)
+	}
+}
+
+func TestReservedBytes_SkipsPreallocatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(nil, dir, 2)
+	for i := 0; i < 2; i++ {
+		it := &Item{ID: i + 1, DestPath: filepath.Join(dir, fmt.Sprintf("game%d.iso", i)), Status: StatusActive, TotalBytes: 40 << 30}
+		m.items = append(m.items, it)
+	}
+	if got := m.reservedBytes(nil); got != 80<<30 {
+		t.Fatalf("reserved = %d, want 80 GiB", got)
+	}
+	// Free space already excludes the blocks of a preallocated file.
+	m.items[0].preallocated.Store(true)
+	if got := m.reservedBytes(nil); got != 40<<30 {
+		t.Errorf("reserved = %d, want 40 GiB", got)
+	}
+
+	// A running transfer with preallocation holds no reservation.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000000")
+		w.Write(make([]byte, 100))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	m = NewManager(client.New(srv.URL+"/", 100), dir, 1)
+	m.SetPreallocate(true)
+	events, unsubscribe := m.Subscribe(16)
+	defer unsubscribe()
+	item, _ := m.Add(Request{Name: "game.zip", URL: srv.URL + "/game.zip"})
+	deadline := time.After(5 * time.Second)
+	for item.DoneBytes.Load() < 100 {
+		select {
+		case <-events:
+		case <-deadline:
+			t.Fatal("download did not start")
+		}
+	}
+	defer m.PauseAll()
+	if !item.preallocated.Load() {
+		t.Skip("filesystem does not support preallocation")
+	}
+	if got := m.reservedBytes(nil); got != 0 {
+		t.Errorf("reserved = %d for a preallocated transfer, want 0", got)
+	}
+}
@@ -0,0 +1,106 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"syscall"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+)
+
+// ErrorClass groups download failures by cause.
+type ErrorClass int
+
+const (
+	ClassNone ErrorClass = iota
+	ClassCancelled
+	ClassNetwork
+	ClassServer
+	ClassNotFound
+	ClassHTTP
+	ClassRefused
+	ClassNoSpace
+	ClassDisk
+	ClassTooLarge
+	ClassOther
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassNone:
+		return ""
+	case ClassCancelled:
+		return "cancelled"
+	case ClassNetwork:
+		return "network"
+	case ClassServer:
+		return "server"
+	case ClassNotFound:
+		return "not found"
+	case ClassHTTP:
+		return "http"
+	case ClassRefused:
+		return "refused"
+	case ClassNoSpace:
+		return "no space"
+	case ClassDisk:
+		return "disk"
+	case ClassTooLarge:
+		return "too large"
+	default:
+		return "other"
+	}
+}
+
+// errIncomplete is returned when a response ends before the expected size.
+var errIncomplete = errors.New("incomplete download")
+
+// ClassifyError maps a download error to its ErrorClass.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ClassNone
+	}
+	if errors.Is(err, errCancelled) || errors.Is(err, context.Canceled) {
+		return ClassCancelled
+	}
+	if errors.Is(err, ErrInsufficientSpace) {
+		return ClassNoSpace
+	}
+	if errors.Is(err, ErrTooLarge) {
+		return ClassTooLarge
+	}
+	if errors.Is(err, client.ErrHTMLResponse) {
+		return ClassRefused
+	}
+	var httpErr *client.HTTPError
+	if errors.As(err, &httpErr) {
+		switch {
+		case httpErr.StatusCode == 404 || httpErr.StatusCode == 410:
+			return ClassNotFound
+		case httpErr.StatusCode == 408 || httpErr.StatusCode == 429 || httpErr.StatusCode >= 500:
+			return ClassServer
+		default:
+			return ClassHTTP
+		}
+	}
+	if errors.Is(err, errIncomplete) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return ClassNetwork
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ClassNetwork
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return ClassDisk
+	}
+	return ClassOther
+}
@@ -0,0 +1,206 @@
+package downloader
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies what happened to a download.
+type EventType int
+
+const (
+	EventEnqueued EventType = iota
+	EventStarted
+	EventProgress
+	EventPaused
+	EventCompleted
+	EventFailed
+	EventRetrying
+	EventRemoved
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventEnqueued:
+		return "enqueued"
+	case EventStarted:
+		return "started"
+	case EventProgress:
+		return "progress"
+	case EventPaused:
+		return "paused"
+	case EventCompleted:
+		return "completed"
+	case EventFailed:
+		return "failed"
+	case EventRetrying:
+		return "retrying"
+	case EventRemoved:
+		return "removed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a snapshot of a download at the moment its state changed.
+type Event struct {
+	Type       EventType
+	Time       time.Time
+	ItemID     int
+	Name       string
+	URL        string
+	DestPath   string
+	Status     Status
+	DoneBytes  int64
+	TotalBytes int64
+	Speed      float64
+	Err        error
+	Class      ErrorClass
+}
+
+// progressInterval throttles progress events per item.
+const progressInterval = 250 * time.Millisecond
+
+// maxPendingEvents bounds a subscriber's backlog. Once reached, the oldest
+// progress event is dropped, or the oldest event of any type when none is
+// queued, so a stalled consumer cannot grow memory forever.
+const maxPendingEvents = 4096
+
+// subscriber buffers events for one consumer. Progress events for an item
+// are coalesced while queued, so slow consumers see the latest numbers
+// without blocking downloads. State transitions are kept unless the backlog
+// fills up with nothing else.
+type subscriber struct {
+	ch      chan Event
+	mu      sync.Mutex
+	pending []Event
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+func (s *subscriber) push(ev Event) {
+	s.mu.Lock()
+	coalesced := false
+	if ev.Type == EventProgress {
+		for i := len(s.pending) - 1; i >= 0; i-- {
+			if s.pending[i].ItemID != ev.ItemID {
+				continue
+			}
+			if s.pending[i].Type == EventProgress {
+				s.pending[i] = ev
+				coalesced = true
+			}
+			break
+		}
+	}
+	if !coalesced {
+		if len(s.pending) >= maxPendingEvents {
+			s.dropOldest()
+		}
+		s.pending = append(s.pending, ev)
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dropOldest removes the oldest progress event from the backlog, or the
+// oldest event when it holds no progress. s.mu must be held.
+func (s *subscriber) dropOldest() {
+	for i, ev := range s.pending {
+		if ev.Type == EventProgress {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			return
+		}
+	}
+	s.pending = s.pending[1:]
+}
+
+func (s *subscriber) run() {
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		if len(s.pending) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.wake:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		ev := s.pending[0]
+		s.pending = s.pending[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Subscribe returns a channel of download events and a function that ends
+// the subscription and closes the channel. buffer sets the channel capacity;
+// events beyond it are held in a per-subscriber queue.
+func (m *Manager) Subscribe(buffer int) (<-chan Event, func()) {
+	if buffer < 0 {
+		buffer = 0
+	}
+	s := &subscriber{
+		ch:   make(chan Event, buffer),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	m.subMu.Lock()
+	m.subs = append(m.subs, s)
+	m.subMu.Unlock()
+	go s.run()
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			m.subMu.Lock()
+			for i, other := range m.subs {
+				if other == s {
+					m.subs = append(m.subs[:i], m.subs[i+1:]...)
+					break
+				}
+			}
+			m.subMu.Unlock()
+			close(s.done)
+		})
+	}
+}
+
+// emit publishes an event describing item's current state to all subscribers.
+func (m *Manager) emit(typ EventType, item *Item) {
+	item.Mu.Lock()
+	ev := Event{
+		Type:       typ,
+		Time:       time.Now(),
+		ItemID:     item.ID,
+		Name:       item.Name,
+		URL:        item.URL,
+		DestPath:   item.DestPath,
+		Status:     item.Status,
+		Err:        item.Error,
+		TotalBytes: item.TotalBytes,
+	}
+	item.Mu.Unlock()
+	ev.DoneBytes = item.DoneBytes.Load()
+	ev.Speed = item.Speed()
+	ev.Class = ClassifyError(ev.Err)
+
+	m.subMu.Lock()
+	subs := make([]*subscriber, len(m.subs))
+	copy(subs, m.subs)
+	m.subMu.Unlock()
+	for _, s := range subs {
+		s.push(ev)
+	}
+}
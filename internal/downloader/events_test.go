@@ -0,0 +1,69 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscriber_CoalescesProgressButKeepsTransitions(t *testing.T) {
+	s := &subscriber{
+		ch:   make(chan Event),
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	s.push(Event{Type: EventStarted, ItemID: 1})
+	s.push(Event{Type: EventProgress, ItemID: 1, DoneBytes: 10})
+	s.push(Event{Type: EventProgress, ItemID: 2, DoneBytes: 5})
+	s.push(Event{Type: EventProgress, ItemID: 1, DoneBytes: 20})
+	s.push(Event{Type: EventCompleted, ItemID: 1})
+	s.push(Event{Type: EventProgress, ItemID: 1, DoneBytes: 30})
+
+	go s.run()
+	defer close(s.done)
+
+	want := []Event{
+		{Type: EventStarted, ItemID: 1},
+		{Type: EventProgress, ItemID: 1, DoneBytes: 20},
+		{Type: EventProgress, ItemID: 2, DoneBytes: 5},
+		{Type: EventCompleted, ItemID: 1},
+		{Type: EventProgress, ItemID: 1, DoneBytes: 30},
+	}
+	for i, w := range want {
+		select {
+		case got := <-s.ch:
+			if got.Type != w.Type || got.ItemID != w.ItemID || got.DoneBytes != w.DoneBytes {
+				t.Fatalf("event %d: got %+v, want %+v", i, got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+}
+
+func TestSubscriber_FullBacklogDropsProgressFirst(t *testing.T) {
+	s := &subscriber{wake: make(chan struct{}, 1)}
+	s.push(Event{Type: EventStarted, ItemID: 1})
+	s.push(Event{Type: EventProgress, ItemID: 1})
+	for i := 2; len(s.pending) < maxPendingEvents; i++ {
+		s.push(Event{Type: EventEnqueued, ItemID: i})
+	}
+	s.push(Event{Type: EventCompleted, ItemID: 1})
+
+	if len(s.pending) != maxPendingEvents {
+		t.Fatalf("backlog = %d events, want %d", len(s.pending), maxPendingEvents)
+	}
+	if first := s.pending[0]; first.Type != EventStarted {
+		t.Errorf("first event = %v, want the start to be kept", first.Type)
+	}
+	for _, ev := range s.pending {
+		if ev.Type == EventProgress {
+			t.Fatal("progress event kept over a state transition")
+		}
+	}
+
+	// With no progress left to drop, the oldest event goes.
+	s.push(Event{Type: EventFailed, ItemID: 2})
+	if first := s.pending[0]; first.Type != EventEnqueued {
+		t.Errorf("first event = %v after overflow, want enqueued", first.Type)
+	}
+}
@@ -0,0 +1,132 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+)
+
+// ExistingPolicy decides what happens when a download's destination file is
+// already present on disk.
+type ExistingPolicy int
+
+const (
+	// ExistingSkip skips the download when the local file has the expected
+	// size. It never reads the file.
+	ExistingSkip ExistingPolicy = iota
+	// ExistingVerify skips the download only when the local file's hash
+	// matches the known hash, falling back to the size when no hash is
+	// known. Mismatching files are downloaded again.
+	ExistingVerify
+	// ExistingOverwrite always downloads and replaces the local file.
+	ExistingOverwrite
+)
+
+func (p ExistingPolicy) String() string {
+	switch p {
+	case ExistingSkip:
+		return "skip"
+	case ExistingVerify:
+		return "verify"
+	case ExistingOverwrite:
+		return "overwrite"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseExistingPolicy parses "skip", "verify" or "overwrite". An empty string
+// yields ExistingSkip.
+func ParseExistingPolicy(s string) (ExistingPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "skip":
+		return ExistingSkip, nil
+	case "verify":
+		return ExistingVerify, nil
+	case "overwrite":
+		return ExistingOverwrite, nil
+	}
+	return ExistingSkip, fmt.Errorf("unknown existing-file policy %q (want skip, verify or overwrite)", s)
+}
+
+// SetExistingPolicy sets how already-present destination files are handled.
+func (m *Manager) SetExistingPolicy(p ExistingPolicy) {
+	m.mu.Lock()
+	m.existing = p
+	m.mu.Unlock()
+}
+
+// errAlreadyPresent ends a transfer early because the destination already
+// holds the expected file.
+var errAlreadyPresent = errors.New("already present")
+
+// isPresent reports whether the item's destination already holds the file,
+// given the expected size (0 when unknown). A nil error with false means the
+// file has to be downloaded.
+func (m *Manager) isPresent(item *Item, size int64) (bool, error) {
+	m.mu.Lock()
+	policy := m.existing
+	m.mu.Unlock()
+	if policy == ExistingOverwrite {
+		return false, nil
+	}
+	if item.Extract {
+		return isExtracted(item, size), nil
+	}
+
+	info, err := os.Stat(item.DestPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return false, nil
+	}
+	if size > 0 && info.Size() != size {
+		return false, nil
+	}
+	if policy == ExistingVerify && item.Hash != "" {
+		ok, err := verifyHash(item.DestPath, item.Hash)
+		if err != nil {
+			return false, fmt.Errorf("verifying existing file: %w", err)
+		}
+		return ok, nil
+	}
+	return size > 0, nil
+}
+
+// verifyHash compares the file at path with want, written as "algo:hex"
+// (crc32, md5, sha1 or sha256).
+func verifyHash(path, want string) (bool, error) {
+	algo, sum, ok := strings.Cut(want, ":")
+	if !ok {
+		return false, fmt.Errorf("malformed hash %q", want)
+	}
+	var h hash.Hash
+	switch strings.ToLower(algo) {
+	case "crc32", "crc":
+		h = crc32.NewIEEE()
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return false, fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return strings.EqualFold(hex.EncodeToString(h.Sum(nil)), sum), nil
+}
@@ -0,0 +1,421 @@
+package downloader
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/zipstream"
+)
+
+// ExtractStateFile is kept in the directory a ZIP archive is extracted to.
+// It records how far the extraction got, so an interrupted download resumes
+// at the next member, and marks the archive as extracted once complete.
+const ExtractStateFile = ".myrient-extract.json"
+
+// extractingSuffix is appended to a member while it is being written.
+const extractingSuffix = ".extracting"
+
+// extractState is the content of ExtractStateFile.
+type extractState struct {
+	URL  string `json:"url"`
+	Size int64  `json:"size,omitempty"`
+	// Offset is where the next member to extract starts in the archive.
+	// With Fallback set, the rest of the archive from Offset on is being
+	// downloaded into the .part file, to be extracted once complete.
+	Offset   int64    `json:"offset"`
+	Fallback bool     `json:"fallback,omitempty"`
+	Files    []string `json:"files"`
+	Done     bool     `json:"done,omitempty"`
+}
+
+// ExtractDir returns the directory an archive downloaded to dest is
+// extracted into: dest without its extension.
+func ExtractDir(dest string) string {
+	return strings.TrimSuffix(dest, filepath.Ext(dest))
+}
+
+// Extracting reports whether dest is an archive whose extraction has started
+// but not finished. Its .part file, if any, is not a plain partial download.
+func Extracting(dest string) bool {
+	st, err := loadExtractState(ExtractDir(dest))
+	return err == nil && st != nil && !st.Done
+}
+
+// ExtractedFrom reports the URL of the archive whose extraction into dir
+// has finished, and its size when known.
+func ExtractedFrom(dir string) (url string, size int64, ok bool) {
+	st, err := loadExtractState(dir)
+	if err != nil || st == nil || !st.Done {
+		return "", 0, false
+	}
+	return st.URL, st.Size, true
+}
+
+func isZipName(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".zip")
+}
+
+// SetExtract controls whether ZIP archives added from now on are extracted
+// while they download instead of being saved as archives.
+func (m *Manager) SetExtract(enabled bool) {
+	m.mu.Lock()
+	m.extract = enabled
+	m.mu.Unlock()
+}
+
+func loadExtractState(dir string) (*extractState, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ExtractStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st extractState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("reading extraction state: %w", err)
+	}
+	return &st, nil
+}
+
+// save writes the state atomically, so a crash leaves either the old or the
+// new progress.
+func (st *extractState) save(dir string) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, ExtractStateFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		if isDiskFull(err) {
+			return fmt.Errorf("saving extraction state: %w", ErrInsufficientSpace)
+		}
+		return fmt.Errorf("saving extraction state: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("saving extraction state: %w", err)
+	}
+	return nil
+}
+
+// isExtracted reports whether the item's archive was already extracted
+// completely from the same URL and, when known, the same size.
+func isExtracted(item *Item, size int64) bool {
+	st, err := loadExtractState(ExtractDir(item.DestPath))
+	if err != nil || st == nil || !st.Done || st.URL != item.URL {
+		return false
+	}
+	return size <= 0 || st.Size <= 0 || st.Size == size
+}
+
+// downloadExtract streams a ZIP archive and writes its members into the
+// extraction directory as they arrive, so the archive itself never has to
+// fit on disk. After every member the position is saved, and a resumed
+// transfer requests the archive from the next member on. When a member
+// cannot be streamed, the rest of the archive is downloaded into the .part
+// file at its original offsets and extracted once complete.
+func (m *Manager) downloadExtract(ctx context.Context, item *Item) error {
+	dir := ExtractDir(item.DestPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+	st, err := loadExtractState(dir)
+	if err != nil {
+		return err
+	}
+	if st == nil || st.URL != item.URL || st.Done {
+		st = &extractState{URL: item.URL}
+	}
+	if st.Fallback {
+		return m.fallbackExtract(ctx, item, dir, st)
+	}
+
+	body, contentLength, resumed, err := m.client.DownloadFile(ctx, item.URL, st.Offset)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if contentLength > 0 {
+		if resumed {
+			item.setTotal(st.Offset + contentLength)
+		} else {
+			item.setTotal(contentLength)
+		}
+		st.Size = item.TotalBytes
+		if err := m.checkSpace(item, item.TotalBytes-st.Offset); err != nil {
+			return err
+		}
+	}
+	if st.Offset > 0 && !resumed {
+		// The server ignored the range; skip what was already extracted.
+		if _, err := io.CopyN(io.Discard, body, st.Offset); err != nil {
+			return fmt.Errorf("reading response: %w", err)
+		}
+	}
+	item.DoneBytes.Store(st.Offset)
+	item.meter.reset(st.Offset, time.Now())
+
+	zr := zipstream.NewReader(m.progressReader(ctx, item, body), st.Offset)
+	for {
+		h, err := zr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, zipstream.ErrUnstreamable) {
+			st.Offset, st.Fallback = h.Offset, true
+			if err := st.save(dir); err != nil {
+				return err
+			}
+			return m.fallbackWrite(item, dir, st, st.Offset, zr.Rest())
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+		rel, err := m.extractMember(dir, h.Name, h.IsDir(), int64(h.UncompressedSize), h.Modified, zr.Open(h))
+		if err != nil {
+			return err
+		}
+		st.Offset = zr.Offset()
+		st.Files = append(st.Files, rel)
+		if err := st.save(dir); err != nil {
+			return err
+		}
+	}
+	st.Done = true
+	if item.TotalBytes > 0 {
+		item.DoneBytes.Store(item.TotalBytes)
+	}
+	return st.save(dir)
+}
+
+// fallbackExtract continues a fallback download after an interruption.
+// The .part file holds the archive from st.Offset on; bytes before that
+// are a hole and belong to members that were already extracted.
+func (m *Manager) fallbackExtract(ctx context.Context, item *Item, dir string, st *extractState) error {
+	from := st.Offset
+	if info, err := os.Stat(item.DestPath + ".part"); err == nil && info.Size() > from {
+		from = info.Size()
+	}
+	body, contentLength, resumed, err := m.client.DownloadFile(ctx, item.URL, from)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if contentLength > 0 {
+		if resumed {
+			item.setTotal(from + contentLength)
+		} else {
+			item.setTotal(contentLength)
+		}
+		st.Size = item.TotalBytes
+	}
+	if !resumed {
+		if _, err := io.CopyN(io.Discard, body, st.Offset); err != nil {
+			return fmt.Errorf("reading response: %w", err)
+		}
+		from = st.Offset
+	}
+	item.DoneBytes.Store(from)
+	item.meter.reset(from, time.Now())
+	return m.fallbackWrite(item, dir, st, from, m.progressReader(ctx, item, body))
+}
+
+// fallbackWrite writes r into the .part file from archive offset from on,
+// then extracts the members not extracted yet and removes the .part file.
+// r must already count its bytes into the item's progress, as the rest of
+// a zipstream reader built on progressReader does.
+func (m *Manager) fallbackWrite(item *Item, dir string, st *extractState, from int64, r io.Reader) error {
+	partPath := item.DestPath + ".part"
+	f, err := os.OpenFile(partPath, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer f.Close()
+	// Truncating to the start offset leaves a hole where the streamed
+	// members were, which takes no space on most filesystems.
+	if err := f.Truncate(from); err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	if _, err := f.Seek(from, io.SeekStart); err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	m.mu.Lock()
+	bufSize := m.bufSize
+	m.mu.Unlock()
+	if _, err := io.CopyBuffer(diskWriter{f}, r, make([]byte, bufSize)); err != nil {
+		return err
+	}
+	if item.TotalBytes > 0 {
+		if info, err := f.Stat(); err == nil && info.Size() != item.TotalBytes {
+			return fmt.Errorf("%w: got %d of %d bytes", errIncomplete, info.Size(), item.TotalBytes)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing file: %w", err)
+	}
+
+	if err := m.extractPart(partPath, dir, st); err != nil {
+		return err
+	}
+	st.Done = true
+	if err := st.save(dir); err != nil {
+		return err
+	}
+	os.Remove(partPath)
+	return nil
+}
+
+// extractPart extracts the members of the archive at partPath that are not
+// listed in st.Files yet.
+func (m *Manager) extractPart(partPath, dir string, st *extractState) error {
+	f, err := os.Open(partPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+	done := make(map[string]bool, len(st.Files))
+	for _, rel := range st.Files {
+		done[rel] = true
+	}
+	for _, zf := range zr.File {
+		if rel, err := m.memberPath(zf.Name); err == nil && done[rel] {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+		rel, err := m.extractMember(dir, zf.Name, zf.FileInfo().IsDir(), int64(zf.UncompressedSize64), zf.Modified, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		st.Files = append(st.Files, rel)
+		if err := st.save(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memberPath returns the relative path a member is extracted to, with the
+// filesystem profile applied.
+func (m *Manager) memberPath(name string) (string, error) {
+	rel, err := zipstream.LocalPath(name)
+	if err != nil {
+		return "", err
+	}
+	m.mu.Lock()
+	profile := m.fsProfile
+	m.mu.Unlock()
+	return profile.Subdir(rel), nil
+}
+
+// extractMember writes one archive member below dir and returns its
+// relative path. Files are written under a temporary name and renamed once
+// their contents checked out.
+func (m *Manager) extractMember(dir, name string, isDir bool, size int64, modified time.Time, r io.Reader) (string, error) {
+	rel, err := m.memberPath(name)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, filepath.FromSlash(rel))
+	if isDir {
+		if err := os.MkdirAll(target, 0o755); err != nil {
+			return "", fmt.Errorf("creating directory: %w", err)
+		}
+		return rel, nil
+	}
+	m.mu.Lock()
+	profile, bufSize := m.fsProfile, m.bufSize
+	m.mu.Unlock()
+	if err := profile.CheckSize(size); err != nil {
+		return "", fmt.Errorf("%s: %w", rel, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", fmt.Errorf("creating directory: %w", err)
+	}
+
+	tmp := target + extractingSuffix
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("opening file: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.CopyBuffer(diskWriter{f}, r, make([]byte, bufSize)); err != nil {
+		return "", err
+	}
+	if err := finalizePart(f, tmp, target, size); err != nil {
+		return "", err
+	}
+	if !modified.IsZero() {
+		_ = os.Chtimes(target, time.Now(), modified)
+	}
+	return rel, nil
+}
+
+// diskWriter reports a full disk as ErrInsufficientSpace.
+type diskWriter struct {
+	f *os.File
+}
+
+func (w diskWriter) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	if err != nil {
+		if isDiskFull(err) {
+			return n, fmt.Errorf("writing file: %w", ErrInsufficientSpace)
+		}
+		return n, fmt.Errorf("writing file: %w", err)
+	}
+	return n, nil
+}
+
+// progressReader counts the bytes read from r towards the item's progress
+// and stops when ctx ends.
+func (m *Manager) progressReader(ctx context.Context, item *Item, r io.Reader) io.Reader {
+	return &itemProgress{ctx: ctx, m: m, item: item, r: r}
+}
+
+type itemProgress struct {
+	ctx  context.Context
+	m    *Manager
+	item *Item
+	r    io.Reader
+	last time.Time
+}
+
+func (p *itemProgress) Read(b []byte) (int, error) {
+	if err := p.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := p.r.Read(b)
+	if n > 0 {
+		done := p.item.DoneBytes.Add(int64(n))
+		now := time.Now()
+		p.item.meter.sample(done, now)
+		if now.Sub(p.last) >= progressInterval {
+			p.last = now
+			p.m.emit(EventProgress, p.item)
+		}
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, fmt.Errorf("reading response: %w", err)
+	}
+	return n, err
+}
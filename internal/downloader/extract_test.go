@@ -0,0 +1,215 @@
+package downloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+)
+
+type zipMember struct {
+	name       string
+	data       []byte
+	descriptor bool
+}
+
+// buildZip writes stored members, with a data descriptor where asked, and
+// returns the archive and the offset of each member's local header.
+func buildZip(t *testing.T, members []zipMember) ([]byte, []int64) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	var offsets []int64
+	for _, mb := range members {
+		w.Flush()
+		offsets = append(offsets, int64(buf.Len()))
+		var fw interface{ Write([]byte) (int, error) }
+		var err error
+		if mb.descriptor {
+			fw, err = w.CreateHeader(&zip.FileHeader{Name: mb.name, Method: zip.Store})
+		} else {
+			fw, err = w.CreateRaw(&zip.FileHeader{
+				Name:               mb.name,
+				Method:             zip.Store,
+				CRC32:              crc32.ChecksumIEEE(mb.data),
+				CompressedSize64:   uint64(len(mb.data)),
+				UncompressedSize64: uint64(len(mb.data)),
+			})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(mb.data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), offsets
+}
+
+// serveArchive serves data with range support and records the Range
+// headers it receives.
+func serveArchive(t *testing.T, data []byte) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/zip")
+		http.ServeContent(w, r, "set.zip", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), ranges...)
+	}
+}
+
+func waitFinished(t *testing.T, events <-chan Event, id int) Event {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.ItemID == id && (ev.Type == EventCompleted || ev.Type == EventFailed) {
+				return ev
+			}
+		case <-deadline:
+			t.Fatal("download did not finish")
+		}
+	}
+}
+
+func TestDownloadExtract_ResumesAtNextMember(t *testing.T) {
+	members := []zipMember{
+		{name: "Disc 1.bin", data: bytes.Repeat([]byte{1}, 4000)},
+		{name: "Disc 2.bin", data: bytes.Repeat([]byte{2}, 4000)},
+		{name: "sub/Set.cue", data: []byte("FILE \"Disc 1.bin\" BINARY\n")},
+	}
+	archive, offsets := buildZip(t, members)
+	srv, ranges := serveArchive(t, archive)
+
+	// An earlier session extracted the first member and was interrupted
+	// while writing the second.
+	dir := t.TempDir()
+	extractDir := filepath.Join(dir, "Set")
+	os.MkdirAll(extractDir, 0o755)
+	os.WriteFile(filepath.Join(extractDir, "Disc 1.bin"), members[0].data, 0o644)
+	os.WriteFile(filepath.Join(extractDir, "Disc 2.bin"+extractingSuffix), []byte{2, 2}, 0o644)
+	st := &extractState{URL: srv.URL + "/Set.zip", Offset: offsets[1], Files: []string{"Disc 1.bin"}}
+	if err := st.save(extractDir); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(client.New(srv.URL+"/", 100), dir, 1)
+	events, unsubscribe := m.Subscribe(64)
+	defer unsubscribe()
+	item, _ := m.Add(Request{Name: "Set.zip", URL: srv.URL + "/Set.zip", Extract: true})
+	if ev := waitFinished(t, events, item.ID); ev.Type != EventCompleted {
+		t.Fatalf("download failed: %v", ev.Err)
+	}
+
+	if got := ranges(); len(got) != 1 || got[0] != "bytes="+strconv.FormatInt(offsets[1], 10)+"-" {
+		t.Errorf("requests = %q, want one range from offset %d", got, offsets[1])
+	}
+	for _, mb := range members {
+		data, err := os.ReadFile(filepath.Join(extractDir, filepath.FromSlash(mb.name)))
+		if err != nil || !bytes.Equal(data, mb.data) {
+			t.Errorf("%s: %v, contents match %v", mb.name, err, bytes.Equal(data, mb.data))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Set.zip")); !os.IsNotExist(err) {
+		t.Error("archive was kept")
+	}
+	if _, err := os.Stat(filepath.Join(extractDir, "Disc 2.bin"+extractingSuffix)); !os.IsNotExist(err) {
+		t.Error("temporary member was left behind")
+	}
+	if !isExtracted(item, int64(len(archive))) {
+		t.Error("archive not marked as extracted")
+	}
+}
+
+func TestDownloadExtract_FallsBackForDataDescriptors(t *testing.T) {
+	members := []zipMember{
+		{name: "a.txt", data: []byte("streamed")},
+		{name: "b.txt", data: []byte("needs the central directory"), descriptor: true},
+		{name: "c.txt", data: []byte("after the fallback")},
+	}
+	archive, _ := buildZip(t, members)
+	srv, _ := serveArchive(t, archive)
+
+	dir := t.TempDir()
+	m := NewManager(client.New(srv.URL+"/", 100), dir, 1)
+	events, unsubscribe := m.Subscribe(64)
+	defer unsubscribe()
+	item, _ := m.Add(Request{Name: "Set.zip", URL: srv.URL + "/Set.zip", Extract: true})
+	if ev := waitFinished(t, events, item.ID); ev.Type != EventCompleted {
+		t.Fatalf("download failed: %v", ev.Err)
+	}
+
+	for _, mb := range members {
+		data, err := os.ReadFile(filepath.Join(dir, "Set", mb.name))
+		if err != nil || !bytes.Equal(data, mb.data) {
+			t.Errorf("%s: %v, contents match %v", mb.name, err, bytes.Equal(data, mb.data))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "Set.zip.part")); !os.IsNotExist(err) {
+		t.Error("fallback .part file was kept")
+	}
+	raw, _ := os.ReadFile(filepath.Join(dir, "Set", ExtractStateFile))
+	var st extractState
+	json.Unmarshal(raw, &st)
+	if !st.Done || !st.Fallback || len(st.Files) != 3 {
+		t.Errorf("state = %+v", st)
+	}
+	if done := item.DoneBytes.Load(); done != item.TotalBytes || done != int64(len(archive)) {
+		t.Errorf("progress = %d of %d bytes, archive has %d", done, item.TotalBytes, len(archive))
+	}
+}
+
+func TestPlaylist_WrittenWhenSetCompletes(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(client.New("http://127.0.0.1:1/", 100), dir, 2)
+	events, unsubscribe := m.Subscribe(64)
+	defer unsubscribe()
+
+	var items []*Item
+	for _, name := range []string{"Game (USA) (Disc 1).iso", "Game (USA) (Disc 2).iso"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("disc"), 0o644)
+		item, _ := m.Add(Request{Name: name, URL: "http://127.0.0.1:1/" + name, Size: 4, Playlist: "Game (USA).m3u"})
+		items = append(items, item)
+	}
+	deadline := time.After(5 * time.Second)
+	for finished := 0; finished < len(items); {
+		select {
+		case ev := <-events:
+			switch ev.Type {
+			case EventCompleted:
+				finished++
+			case EventFailed:
+				t.Fatalf("%s: %v", ev.Name, ev.Err)
+			}
+		case <-deadline:
+			t.Fatal("downloads did not finish")
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "Game (USA).m3u"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Game (USA) (Disc 1).iso\nGame (USA) (Disc 2).iso\n"; string(data) != want {
+		t.Errorf("playlist = %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,235 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// FSProfile describes the naming rules and file size limit of the
+// filesystem downloads are stored on.
+type FSProfile int
+
+const (
+	// FSPosix only keeps names below 255 bytes; every other character is
+	// allowed.
+	FSPosix FSProfile = iota
+	// FSWindows replaces characters Windows and SMB shares reject, trims
+	// trailing dots and spaces, avoids reserved device names and keeps
+	// names below 255 UTF-16 units.
+	FSWindows
+	// FSFAT32 applies the Windows rules and refuses files of 4 GiB or more.
+	FSFAT32
+	// FSExFAT applies the Windows rules.
+	FSExFAT
+)
+
+// ErrTooLarge is reported when a file exceeds the size limit of the
+// destination's filesystem profile.
+var ErrTooLarge = errors.New("file too large for filesystem")
+
+// NamesFile is written next to downloads stored under a name that differs
+// from the remote one. Each line holds the stored and the original name,
+// separated by a tab.
+const NamesFile = ".myrient-names.tsv"
+
+// maxNameLen is the component length limit shared by all profiles, in
+// bytes for posix and in UTF-16 units otherwise.
+const maxNameLen = 255
+
+// partReserve keeps room for the ".part" suffix used while downloading.
+const partReserve = len(".part")
+
+func (p FSProfile) String() string {
+	switch p {
+	case FSPosix:
+		return "posix"
+	case FSWindows:
+		return "windows"
+	case FSFAT32:
+		return "fat32"
+	case FSExFAT:
+		return "exfat"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseFSProfile parses "posix", "windows", "fat32" or "exfat". An empty
+// string yields FSPosix.
+func ParseFSProfile(s string) (FSProfile, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "posix":
+		return FSPosix, nil
+	case "windows", "smb", "ntfs":
+		return FSWindows, nil
+	case "fat32", "vfat":
+		return FSFAT32, nil
+	case "exfat":
+		return FSExFAT, nil
+	}
+	return FSPosix, fmt.Errorf("unknown filesystem profile %q (want posix, windows, fat32 or exfat)", s)
+}
+
+// MaxFileSize returns the largest file the profile can store, or 0 when
+// there is no practical limit.
+func (p FSProfile) MaxFileSize() int64 {
+	if p == FSFAT32 {
+		return 1<<32 - 1
+	}
+	return 0
+}
+
+// CheckSize returns ErrTooLarge when size exceeds the profile's limit.
+func (p FSProfile) CheckSize(size int64) error {
+	if limit := p.MaxFileSize(); limit > 0 && size > limit {
+		return fmt.Errorf("%w: %d bytes exceeds the %s limit of %d bytes", ErrTooLarge, size, p, limit)
+	}
+	return nil
+}
+
+var windowsReplacer = strings.NewReplacer(
+	": ", " - ",
+	":", "-",
+	`"`, "'",
+	"<", "(",
+	">", ")",
+	"|", "-",
+	"?", "",
+	"*", "_",
+	`\`, "-",
+	"/", "-",
+)
+
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Name returns the stored form of a single path component. The result is
+// deterministic: the same name always maps to the same stored name, and
+// names that had characters replaced or had to be shortened keep a short
+// hash of the original so distinct names stay distinct, e.g. "A:B" and
+// "A-B". Names that already fit are unchanged.
+func (p FSProfile) Name(name string) string {
+	stored := strings.ReplaceAll(name, "/", "-")
+	if p != FSPosix {
+		stored = strings.Map(func(r rune) rune {
+			if r < 0x20 || r == 0x7f {
+				return '_'
+			}
+			return r
+		}, windowsReplacer.Replace(stored))
+		stored = strings.TrimRight(stored, ". ")
+		base, _, _ := strings.Cut(stored, ".")
+		if reservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+			stored = "_" + stored
+		}
+		if stored == "" {
+			stored = "_"
+		}
+	}
+	if stored != name || p.length(stored) > maxNameLen-partReserve {
+		stored = p.withHash(stored, name)
+	}
+	return stored
+}
+
+// Subdir applies Name to every component of a slash-separated directory.
+func (p FSProfile) Subdir(subdir string) string {
+	if subdir == "" {
+		return ""
+	}
+	segs := strings.Split(subdir, "/")
+	for i, s := range segs {
+		segs[i] = p.Name(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+// length measures a name the way the profile's filesystem does.
+func (p FSProfile) length(s string) int {
+	if p == FSPosix {
+		return len(s)
+	}
+	n := 0
+	for _, r := range s {
+		n++
+		if r > 0xffff {
+			n++
+		}
+	}
+	return n
+}
+
+// withHash appends a hash of the original name to stored, keeping its
+// extension, and cuts it to fit.
+func (p FSProfile) withHash(stored, original string) string {
+	ext := filepath.Ext(stored)
+	if p.length(ext) > 16 {
+		ext = ""
+	}
+	suffix := fmt.Sprintf("~%08x", crc32.ChecksumIEEE([]byte(original))) + ext
+	base := strings.TrimSuffix(stored, ext)
+	room := maxNameLen - partReserve - p.length(suffix)
+	for p.length(base) > room {
+		_, size := utf8.DecodeLastRuneInString(base)
+		base = base[:len(base)-size]
+	}
+	return strings.TrimRight(base, ". ") + suffix
+}
+
+// SetFSProfile sets the filesystem profile applied to the names of items
+// added from now on.
+func (m *Manager) SetFSProfile(p FSProfile) {
+	m.mu.Lock()
+	m.fsProfile = p
+	m.mu.Unlock()
+}
+
+// recordStoredName notes a renamed item in the NamesFile of its directory.
+func (m *Manager) recordStoredName(item *Item) {
+	item.Mu.Lock()
+	dest, original := item.DestPath, item.OriginalName
+	item.Mu.Unlock()
+	if original == "" {
+		return
+	}
+	line := filepath.Base(dest) + "\t" + original + "\n"
+	path := filepath.Join(filepath.Dir(dest), NamesFile)
+
+	m.namesMu.Lock()
+	defer m.namesMu.Unlock()
+	if data, err := os.ReadFile(path); err == nil && strings.Contains("\n"+string(data), "\n"+line) {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return
+	}
+	f.WriteString(line)
+	f.Close()
+}
+
+// StoredNames reads the NamesFile of dir and maps each stored file name to
+// the remote name it was downloaded as. It returns nil when dir has none.
+func StoredNames(dir string) map[string]string {
+	data, err := os.ReadFile(filepath.Join(dir, NamesFile))
+	if err != nil {
+		return nil
+	}
+	names := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if stored, original, ok := strings.Cut(line, "\t"); ok {
+			names[stored] = original
+		}
+	}
+	return names
+}
@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFSProfile_Name(t *testing.T) {
+	cases := []struct {
+		profile FSProfile
+		in      string
+		// want is the stored name before the hash of the original, which
+		// renamed names get in front of the extension.
+		want    string
+		renamed bool
+	}{
+		{FSPosix, `Zelda: Link's "Awakening"?.zip`, `Zelda: Link's "Awakening"?.zip`, false},
+		{FSWindows, `Zelda: Link's "Awakening"?.zip`, `Zelda - Link's 'Awakening'.zip`, true},
+		{FSFAT32, "Game (USA) <Beta> | Demo*.7z", "Game (USA) (Beta) - Demo_.7z", true},
+		{FSExFAT, "Trailing dots...", "Trailing dots", true},
+		{FSWindows, "CON.zip", "_CON.zip", true},
+		{FSWindows, "Con Air (USA).zip", "Con Air (USA).zip", false},
+		{FSWindows, "???", "_", true},
+	}
+	for _, tc := range cases {
+		want := tc.want
+		if tc.renamed {
+			ext := filepath.Ext(want)
+			want = strings.TrimSuffix(want, ext) + fmt.Sprintf("~%08x", crc32.ChecksumIEEE([]byte(tc.in))) + ext
+		}
+		if got := tc.profile.Name(tc.in); got != want {
+			t.Errorf("%s.Name(%q) = %q, want %q", tc.profile, tc.in, got, want)
+		}
+	}
+
+	// Replacing characters alone must not make distinct names collide.
+	if a, b := FSWindows.Name("A:B.zip"), FSWindows.Name("A-B.zip"); a == b {
+		t.Errorf("A:B.zip and A-B.zip are both stored as %q", a)
+	}
+}
+
+func TestFSProfile_ShortensDeterministically(t *testing.T) {
+	long := strings.Repeat("Ω", 300) + " (Japan).zip"
+	other := strings.Repeat("Ω", 300) + " (Europe).zip"
+	for _, p := range []FSProfile{FSPosix, FSWindows, FSFAT32, FSExFAT} {
+		got := p.Name(long)
+		if p.length(got)+partReserve > maxNameLen {
+			t.Errorf("%s: %d units is too long", p, p.length(got))
+		}
+		if !utf8.ValidString(got) || !strings.HasSuffix(got, ".zip") {
+			t.Errorf("%s: shortened name %q lost its extension or broke UTF-8", p, got)
+		}
+		if again := p.Name(long); again != got {
+			t.Errorf("%s: not deterministic: %q then %q", p, got, again)
+		}
+		if p.Name(got) != got {
+			t.Errorf("%s: stored name %q changes when applied again", p, got)
+		}
+		if p.Name(other) == got {
+			t.Errorf("%s: distinct names collide on %q", p, got)
+		}
+	}
+}
+
+func TestFSProfile_CheckSize(t *testing.T) {
+	if err := FSFAT32.CheckSize(4 << 30); !errors.Is(err, ErrTooLarge) || ClassifyError(err) != ClassTooLarge {
+		t.Errorf("fat32 accepted a 4 GiB file: %v", err)
+	}
+	if err := FSFAT32.CheckSize(4<<30 - 1); err != nil {
+		t.Errorf("fat32 refused a file below 4 GiB: %v", err)
+	}
+	if err := FSExFAT.CheckSize(1 << 40); err != nil {
+		t.Errorf("exfat refused a large file: %v", err)
+	}
+}
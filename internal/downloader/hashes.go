@@ -0,0 +1,88 @@
+package downloader
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// Hashes holds the checksums of a downloaded file as lowercase hex.
+type Hashes struct {
+	CRC32 string `json:"crc32,omitempty"`
+	MD5   string `json:"md5,omitempty"`
+	SHA1  string `json:"sha1,omitempty"`
+}
+
+// IsZero reports whether no checksum is known.
+func (h Hashes) IsZero() bool {
+	return h == Hashes{}
+}
+
+// fileHasher computes every Hashes checksum in one pass over the data.
+type fileHasher struct {
+	crc  hash.Hash32
+	md5  hash.Hash
+	sha1 hash.Hash
+	w    io.Writer
+}
+
+func newFileHasher() *fileHasher {
+	h := &fileHasher{crc: crc32.NewIEEE(), md5: md5.New(), sha1: sha1.New()}
+	h.w = io.MultiWriter(h.crc, h.md5, h.sha1)
+	return h
+}
+
+func (h *fileHasher) Write(p []byte) (int, error) {
+	return h.w.Write(p)
+}
+
+// seed feeds the first n bytes of the file at path, the part of a resumed
+// download that is already on disk.
+func (h *fileHasher) seed(path string, n int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(h.w, f, n)
+	return err
+}
+
+func (h *fileHasher) sums() Hashes {
+	return Hashes{
+		CRC32: hex.EncodeToString(h.crc.Sum(nil)),
+		MD5:   hex.EncodeToString(h.md5.Sum(nil)),
+		SHA1:  hex.EncodeToString(h.sha1.Sum(nil)),
+	}
+}
+
+// SetFinishHook sets a function that receives the final state of every
+// download that completes or fails for good. Cancelled, skipped and held
+// downloads are not reported. The hook runs on the download's goroutine
+// before the matching event is published, so it must not block for long.
+func (m *Manager) SetFinishHook(fn func(Snapshot)) {
+	m.mu.Lock()
+	m.finishHook = fn
+	m.mu.Unlock()
+}
+
+// reportFinished hands a finished item to the finish hook, if any.
+func (m *Manager) reportFinished(item *Item) {
+	m.mu.Lock()
+	fn := m.finishHook
+	m.mu.Unlock()
+	if fn == nil {
+		return
+	}
+	item.Mu.Lock()
+	status, err := item.Status, item.Error
+	item.Mu.Unlock()
+	if status != StatusCompleted && (status != StatusFailed || err == errCancelled) {
+		return
+	}
+	fn(item.Snapshot())
+}
@@ -0,0 +1,54 @@
+package downloader
+
+import "fmt"
+
+// The enums below encode as their String form in JSON so the daemon API is
+// readable by other tools.
+
+// MarshalText implements encoding.TextMarshaler.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Status) UnmarshalText(text []byte) error {
+	for v := StatusQueued; v <= StatusPresent; v++ {
+		if v.String() == string(text) {
+			*s = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown download status %q", text)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c ErrorClass) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *ErrorClass) UnmarshalText(text []byte) error {
+	for v := ClassNone; v <= ClassOther; v++ {
+		if v.String() == string(text) {
+			*c = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown error class %q", text)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *EventType) UnmarshalText(text []byte) error {
+	for v := EventEnqueued; v <= EventRemoved; v++ {
+		if v.String() == string(text) {
+			*t = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event type %q", text)
+}
@@ -0,0 +1,22 @@
+package downloader
+
+import (
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/config"
+)
+
+// ApplyConfig copies the user's download tuning settings onto the manager.
+func (m *Manager) ApplyConfig(cfg *config.Config) {
+	m.SetMinFreeSpace(cfg.MinFreeSpaceMB << 20)
+	m.SetPreallocate(cfg.PreallocateFiles)
+	m.SetBufferSize(cfg.WriteBufferKB * 1024)
+	m.SetRetryPolicy(cfg.MaxRetries, time.Duration(cfg.RetryBackoffSeconds)*time.Second)
+	if policy, err := ParseExistingPolicy(cfg.ExistingFiles); err == nil {
+		m.SetExistingPolicy(policy)
+	}
+	if profile, err := ParseFSProfile(cfg.FSProfile); err == nil {
+		m.SetFSProfile(profile)
+	}
+	m.SetExtract(cfg.ExtractArchives)
+}
@@ -0,0 +1,60 @@
+package downloader
+
+import (
+	"path/filepath"
+
+	"github.com/JohnDeved/myrient-cli/internal/discset"
+)
+
+// writePlaylist writes the M3U playlist of item's disc set once every disc
+// queued with the same playlist has finished. Each disc is listed by its
+// extracted image when the archive was extracted, by its file otherwise.
+func (m *Manager) writePlaylist(item *Item) {
+	item.Mu.Lock()
+	playlist := item.Playlist
+	item.Mu.Unlock()
+	if playlist == "" {
+		return
+	}
+
+	m.mu.Lock()
+	var discs []*Item
+	for _, it := range m.items {
+		it.Mu.Lock()
+		same := it.Playlist == playlist
+		done := it.Status == StatusCompleted || it.Status == StatusPresent
+		it.Mu.Unlock()
+		if !same {
+			continue
+		}
+		if !done {
+			m.mu.Unlock()
+			return
+		}
+		discs = append(discs, it)
+	}
+	m.mu.Unlock()
+
+	dir := filepath.Dir(playlist)
+	entries := make([]string, 0, len(discs))
+	for _, it := range discs {
+		target := it.DestPath
+		if it.Extract {
+			extracted := ExtractDir(it.DestPath)
+			image := discset.ImageFile(extracted)
+			if image == "" {
+				continue
+			}
+			target = filepath.Join(extracted, filepath.FromSlash(image))
+		}
+		rel, err := filepath.Rel(dir, target)
+		if err != nil {
+			rel = target
+		}
+		entries = append(entries, rel)
+	}
+	if len(entries) == 0 {
+		return
+	}
+	_ = discset.WritePlaylist(playlist, entries)
+}
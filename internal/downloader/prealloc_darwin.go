@@ -0,0 +1,27 @@
+//go:build darwin
+
+package downloader
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves disk blocks for f up to size bytes. F_PREALLOCATE does
+// not change the file's length, so resume offsets stay correct.
+func preallocate(f *os.File, size int64) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if size <= info.Size() {
+		return nil
+	}
+	store := unix.Fstore_t{
+		Flags:   unix.F_ALLOCATEALL,
+		Posmode: unix.F_PEOFPOSMODE,
+		Length:  size - info.Size(),
+	}
+	return unix.FcntlFstore(f.Fd(), unix.F_PREALLOCATE, &store)
+}
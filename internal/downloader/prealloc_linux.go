@@ -0,0 +1,15 @@
+//go:build linux
+
+package downloader
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves disk blocks for f up to size bytes without changing
+// its apparent length, so resume offsets stay tied to bytes actually written.
+func preallocate(f *os.File, size int64) error {
+	return unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_KEEP_SIZE, 0, size)
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package downloader
+
+import (
+	"errors"
+	"os"
+)
+
+// preallocate reserves nothing on platforms without a size-preserving
+// allocation call.
+func preallocate(f *os.File, size int64) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,86 @@
+package downloader
+
+import (
+	"time"
+)
+
+// Attempt records the outcome of one failed transfer attempt.
+type Attempt struct {
+	At    time.Time
+	Err   error
+	Class ErrorClass
+}
+
+// Retryable reports whether failures of this class are worth retrying
+// automatically. Missing files, refusals and local disk problems are not.
+func (c ErrorClass) Retryable() bool {
+	return c == ClassNetwork || c == ClassServer
+}
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 5 * time.Second
+	maxRetryBackoff     = 5 * time.Minute
+)
+
+// SetRetryPolicy sets how many automatic retries a retryable failure gets and
+// the initial backoff, which doubles after each attempt.
+func (m *Manager) SetRetryPolicy(maxRetries int, backoff time.Duration) {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	m.mu.Lock()
+	m.maxRetries = maxRetries
+	m.retryBackoff = backoff
+	m.mu.Unlock()
+}
+
+// retryDelay returns the backoff before the given retry (1-based).
+func retryDelay(base time.Duration, retry int) time.Duration {
+	d := base
+	for i := 1; i < retry; i++ {
+		d *= 2
+		if d >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return d
+}
+
+// scheduleRetry decides whether a failed item gets another automatic attempt
+// under the given retry policy. It must be called with item.Mu held, and
+// without m.mu, and returns true when a retry was scheduled, leaving the item
+// in StatusRetryWait.
+func (m *Manager) scheduleRetry(item *Item, class ErrorClass, maxRetries int, base time.Duration) bool {
+	if !class.Retryable() {
+		return false
+	}
+
+	retry := item.Attempts // attempts so far; the next one is retry number Attempts
+	if retry > maxRetries {
+		return false
+	}
+	delay := retryDelay(base, retry)
+	item.Status = StatusRetryWait
+	item.NextRetry = time.Now().Add(delay)
+	time.AfterFunc(delay, func() { m.fireRetry(item) })
+	return true
+}
+
+// fireRetry re-queues an item whose backoff elapsed, unless it was paused or
+// cancelled in the meantime.
+func (m *Manager) fireRetry(item *Item) {
+	item.Mu.Lock()
+	if item.Status != StatusRetryWait {
+		item.Mu.Unlock()
+		return
+	}
+	item.Status = StatusQueued
+	item.NextRetry = time.Time{}
+	item.Mu.Unlock()
+	m.emit(EventEnqueued, item)
+	m.processItem(item)
+}
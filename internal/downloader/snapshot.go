@@ -0,0 +1,88 @@
+package downloader
+
+import "time"
+
+// Snapshot is a point-in-time copy of an item. It is safe to read without
+// locking and encodes cleanly as JSON.
+type Snapshot struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	DestPath string `json:"dest_path"`
+	// OriginalName is set when the file is stored under a different Name.
+	OriginalName string            `json:"original_name,omitempty"`
+	Hashes       Hashes            `json:"hashes,omitzero"`
+	Status       Status            `json:"status"`
+	DoneBytes    int64             `json:"done_bytes"`
+	TotalBytes   int64             `json:"total_bytes"`
+	Speed        float64           `json:"speed"`
+	ETA          time.Duration     `json:"eta,omitempty"`
+	ETAKnown     bool              `json:"eta_known,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	Class        ErrorClass        `json:"class,omitempty"`
+	Attempts     int               `json:"attempts,omitempty"`
+	History      []AttemptSnapshot `json:"history,omitempty"`
+	NextRetry    time.Time         `json:"next_retry,omitzero"`
+	StartedAt    time.Time         `json:"started_at,omitzero"`
+	CompletedAt  time.Time         `json:"completed_at,omitzero"`
+}
+
+// AttemptSnapshot is the JSON-friendly form of an Attempt.
+type AttemptSnapshot struct {
+	At    time.Time  `json:"at"`
+	Error string     `json:"error"`
+	Class ErrorClass `json:"class"`
+}
+
+// Progress returns the completed fraction, or 0 when the size is unknown.
+func (s Snapshot) Progress() float64 {
+	if s.TotalBytes <= 0 {
+		return 0
+	}
+	return float64(s.DoneBytes) / float64(s.TotalBytes)
+}
+
+// Snapshot copies the item's current state.
+func (it *Item) Snapshot() Snapshot {
+	speed := it.Speed()
+	eta, etaOK := it.ETA()
+
+	it.Mu.Lock()
+	defer it.Mu.Unlock()
+	s := Snapshot{
+		ID:           it.ID,
+		Name:         it.Name,
+		URL:          it.URL,
+		DestPath:     it.DestPath,
+		OriginalName: it.OriginalName,
+		Hashes:       it.Hashes,
+		Status:       it.Status,
+		DoneBytes:    it.DoneBytes.Load(),
+		TotalBytes:   it.TotalBytes,
+		Speed:        speed,
+		ETA:          eta,
+		ETAKnown:     etaOK,
+		Attempts:     it.Attempts,
+		NextRetry:    it.NextRetry,
+		StartedAt:    it.StartedAt,
+		CompletedAt:  it.CompletedAt,
+	}
+	if it.Error != nil {
+		s.Error = it.Error.Error()
+		s.Class = ClassifyError(it.Error)
+	}
+	for _, a := range it.History {
+		s.History = append(s.History, AttemptSnapshot{At: a.At, Error: a.Err.Error(), Class: a.Class})
+	}
+	return s
+}
+
+// Snapshots returns a snapshot of every item, in queue order.
+func (m *Manager) Snapshots() []Snapshot {
+	items := m.Items()
+	out := make([]Snapshot, 0, len(items))
+	for _, it := range items {
+		out = append(out, it.Snapshot())
+	}
+	return out
+}
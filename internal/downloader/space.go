@@ -0,0 +1,140 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/JohnDeved/myrient-cli/internal/util"
+)
+
+// ErrInsufficientSpace is reported when a download does not fit on the
+// destination filesystem.
+var ErrInsufficientSpace = errors.New("insufficient space")
+
+// existingAncestor walks up from dir until it finds a path that exists, so
+// free space can be queried before the destination directory is created.
+func existingAncestor(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// remainingBytes returns how many bytes the item still needs on disk, based on
+// its expected size and any partial file already written. A preallocated
+// file needs none: its blocks are already taken.
+func remainingBytes(item *Item) int64 {
+	total := item.total()
+	if total <= 0 || item.preallocated.Load() {
+		return 0
+	}
+	done := item.DoneBytes.Load()
+	if info, err := os.Stat(item.DestPath + ".part"); err == nil && info.Size() > done {
+		done = info.Size()
+	}
+	if done >= total {
+		return 0
+	}
+	return total - done
+}
+
+// reservedBytes sums the remaining bytes of all active downloads except skip.
+// Each active download holds a reservation for what it has yet to write.
+func (m *Manager) reservedBytes(skip *Item) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var reserved int64
+	for _, it := range m.items {
+		if it == skip {
+			continue
+		}
+		it.Mu.Lock()
+		active := it.Status == StatusActive
+		it.Mu.Unlock()
+		if active {
+			reserved += remainingBytes(it)
+		}
+	}
+	return reserved
+}
+
+// checkSpace verifies that need bytes fit in the item's destination alongside
+// the reservations of other active downloads and the configured headroom.
+func (m *Manager) checkSpace(item *Item, need int64) error {
+	if need <= 0 {
+		return nil
+	}
+	free, err := freeSpace(filepath.Dir(item.DestPath))
+	if err != nil {
+		// Unknown free space should never block a download.
+		return nil
+	}
+	m.mu.Lock()
+	headroom := m.minFree
+	m.mu.Unlock()
+
+	available := free - m.reservedBytes(item) - headroom
+	if need > available {
+		if available < 0 {
+			available = 0
+		}
+		return fmt.Errorf("%w: need %s, %s available", ErrInsufficientSpace,
+			util.FormatBytes(need), util.FormatBytes(available))
+	}
+	return nil
+}
+
+// FreeSpace returns the free bytes on the download directory's filesystem,
+// minus reservations held by active downloads.
+func (m *Manager) FreeSpace() (int64, error) {
+	free, err := freeSpace(m.downloadDir)
+	if err != nil {
+		return 0, err
+	}
+	free -= m.reservedBytes(nil)
+	if free < 0 {
+		free = 0
+	}
+	return free, nil
+}
+
+// SetMinFreeSpace sets how many bytes must remain free after a download's
+// reservation is accounted for.
+func (m *Manager) SetMinFreeSpace(bytes int64) {
+	if bytes < 0 {
+		bytes = 0
+	}
+	m.mu.Lock()
+	m.minFree = bytes
+	m.mu.Unlock()
+}
+
+// releaseHeld re-queues items held for insufficient space so they can
+// re-check against the reservations that remain.
+func (m *Manager) releaseHeld() {
+	m.mu.Lock()
+	var held []*Item
+	for _, it := range m.items {
+		it.Mu.Lock()
+		if it.Status == StatusNoSpace {
+			it.Status = StatusQueued
+			it.Error = nil
+			held = append(held, it)
+		}
+		it.Mu.Unlock()
+	}
+	m.mu.Unlock()
+	for _, it := range held {
+		m.emit(EventEnqueued, it)
+		go m.processItem(it)
+	}
+}
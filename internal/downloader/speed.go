@@ -0,0 +1,137 @@
+package downloader
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// speedWindow is the time constant of the rolling speed average. Samples
+// older than a few windows have effectively no influence.
+const speedWindow = 3 * time.Second
+
+// minSampleInterval avoids noisy rates from back-to-back small reads.
+const minSampleInterval = 200 * time.Millisecond
+
+// rateMeter tracks an exponentially weighted transfer rate from periodic
+// byte counter samples. Only bytes transferred since reset are counted, so a
+// resumed prefix never inflates the rate.
+type rateMeter struct {
+	mu        sync.Mutex
+	lastAt    time.Time
+	lastBytes int64
+	rate      float64
+	primed    bool
+}
+
+func (r *rateMeter) reset(bytes int64, now time.Time) {
+	r.mu.Lock()
+	r.lastAt = now
+	r.lastBytes = bytes
+	r.rate = 0
+	r.primed = false
+	r.mu.Unlock()
+}
+
+func (r *rateMeter) sample(bytes int64, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lastAt.IsZero() {
+		r.lastAt = now
+		r.lastBytes = bytes
+		return
+	}
+	dt := now.Sub(r.lastAt)
+	if dt < minSampleInterval {
+		return
+	}
+	inst := float64(bytes-r.lastBytes) / dt.Seconds()
+	if inst < 0 {
+		inst = 0
+	}
+	if !r.primed {
+		r.rate = inst
+		r.primed = true
+	} else {
+		alpha := 1 - math.Exp(-dt.Seconds()/speedWindow.Seconds())
+		r.rate += alpha * (inst - r.rate)
+	}
+	r.lastAt = now
+	r.lastBytes = bytes
+}
+
+// current returns the rate at now, decaying it when no data has arrived for
+// a while so a stalled transfer does not keep showing its old speed.
+func (r *rateMeter) current(now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.primed {
+		return 0
+	}
+	idle := now.Sub(r.lastAt)
+	if idle <= time.Second {
+		return r.rate
+	}
+	return r.rate * math.Exp(-idle.Seconds()/speedWindow.Seconds())
+}
+
+// Speed returns the rolling download speed in bytes per second.
+func (it *Item) Speed() float64 {
+	it.Mu.Lock()
+	active := it.Status == StatusActive
+	it.Mu.Unlock()
+	if !active {
+		return 0
+	}
+	return it.meter.current(time.Now())
+}
+
+// ETA returns the estimated time until the item finishes at its current
+// speed. ok is false when the size or speed is unknown.
+func (it *Item) ETA() (eta time.Duration, ok bool) {
+	total := it.total()
+	speed := it.Speed()
+	if total <= 0 || speed <= 0 {
+		return 0, false
+	}
+	remaining := total - it.DoneBytes.Load()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return time.Duration(float64(remaining) / speed * float64(time.Second)), true
+}
+
+// Throughput returns the combined speed of all active downloads.
+func (m *Manager) Throughput() float64 {
+	var total float64
+	for _, it := range m.Items() {
+		total += it.Speed()
+	}
+	return total
+}
+
+// QueueETA estimates how long the remaining queued, active and paused
+// downloads will take at the current aggregate throughput. Items with an
+// unknown size are not counted. ok is false when nothing is transferring.
+func (m *Manager) QueueETA() (eta time.Duration, ok bool) {
+	var remaining int64
+	var speed float64
+	for _, it := range m.Items() {
+		it.Mu.Lock()
+		status, total := it.Status, it.TotalBytes
+		it.Mu.Unlock()
+		if !status.Pending() {
+			continue
+		}
+		if total > 0 {
+			if left := total - it.DoneBytes.Load(); left > 0 {
+				remaining += left
+			}
+		}
+		speed += it.Speed()
+	}
+	if speed <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(remaining) / speed * float64(time.Second)), true
+}
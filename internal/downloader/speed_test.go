@@ -0,0 +1,42 @@
+package downloader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateMeter_IgnoresResumedPrefix(t *testing.T) {
+	var r rateMeter
+	start := time.Unix(1000, 0)
+	// Resume at 900 MB: only bytes written afterwards count.
+	r.reset(900<<20, start)
+	r.sample(900<<20+1<<20, start.Add(time.Second))
+
+	got := r.current(start.Add(time.Second))
+	if got < 0.99*(1<<20) || got > 1.01*(1<<20) {
+		t.Fatalf("expected ~1 MiB/s, got %.0f", got)
+	}
+}
+
+func TestRateMeter_TracksRateChangesAndStalls(t *testing.T) {
+	var r rateMeter
+	now := time.Unix(1000, 0)
+	r.reset(0, now)
+	var bytes int64
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Second)
+		bytes += 1000
+		r.sample(bytes, now)
+	}
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Second)
+		bytes += 100
+		r.sample(bytes, now)
+	}
+	if got := r.current(now); got > 200 {
+		t.Fatalf("expected rate to follow the slowdown, got %.0f", got)
+	}
+	if got := r.current(now.Add(30 * time.Second)); got > 1 {
+		t.Fatalf("expected stalled rate to decay, got %.2f", got)
+	}
+}
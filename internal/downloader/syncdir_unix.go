@@ -0,0 +1,15 @@
+//go:build !windows
+
+package downloader
+
+import "os"
+
+// syncDir flushes directory metadata so a completed rename survives power loss.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
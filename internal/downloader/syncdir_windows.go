@@ -0,0 +1,9 @@
+//go:build windows
+
+package downloader
+
+// syncDir is a no-op on Windows, where directory handles cannot be flushed and
+// NTFS journals renames itself.
+func syncDir(dir string) error {
+	return nil
+}
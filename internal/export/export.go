@@ -0,0 +1,200 @@
+// Package export writes download lists for external downloaders: aria2
+// input files, wget and curl shell scripts, and Metalink 4 documents.
+package export
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Format is an export file format.
+type Format string
+
+const (
+	Aria2    Format = "aria2"
+	Wget     Format = "wget"
+	Curl     Format = "curl"
+	Metalink Format = "metalink"
+)
+
+// Formats lists the supported formats.
+func Formats() []Format {
+	return []Format{Aria2, Wget, Curl, Metalink}
+}
+
+// ParseFormat parses a format name, case-insensitively.
+func ParseFormat(s string) (Format, error) {
+	f := Format(strings.ToLower(strings.TrimSpace(s)))
+	for _, known := range Formats() {
+		if f == known {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown export format %q (want aria2, wget, curl or metalink)", s)
+}
+
+// Ext returns the usual file extension for the format, without the dot.
+func (f Format) Ext() string {
+	switch f {
+	case Aria2:
+		return "aria2"
+	case Metalink:
+		return "meta4"
+	default:
+		return "sh"
+	}
+}
+
+// Entry is one file to export.
+type Entry struct {
+	URL string
+	// Path is the destination relative to the output directory, using "/".
+	Path string
+	// Size is the exact size in bytes, or 0 when unknown.
+	Size int64
+	// Hash is "algo:hex" (crc32, md5, sha1 or sha256), or empty.
+	Hash string
+}
+
+// Write renders entries in format f. dir is the output directory the
+// relative entry paths are placed under; Metalink documents only carry the
+// relative paths.
+func Write(w io.Writer, f Format, dir string, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	var err error
+	switch f {
+	case Aria2:
+		writeAria2(bw, dir, entries)
+	case Wget, Curl:
+		writeScript(bw, f, dir, entries)
+	case Metalink:
+		err = writeMetalink(bw, entries)
+	default:
+		return fmt.Errorf("unknown export format %q", f)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// splitHash returns the algorithm and hex digest of an "algo:hex" hash with
+// the algorithm normalised to md5, sha1, sha256 or crc32.
+func splitHash(h string) (algo, sum string, ok bool) {
+	algo, sum, ok = strings.Cut(h, ":")
+	if !ok || sum == "" {
+		return "", "", false
+	}
+	switch strings.ToLower(strings.ReplaceAll(algo, "-", "")) {
+	case "md5":
+		algo = "md5"
+	case "sha1":
+		algo = "sha1"
+	case "sha256":
+		algo = "sha256"
+	case "crc32", "crc":
+		algo = "crc32"
+	default:
+		return "", "", false
+	}
+	return algo, strings.ToLower(sum), true
+}
+
+// ianaHash maps an algorithm to its name in the IANA hash registry used by
+// aria2 and Metalink. CRC32 has none.
+func ianaHash(algo string) string {
+	switch algo {
+	case "md5":
+		return "md5"
+	case "sha1":
+		return "sha-1"
+	case "sha256":
+		return "sha-256"
+	}
+	return ""
+}
+
+func writeAria2(w *bufio.Writer, dir string, entries []Entry) {
+	fmt.Fprintf(w, "# %d file(s) exported by myrient; run: aria2c -c -i <this file>\n", len(entries))
+	for _, e := range entries {
+		sub, name := path.Split(e.Path)
+		fmt.Fprintln(w, e.URL)
+		fmt.Fprintf(w, "  dir=%s\n", filepath.Join(dir, filepath.FromSlash(sub)))
+		fmt.Fprintf(w, "  out=%s\n", name)
+		if algo, sum, ok := splitHash(e.Hash); ok && ianaHash(algo) != "" {
+			fmt.Fprintf(w, "  checksum=%s=%s\n", ianaHash(algo), sum)
+		}
+	}
+}
+
+func writeScript(w *bufio.Writer, f Format, dir string, entries []Entry) {
+	fmt.Fprintln(w, "#!/bin/sh")
+	fmt.Fprintf(w, "# %d file(s) exported by myrient\n", len(entries))
+	fmt.Fprintln(w, "set -e")
+	for _, e := range entries {
+		dest := filepath.Join(dir, filepath.FromSlash(e.Path))
+		fmt.Fprintln(w)
+		if e.Size > 0 {
+			fmt.Fprintf(w, "# %s bytes\n", strconv.FormatInt(e.Size, 10))
+		}
+		if f == Wget {
+			fmt.Fprintf(w, "mkdir -p %s\n", shellQuote(filepath.Dir(dest)))
+			fmt.Fprintf(w, "wget -c -O %s %s\n", shellQuote(dest), shellQuote(e.URL))
+		} else {
+			fmt.Fprintf(w, "curl -fL --retry 3 -C - --create-dirs -o %s %s\n", shellQuote(dest), shellQuote(e.URL))
+		}
+		if algo, sum, ok := splitHash(e.Hash); ok && algo != "crc32" {
+			fmt.Fprintf(w, "echo %s | %ssum -c -\n", shellQuote(sum+"  "+dest), algo)
+		}
+	}
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+type metalinkDoc struct {
+	XMLName   xml.Name       `xml:"urn:ietf:params:xml:ns:metalink metalink"`
+	Generator string         `xml:"generator"`
+	Files     []metalinkFile `xml:"file"`
+}
+
+type metalinkFile struct {
+	Name   string         `xml:"name,attr"`
+	Size   int64          `xml:"size,omitempty"`
+	Hashes []metalinkHash `xml:"hash"`
+	URL    string         `xml:"url"`
+}
+
+type metalinkHash struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func writeMetalink(w io.Writer, entries []Entry) error {
+	doc := metalinkDoc{Generator: "myrient"}
+	for _, e := range entries {
+		mf := metalinkFile{Name: e.Path, Size: e.Size, URL: e.URL}
+		if algo, sum, ok := splitHash(e.Hash); ok && ianaHash(algo) != "" {
+			mf.Hashes = append(mf.Hashes, metalinkHash{Type: ianaHash(algo), Value: sum})
+		}
+		doc.Files = append(doc.Files, mf)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("encoding metalink: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
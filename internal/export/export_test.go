@@ -0,0 +1,89 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testEntries = []Entry{
+	{
+		URL:  "https://example.org/files/GB/Tetris%20(World).zip",
+		Path: "GB/Tetris (World).zip",
+		Size: 1234,
+		Hash: "sha1:ABCDEF",
+	},
+	{
+		URL:  "https://example.org/files/GB/It's%20Mario.zip",
+		Path: "It's Mario.zip",
+		Hash: "crc32:0badf00d",
+	},
+}
+
+func render(t *testing.T, f Format) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, f, "/dl", testEntries); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestAria2(t *testing.T) {
+	out := render(t, Aria2)
+	for _, want := range []string{
+		"https://example.org/files/GB/Tetris%20(World).zip\n  dir=/dl/GB\n  out=Tetris (World).zip\n  checksum=sha-1=abcdef\n",
+		"  dir=/dl\n  out=It's Mario.zip\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("aria2 output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "0badf00d") {
+		t.Errorf("aria2 output should skip crc32 checksums:\n%s", out)
+	}
+}
+
+func TestScripts(t *testing.T) {
+	wget := render(t, Wget)
+	for _, want := range []string{
+		"wget -c -O '/dl/GB/Tetris (World).zip' 'https://example.org/files/GB/Tetris%20(World).zip'\n",
+		"echo 'abcdef  /dl/GB/Tetris (World).zip' | sha1sum -c -\n",
+		`'/dl/It'\''s Mario.zip'`,
+	} {
+		if !strings.Contains(wget, want) {
+			t.Errorf("wget output missing %q:\n%s", want, wget)
+		}
+	}
+	curl := render(t, Curl)
+	if !strings.Contains(curl, "curl -fL --retry 3 -C - --create-dirs -o '/dl/GB/Tetris (World).zip' ") {
+		t.Errorf("unexpected curl output:\n%s", curl)
+	}
+}
+
+func TestMetalink(t *testing.T) {
+	out := render(t, Metalink)
+	for _, want := range []string{
+		`<metalink xmlns="urn:ietf:params:xml:ns:metalink">`,
+		`<file name="GB/Tetris (World).zip">`,
+		`<size>1234</size>`,
+		`<hash type="sha-1">abcdef</hash>`,
+		`<url>https://example.org/files/GB/It&#39;s%20Mario.zip</url>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metalink output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "<size>") != 1 {
+		t.Errorf("unknown sizes should be omitted:\n%s", out)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat(" Metalink "); err != nil || f != Metalink {
+		t.Fatalf("ParseFormat = %q, %v", f, err)
+	}
+	if _, err := ParseFormat("torrent"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
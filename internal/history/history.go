@@ -0,0 +1,269 @@
+// Package history records finished downloads in a SQLite database so past
+// downloads can be looked up after they leave the queue.
+package history
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+// Download statuses stored in the history.
+const (
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Entry is one finished download.
+type Entry struct {
+	ID         int64         `json:"id"`
+	Name       string        `json:"name"`
+	URL        string        `json:"url"`
+	DestPath   string        `json:"dest_path"`
+	Collection string        `json:"collection,omitempty"`
+	Status     string        `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	Size       int64         `json:"size"`
+	CRC32      string        `json:"crc32,omitempty"`
+	MD5        string        `json:"md5,omitempty"`
+	SHA1       string        `json:"sha1,omitempty"`
+	StartedAt  time.Time     `json:"started_at,omitzero"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Duration   time.Duration `json:"duration"`
+	// AvgSpeed is in bytes per second over the final attempt.
+	AvgSpeed float64 `json:"avg_speed"`
+}
+
+// FromSnapshot builds an entry from the final state of a download. baseURL
+// is used to derive the source collection from the file URL.
+func FromSnapshot(s downloader.Snapshot, baseURL string) Entry {
+	e := Entry{
+		Name:       s.Name,
+		URL:        s.URL,
+		DestPath:   s.DestPath,
+		Collection: collectionOf(baseURL, s.URL),
+		Status:     StatusFailed,
+		Error:      s.Error,
+		Size:       s.TotalBytes,
+		CRC32:      s.Hashes.CRC32,
+		MD5:        s.Hashes.MD5,
+		SHA1:       s.Hashes.SHA1,
+		StartedAt:  s.StartedAt,
+		FinishedAt: s.CompletedAt,
+	}
+	if s.Status == downloader.StatusCompleted {
+		e.Status = StatusCompleted
+	}
+	if e.Size <= 0 {
+		e.Size = s.DoneBytes
+	}
+	if e.FinishedAt.IsZero() {
+		e.FinishedAt = time.Now()
+	}
+	if !e.StartedAt.IsZero() {
+		e.Duration = e.FinishedAt.Sub(e.StartedAt)
+		if e.Status == StatusCompleted && e.Duration > 0 {
+			e.AvgSpeed = float64(e.Size) / e.Duration.Seconds()
+		}
+	}
+	return e
+}
+
+func collectionOf(baseURL, fileURL string) string {
+	base := strings.TrimRight(baseURL, "/") + "/"
+	if !strings.HasPrefix(fileURL, base) {
+		return ""
+	}
+	remote := destpath.RemotePath(baseURL, fileURL)
+	collection, _, ok := strings.Cut(remote, "/")
+	if !ok {
+		return ""
+	}
+	return collection
+}
+
+// DB wraps the SQLite history database.
+type DB struct {
+	db *sql.DB
+
+	// mu orders hook writes before Close; closed drops later ones.
+	mu     sync.Mutex
+	closed bool
+}
+
+// Open opens or creates the history database at the given path.
+func Open(dbPath string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return nil, fmt.Errorf("creating db directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=journal_mode(wal)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("opening history database: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating history database: %w", err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database, waiting for a hook write in progress.
+func (d *DB) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	return d.db.Close()
+}
+
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS downloads (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		url TEXT NOT NULL,
+		dest_path TEXT NOT NULL,
+		collection TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		error TEXT NOT NULL DEFAULT '',
+		size INTEGER NOT NULL DEFAULT 0,
+		crc32 TEXT NOT NULL DEFAULT '',
+		md5 TEXT NOT NULL DEFAULT '',
+		sha1 TEXT NOT NULL DEFAULT '',
+		started_at INTEGER NOT NULL DEFAULT 0,
+		finished_at INTEGER NOT NULL,
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		avg_speed REAL NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_downloads_url ON downloads(url);
+	CREATE INDEX IF NOT EXISTS idx_downloads_finished ON downloads(finished_at);
+	`)
+	return err
+}
+
+// Add records a finished download and sets its ID.
+func (d *DB) Add(e *Entry) error {
+	var started int64
+	if !e.StartedAt.IsZero() {
+		started = e.StartedAt.UnixMilli()
+	}
+	res, err := d.db.Exec(`
+		INSERT INTO downloads (name, url, dest_path, collection, status, error, size,
+			crc32, md5, sha1, started_at, finished_at, duration_ms, avg_speed)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Name, e.URL, e.DestPath, e.Collection, e.Status, e.Error, e.Size,
+		e.CRC32, e.MD5, e.SHA1, started, e.FinishedAt.UnixMilli(), e.Duration.Milliseconds(), e.AvgSpeed)
+	if err != nil {
+		return fmt.Errorf("recording download: %w", err)
+	}
+	e.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// Hook returns a downloader finish hook that records every finished
+// download. Errors are passed to onErr when it is not nil. Downloads that
+// finish after Close are not recorded.
+func (d *DB) Hook(baseURL string, onErr func(error)) func(downloader.Snapshot) {
+	return func(s downloader.Snapshot) {
+		e := FromSnapshot(s, baseURL)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if d.closed {
+			return
+		}
+		if err := d.Add(&e); err != nil && onErr != nil {
+			onErr(err)
+		}
+	}
+}
+
+// Query selects history entries. Zero fields do not filter.
+type Query struct {
+	// Text matches a substring of the name, URL or destination path.
+	Text       string
+	Status     string
+	Collection string
+	// SHA1, MD5 and CRC32 match the checksums of completed downloads.
+	SHA1  string
+	MD5   string
+	CRC32 string
+	Since time.Time
+	// Limit caps the number of entries; 0 means no limit.
+	Limit int
+}
+
+// List returns matching entries, most recent first.
+func (d *DB) List(q Query) ([]Entry, error) {
+	var where []string
+	var args []any
+	if q.Text != "" {
+		like := "%" + escapeLike(q.Text) + "%"
+		where = append(where, `(name LIKE ? ESCAPE '\' OR url LIKE ? ESCAPE '\' OR dest_path LIKE ? ESCAPE '\')`)
+		args = append(args, like, like, like)
+	}
+	if q.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, q.Status)
+	}
+	for column, sum := range map[string]string{"sha1": q.SHA1, "md5": q.MD5, "crc32": q.CRC32} {
+		if sum != "" {
+			where = append(where, column+" = ?")
+			args = append(args, strings.ToLower(sum))
+		}
+	}
+	if q.Collection != "" {
+		where = append(where, "collection = ? COLLATE NOCASE")
+		args = append(args, q.Collection)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "finished_at >= ?")
+		args = append(args, q.Since.UnixMilli())
+	}
+
+	query := `SELECT id, name, url, dest_path, collection, status, error, size,
+		crc32, md5, sha1, started_at, finished_at, duration_ms, avg_speed FROM downloads`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY finished_at DESC, id DESC"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var started, finished, durationMS int64
+		if err := rows.Scan(&e.ID, &e.Name, &e.URL, &e.DestPath, &e.Collection, &e.Status, &e.Error, &e.Size,
+			&e.CRC32, &e.MD5, &e.SHA1, &started, &finished, &durationMS, &e.AvgSpeed); err != nil {
+			return nil, err
+		}
+		if started > 0 {
+			e.StartedAt = time.UnixMilli(started)
+		}
+		e.FinishedAt = time.UnixMilli(finished)
+		e.Duration = time.Duration(durationMS) * time.Millisecond
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,104 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+func TestFromSnapshot(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	e := FromSnapshot(downloader.Snapshot{
+		Name:        "Tetris (World).zip",
+		URL:         "https://example.org/files/No-Intro/Nintendo%20-%20Game%20Boy/Tetris%20(World).zip",
+		DestPath:    "/dl/Tetris (World).zip",
+		Status:      downloader.StatusCompleted,
+		TotalBytes:  4000,
+		Hashes:      downloader.Hashes{CRC32: "46df91ad"},
+		StartedAt:   start,
+		CompletedAt: start.Add(2 * time.Second),
+	}, "https://example.org/files/")
+
+	if e.Collection != "No-Intro" {
+		t.Errorf("Collection = %q", e.Collection)
+	}
+	if e.Status != StatusCompleted || e.CRC32 != "46df91ad" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e.Duration != 2*time.Second || e.AvgSpeed != 2000 {
+		t.Errorf("Duration = %s, AvgSpeed = %v", e.Duration, e.AvgSpeed)
+	}
+}
+
+func TestAddAndList(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	for _, e := range []Entry{
+		{Name: "Tetris (World).zip", URL: "u1", DestPath: "/a", Collection: "No-Intro", Status: StatusCompleted, FinishedAt: now.Add(-48 * time.Hour)},
+		{Name: "Mario_100%.zip", URL: "u2", DestPath: "/b", Collection: "Redump", Status: StatusFailed, Error: "boom", FinishedAt: now.Add(-time.Hour)},
+		{Name: "Zelda.zip", URL: "u3", DestPath: "/c", Collection: "No-Intro", Status: StatusCompleted, FinishedAt: now},
+	} {
+		if err := db.Add(&e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(q Query) []string {
+		t.Helper()
+		entries, err := db.List(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Name)
+		}
+		return out
+	}
+	check := func(label string, got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %v, want %v", label, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: got %v, want %v", label, got, want)
+			}
+		}
+	}
+
+	check("all", names(Query{}), "Zelda.zip", "Mario_100%.zip", "Tetris (World).zip")
+	check("text", names(Query{Text: "tetris"}), "Tetris (World).zip")
+	check("like escaping", names(Query{Text: "_100%"}), "Mario_100%.zip")
+	check("status", names(Query{Status: StatusFailed}), "Mario_100%.zip")
+	check("collection", names(Query{Collection: "no-intro"}), "Zelda.zip", "Tetris (World).zip")
+	check("since", names(Query{Since: now.Add(-2 * time.Hour)}), "Zelda.zip", "Mario_100%.zip")
+	check("limit", names(Query{Limit: 1}), "Zelda.zip")
+}
+
+func TestHook_DropsDownloadsFinishedAfterClose(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []error
+	hook := db.Hook("https://example.org/files/", func(err error) { errs = append(errs, err) })
+	snap := downloader.Snapshot{Name: "Zelda.zip", URL: "u", DestPath: "/z", Status: downloader.StatusCompleted, CompletedAt: time.Now()}
+
+	hook(snap)
+	if entries, err := db.List(Query{}); err != nil || len(entries) != 1 {
+		t.Fatalf("List = %+v, %v", entries, err)
+	}
+	db.Close()
+	hook(snap)
+	if len(errs) != 0 {
+		t.Errorf("hook after Close reported %v", errs)
+	}
+}
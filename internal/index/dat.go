@@ -0,0 +1,64 @@
+package index
+
+import "strings"
+
+// gameArchiveExts are the archive extensions Myrient stores DAT games
+// under, ZIP first.
+var gameArchiveExts = []string{".zip", ".7z", ".rar"}
+
+// GameFiles returns the indexed files linked to the DAT game named name,
+// i.e. the archives of the same name, ZIP archives first.
+func (d *DB) GameFiles(name string) ([]FileRecord, error) {
+	var files []FileRecord
+	for _, ext := range gameArchiveExts {
+		named, err := d.FilesNamed(name + ext)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, named...)
+	}
+	return files, nil
+}
+
+// CountLinkedGames returns how many of the named DAT games have an indexed
+// file, as GameFiles would find.
+func (d *DB) CountLinkedGames(games []string) (int, error) {
+	const batch = 300
+	linked := 0
+	for start := 0; start < len(games); start += batch {
+		chunk := games[start:min(start+batch, len(games))]
+		var args []any
+		for _, g := range chunk {
+			for _, ext := range gameArchiveExts {
+				args = append(args, g+ext)
+			}
+		}
+		rows, err := d.db.Query(`SELECT DISTINCT name FROM files WHERE name IN (?`+
+			strings.Repeat(", ?", len(args)-1)+`)`, args...)
+		if err != nil {
+			return 0, err
+		}
+		found := map[string]bool{}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			found[name] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+		for _, g := range chunk {
+			for _, ext := range gameArchiveExts {
+				if found[g+ext] {
+					linked++
+					break
+				}
+			}
+		}
+	}
+	return linked, nil
+}
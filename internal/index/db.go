@@ -510,6 +510,84 @@ func isSubsequenceString(needle, haystack string) bool {
 	return j == len(n)
 }
 
+// FilesUnder returns all indexed files whose path starts with dirPath,
+// ordered by path.
+func (d *DB) FilesUnder(dirPath string) ([]FileRecord, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(dirPath)
+	rows, err := d.db.Query(`
+		SELECT id, name, path, url, size, date, directory_id, collection_id
+		FROM files
+		WHERE path LIKE ? ESCAPE '\'
+		ORDER BY path
+	`, escaped+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []FileRecord
+	for rows.Next() {
+		var f FileRecord
+		if err := rows.Scan(&f.ID, &f.Name, &f.Path, &f.URL, &f.Size, &f.Date, &f.DirectoryID, &f.CollectionID); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// FilesNamed returns all indexed files with exactly the given name, ordered
+// by path.
+func (d *DB) FilesNamed(name string) ([]FileRecord, error) {
+	rows, err := d.db.Query(`
+		SELECT id, name, path, url, size, date, directory_id, collection_id
+		FROM files
+		WHERE name = ?
+		ORDER BY path
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []FileRecord
+	for rows.Next() {
+		var f FileRecord
+		if err := rows.Scan(&f.ID, &f.Name, &f.Path, &f.URL, &f.Size, &f.Date, &f.DirectoryID, &f.CollectionID); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// DirectoriesNamed returns the paths of all indexed directories whose last
+// path element is name, e.g. "No-Intro/Nintendo - Game Boy/" for
+// "Nintendo - Game Boy", ordered by path.
+func (d *DB) DirectoriesNamed(name string) ([]string, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(name)
+	rows, err := d.db.Query(`
+		SELECT path
+		FROM directories
+		WHERE path = ? OR path LIKE ? ESCAPE '\'
+		ORDER BY path
+	`, name+"/", "%/"+escaped+"/")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
 // Stats returns index statistics.
 type Stats struct {
 	Collections int
@@ -0,0 +1,198 @@
+// Package library records which remote files exist in local ROM folders,
+// so missing files can be listed and owned ones marked while browsing.
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// How a local file was matched to a remote one.
+const (
+	// MatchHash means the file's checksum equals that of a completed
+	// download or of a ROM in an imported DAT.
+	MatchHash = "hash"
+	// MatchSize means the file has the remote file's name and size.
+	MatchSize = "size"
+	// MatchName means only the name matched, e.g. an unpacked
+	// "Game (USA).gba" for the remote "Game (USA).zip".
+	MatchName = "name"
+	// MatchExtracted means the folder holds an archive extracted by
+	// "download --extract".
+	MatchExtracted = "extracted"
+)
+
+// File is a scanned local file and the remote file it was matched to.
+type File struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA1    string    `json:"sha1,omitempty"`
+	CRC32   string    `json:"crc32,omitempty"`
+	// RemotePath is relative to the archive root, e.g.
+	// "No-Intro/Nintendo - Game Boy/Tetris (World).zip", or empty when the
+	// file matched nothing.
+	RemotePath string `json:"remote_path,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Method     string `json:"method,omitempty"`
+}
+
+// DB wraps the SQLite library database.
+type DB struct {
+	db *sql.DB
+}
+
+// Open opens or creates the library database at the given path.
+func Open(dbPath string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0o755); err != nil {
+		return nil, fmt.Errorf("creating db directory: %w", err)
+	}
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=journal_mode(wal)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("opening library database: %w", err)
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating library database: %w", err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS files (
+		path TEXT PRIMARY KEY,
+		size INTEGER NOT NULL,
+		mod_time INTEGER NOT NULL,
+		sha1 TEXT NOT NULL DEFAULT '',
+		crc32 TEXT NOT NULL DEFAULT '',
+		remote_path TEXT NOT NULL DEFAULT '',
+		url TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_files_remote ON files(remote_path);
+	`)
+	return err
+}
+
+// Get returns the recorded file at path, if any.
+func (d *DB) Get(path string) (File, bool, error) {
+	f := File{Path: path}
+	var mod int64
+	err := d.db.QueryRow(`
+		SELECT size, mod_time, sha1, crc32, remote_path, url, method
+		FROM files WHERE path = ?`, path).
+		Scan(&f.Size, &mod, &f.SHA1, &f.CRC32, &f.RemotePath, &f.URL, &f.Method)
+	if err == sql.ErrNoRows {
+		return File{}, false, nil
+	}
+	if err != nil {
+		return File{}, false, err
+	}
+	f.ModTime = time.UnixMilli(mod)
+	return f, true, nil
+}
+
+// Put records f, replacing an earlier record of the same path.
+func (d *DB) Put(f File) error {
+	_, err := d.db.Exec(`
+		INSERT OR REPLACE INTO files (path, size, mod_time, sha1, crc32, remote_path, url, method)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		f.Path, f.Size, f.ModTime.UnixMilli(), f.SHA1, f.CRC32, f.RemotePath, f.URL, f.Method)
+	if err != nil {
+		return fmt.Errorf("recording %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// Prune forgets the files below root that are not in keep, because they
+// were deleted or moved since the last scan. It returns how many it forgot.
+func (d *DB) Prune(root string, keep map[string]bool) (int, error) {
+	rows, err := d.db.Query(`SELECT path FROM files WHERE path = ? OR path LIKE ? ESCAPE '\'`,
+		root, escapeLike(strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator))+"%")
+	if err != nil {
+		return 0, err
+	}
+	var stale []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !keep[p] {
+			stale = append(stale, p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, p := range stale {
+		if _, err := d.db.Exec(`DELETE FROM files WHERE path = ?`, p); err != nil {
+			return 0, err
+		}
+	}
+	return len(stale), nil
+}
+
+// Owned returns the matched files whose remote path starts with prefix,
+// keyed by remote path. An empty prefix returns every matched file.
+func (d *DB) Owned(prefix string) (map[string]File, error) {
+	rows, err := d.db.Query(`
+		SELECT path, size, mod_time, sha1, crc32, remote_path, url, method
+		FROM files
+		WHERE remote_path != '' AND remote_path LIKE ? ESCAPE '\'
+		ORDER BY path`, escapeLike(prefix)+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owned := map[string]File{}
+	for rows.Next() {
+		var f File
+		var mod int64
+		if err := rows.Scan(&f.Path, &f.Size, &mod, &f.SHA1, &f.CRC32, &f.RemotePath, &f.URL, &f.Method); err != nil {
+			return nil, err
+		}
+		f.ModTime = time.UnixMilli(mod)
+		if _, seen := owned[f.RemotePath]; !seen {
+			owned[f.RemotePath] = f
+		}
+	}
+	return owned, rows.Err()
+}
+
+// OwnedIn returns the names of the owned files directly in the remote
+// directory dir, e.g. "No-Intro/Nintendo - Game Boy/".
+func (d *DB) OwnedIn(dir string) (map[string]bool, error) {
+	owned, err := d.Owned(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(owned))
+	for remote := range owned {
+		name := strings.TrimPrefix(remote, dir)
+		if !strings.Contains(name, "/") {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,88 @@
+package library
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/JohnDeved/myrient-cli/internal/index"
+)
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	kib := make([]byte, 1024)
+	for rel, data := range map[string][]byte{
+		"Nintendo - Game Boy/Tetris (World).zip":   kib,
+		"Nintendo - Game Boy/Kirby (USA).gb":       []byte("rom"),
+		"Nintendo - Game Boy/Wrong Size (USA).zip": []byte("short"),
+		"Nintendo - Game Boy/Zelda (USA).7z":       []byte("not the zip"),
+		"Nintendo - Game Boy/.hidden.zip":          kib,
+		"Nintendo - Game Boy/Next (USA).zip.part":  kib,
+		"notes.txt": []byte("hi"),
+	} {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx, err := index.OpenDB(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	for _, f := range []struct{ name, path string }{
+		{"Tetris (World).zip", "No-Intro/Nintendo - Game Boy Color/Tetris (World).zip"},
+		{"Tetris (World).zip", "No-Intro/Nintendo - Game Boy/Tetris (World).zip"},
+		{"Kirby (USA).zip", "No-Intro/Nintendo - Game Boy/Kirby (USA).zip"},
+		{"Wrong Size (USA).zip", "No-Intro/Nintendo - Game Boy/Wrong Size (USA).zip"},
+		{"Zelda (USA).zip", "No-Intro/Nintendo - Game Boy/Zelda (USA).zip"},
+	} {
+		if err := idx.InsertFile(f.name, f.path, "https://example.org/files/"+f.path, "1.0 KiB", "", 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lib, err := Open(filepath.Join(t.TempDir(), "library.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lib.Close()
+	s := NewScanner(lib, "https://example.org/files/")
+	s.SetIndex(idx)
+	s.SetHash(true)
+	sum, err := s.Scan(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Files != 5 || sum.Matched[MatchSize] != 1 || sum.Matched[MatchName] != 1 || sum.Unmatched != 3 || sum.Hashed != 5 {
+		t.Errorf("first scan: %+v", sum)
+	}
+
+	owned, err := lib.OwnedIn("No-Intro/Nintendo - Game Boy/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(owned) != 2 || !owned["Tetris (World).zip"] || !owned["Kirby (USA).zip"] {
+		t.Errorf("owned = %v", owned)
+	}
+	if f, _, _ := lib.Get(filepath.Join(root, "Nintendo - Game Boy", "Kirby (USA).gb")); f.SHA1 == "" || f.CRC32 == "" {
+		t.Errorf("Kirby not hashed: %+v", f)
+	}
+
+	// Unchanged files keep their checksums; deleted ones are forgotten.
+	if err := os.Remove(filepath.Join(root, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	sum, err = s.Scan(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Files != 4 || sum.Hashed != 0 || sum.Forgotten != 1 {
+		t.Errorf("second scan: %+v", sum)
+	}
+}
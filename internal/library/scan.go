@@ -0,0 +1,326 @@
+package library
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JohnDeved/myrient-cli/internal/dat"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/history"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+)
+
+// archiveExts are tried, in order, for local files that were unpacked
+// from the archives Myrient serves.
+var archiveExts = []string{".zip", ".7z", ".rar"}
+
+// isArchive reports whether name has one of the archiveExts.
+func isArchive(name string) bool {
+	ext := filepath.Ext(name)
+	for _, a := range archiveExts {
+		if strings.EqualFold(ext, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary counts the outcome of a scan.
+type Summary struct {
+	Files int `json:"files"`
+	// Matched counts matched files by match method.
+	Matched   map[string]int `json:"matched"`
+	Unmatched int            `json:"unmatched"`
+	Hashed    int            `json:"hashed"`
+	// Forgotten counts files recorded by an earlier scan that are gone.
+	Forgotten int `json:"forgotten"`
+}
+
+// Scanner walks local folders and records which remote files they hold.
+type Scanner struct {
+	db       *DB
+	baseURL  string
+	index    *index.DB
+	dats     *dat.DB
+	history  *history.DB
+	hash     bool
+	progress func(path string)
+}
+
+// NewScanner returns a scanner recording into db. baseURL turns download
+// URLs into remote paths.
+func NewScanner(db *DB, baseURL string) *Scanner {
+	return &Scanner{db: db, baseURL: baseURL}
+}
+
+// SetIndex sets the index files are matched against by name and size.
+func (s *Scanner) SetIndex(idx *index.DB) {
+	s.index = idx
+}
+
+// SetDATs sets the imported DATs files are matched against by checksum
+// when hashing. It only applies together with an index.
+func (s *Scanner) SetDATs(dats *dat.DB) {
+	s.dats = dats
+}
+
+// SetHistory sets the download history files are matched against by
+// checksum when hashing.
+func (s *Scanner) SetHistory(h *history.DB) {
+	s.history = h
+}
+
+// SetHash makes the scanner checksum every file. Checksums are kept, so
+// unchanged files are only hashed once.
+func (s *Scanner) SetHash(on bool) {
+	s.hash = on
+}
+
+// SetProgressCallback sets a function called with every file scanned.
+func (s *Scanner) SetProgressCallback(fn func(path string)) {
+	s.progress = fn
+}
+
+// Scan walks root, matches every file and records the results, forgetting
+// files below root that an earlier scan recorded but are gone.
+func (s *Scanner) Scan(ctx context.Context, root string) (Summary, error) {
+	sum := Summary{Matched: map[string]int{}}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return sum, err
+	}
+	seen := map[string]bool{}
+	var storedNames map[string]string
+	namesDir := ""
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(name, ".") {
+				return filepath.SkipDir
+			}
+			if url, size, ok := downloader.ExtractedFrom(p); ok {
+				f := File{Path: p, Size: size, URL: url, RemotePath: destpath.RemotePath(s.baseURL, url), Method: MatchExtracted}
+				if info, err := d.Info(); err == nil {
+					f.ModTime = info.ModTime()
+				}
+				if err := s.db.Put(f); err != nil {
+					return err
+				}
+				seen[p] = true
+				sum.Files++
+				sum.Matched[MatchExtracted]++
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || skipFile(name) {
+			return nil
+		}
+		if dir := filepath.Dir(p); dir != namesDir {
+			namesDir, storedNames = dir, downloader.StoredNames(dir)
+		}
+		if original, ok := storedNames[name]; ok {
+			name = original
+		}
+		if s.progress != nil {
+			s.progress(p)
+		}
+
+		f, err := s.scanFile(p, name, d, &sum)
+		if err != nil {
+			return err
+		}
+		if err := s.db.Put(f); err != nil {
+			return err
+		}
+		seen[p] = true
+		sum.Files++
+		if f.Method == "" {
+			sum.Unmatched++
+		} else {
+			sum.Matched[f.Method]++
+		}
+		return nil
+	})
+	if err != nil {
+		return sum, err
+	}
+	sum.Forgotten, err = s.db.Prune(root, seen)
+	return sum, err
+}
+
+// skipFile reports files that are bookkeeping or unfinished downloads.
+func skipFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	for _, suffix := range []string{".part", ".tmp", ".extracting"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanFile matches the file at p, whose remote name is name. Checksums of
+// an unchanged file are taken from the earlier scan.
+func (s *Scanner) scanFile(p, name string, d fs.DirEntry, sum *Summary) (File, error) {
+	info, err := d.Info()
+	if err != nil {
+		return File{}, err
+	}
+	f := File{Path: p, Size: info.Size(), ModTime: info.ModTime()}
+	if prev, ok, err := s.db.Get(p); err != nil {
+		return File{}, err
+	} else if ok && prev.Size == f.Size && prev.ModTime.Equal(f.ModTime.Truncate(1e6)) {
+		f.SHA1, f.CRC32 = prev.SHA1, prev.CRC32
+	}
+	if s.hash && f.SHA1 == "" {
+		if f.SHA1, f.CRC32, err = hashFile(p); err != nil {
+			return File{}, err
+		}
+		sum.Hashed++
+	}
+
+	if f.SHA1 != "" && s.history != nil {
+		entries, err := s.history.List(history.Query{SHA1: f.SHA1, Status: history.StatusCompleted, Limit: 1})
+		if err != nil {
+			return File{}, err
+		}
+		if len(entries) > 0 {
+			f.URL, f.Method = entries[0].URL, MatchHash
+			f.RemotePath = destpath.RemotePath(s.baseURL, f.URL)
+			return f, nil
+		}
+	}
+	if s.index == nil {
+		return f, nil
+	}
+	if f.SHA1 != "" && s.dats != nil {
+		rec, ok, err := s.byDAT(p, f.SHA1)
+		if err != nil {
+			return File{}, err
+		}
+		if ok {
+			f.RemotePath, f.URL, f.Method = rec.Path, rec.URL, MatchHash
+			return f, nil
+		}
+	}
+	rec, ok, err := s.byName(p, name, func(r index.FileRecord) bool { return util.SizeMatches(f.Size, r.Size) })
+	if err != nil {
+		return File{}, err
+	}
+	method := MatchSize
+	if !ok {
+		// Listings without sizes can only be matched by name.
+		rec, ok, err = s.byName(p, name, func(r index.FileRecord) bool { return r.Size == "" || r.Size == "-" })
+		if err != nil {
+			return File{}, err
+		}
+		method = MatchName
+	}
+	if !ok && !isArchive(name) {
+		// An unpacked file is named after its archive. A local archive is
+		// not tried: one in another format is a different file.
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		for _, ext := range archiveExts {
+			if rec, ok, err = s.byName(p, stem+ext, nil); err != nil {
+				return File{}, err
+			} else if ok {
+				method = MatchName
+				break
+			}
+		}
+	}
+	if ok {
+		f.RemotePath, f.URL, f.Method = rec.Path, rec.URL, method
+	}
+	return f, nil
+}
+
+// byName looks name up in the index and returns the record accepted by
+// keep (any record when keep is nil) whose remote directory best matches
+// the local one, e.g. ".../Nintendo - Game Boy/x.zip" prefers
+// "No-Intro/Nintendo - Game Boy/x.zip" over other collections.
+func (s *Scanner) byName(p, name string, keep func(index.FileRecord) bool) (index.FileRecord, bool, error) {
+	recs, err := s.index.FilesNamed(name)
+	if err != nil {
+		return index.FileRecord{}, false, fmt.Errorf("looking up %s: %w", name, err)
+	}
+	local := map[string]bool{}
+	for _, seg := range strings.Split(filepath.ToSlash(filepath.Dir(p)), "/") {
+		local[seg] = true
+	}
+	best, bestScore := -1, -1
+	for i, r := range recs {
+		if keep != nil && !keep(r) {
+			continue
+		}
+		score := 0
+		for _, seg := range strings.Split(strings.TrimSuffix(r.Path, "/"+r.Name), "/") {
+			if local[seg] {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return index.FileRecord{}, false, nil
+	}
+	return recs[best], true, nil
+}
+
+// byDAT finds the indexed archive of the DAT game a ROM with the given
+// SHA-1 belongs to, e.g. the remote "Game (USA).zip" for an unpacked
+// "Game (USA).gba".
+func (s *Scanner) byDAT(p, sha string) (index.FileRecord, bool, error) {
+	roms, err := s.dats.FindROMs("sha1", sha)
+	if err != nil {
+		return index.FileRecord{}, false, fmt.Errorf("looking up DAT checksums: %w", err)
+	}
+	for _, m := range roms {
+		for _, ext := range archiveExts {
+			rec, ok, err := s.byName(p, m.Game+ext, nil)
+			if err != nil || ok {
+				return rec, ok, err
+			}
+		}
+	}
+	return index.FileRecord{}, false, nil
+}
+
+// hashFile returns the SHA-1 and CRC32 of the file at p as lowercase hex.
+func hashFile(p string) (sha, crc string, err error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	sh, cr := sha1.New(), crc32.NewIEEE()
+	if _, err := io.Copy(io.MultiWriter(sh, cr), f); err != nil {
+		return "", "", fmt.Errorf("hashing %s: %w", p, err)
+	}
+	return hex.EncodeToString(sh.Sum(nil)), hex.EncodeToString(cr.Sum(nil)), nil
+}
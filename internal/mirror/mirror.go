@@ -0,0 +1,169 @@
+// Package mirror plans one-way syncs of a remote directory tree into a local
+// folder: which files are new, which changed upstream and which local files
+// no longer exist remotely.
+package mirror
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+	"github.com/JohnDeved/myrient-cli/internal/walk"
+)
+
+// QuarantineDir is the folder below the local root that receives files
+// removed upstream when quarantining. It is never synced itself.
+const QuarantineDir = ".myrient-quarantine"
+
+// dateSlack absorbs listings that only show minutes.
+const dateSlack = time.Minute
+
+// Change is a remote file whose local copy is out of date.
+type Change struct {
+	File   walk.File
+	Reason string
+}
+
+// Plan lists what a sync has to do. Paths are relative to the synced
+// directory and use "/".
+type Plan struct {
+	New       []walk.File
+	Changed   []Change
+	Removed   []string
+	Unchanged int
+}
+
+// Empty reports whether the local folder is already in sync.
+func (p *Plan) Empty() bool {
+	return len(p.New) == 0 && len(p.Changed) == 0 && len(p.Removed) == 0
+}
+
+// Compute compares the remote files with the contents of localDir. A local
+// file is up to date when its size is consistent with the listed size and
+// it is not older than the listed date. Local files that do not pass the
+// filter are left out, so narrowing a sync never marks them as removed.
+func Compute(remote []walk.File, localDir string, filter *walk.Filter) (*Plan, error) {
+	local, err := scanLocal(localDir)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{}
+	seen := make(map[string]bool, len(remote))
+	for _, f := range remote {
+		seen[f.RelPath] = true
+		info, ok := local[f.RelPath]
+		if !ok {
+			plan.New = append(plan.New, f)
+			continue
+		}
+		if reason := changeReason(f, info); reason != "" {
+			plan.Changed = append(plan.Changed, Change{File: f, Reason: reason})
+			continue
+		}
+		plan.Unchanged++
+	}
+
+	for rel, info := range local {
+		if seen[rel] {
+			continue
+		}
+		if !filter.Match(walk.File{RelPath: rel, Name: path.Base(rel), Size: info.Size()}) {
+			continue
+		}
+		plan.Removed = append(plan.Removed, rel)
+	}
+	sort.Strings(plan.Removed)
+	return plan, nil
+}
+
+func changeReason(f walk.File, info fs.FileInfo) string {
+	if f.SizeText != "" && !util.SizeMatches(info.Size(), f.SizeText) {
+		return fmt.Sprintf("size differs (local %s, remote %s)", util.FormatBytes(info.Size()), f.SizeText)
+	}
+	if date, ok := util.ParseListingDate(f.Date); ok && date.After(info.ModTime().Add(dateSlack)) {
+		return fmt.Sprintf("updated upstream (remote %s, local %s)", f.Date, info.ModTime().UTC().Format("2006-01-02 15:04"))
+	}
+	return ""
+}
+
+// scanLocal lists regular files below root by relative path, skipping
+// partial downloads, name maps and the quarantine folder. A missing root is empty.
+func scanLocal(root string) (map[string]fs.FileInfo, error) {
+	files := map[string]fs.FileInfo{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root && os.IsNotExist(err) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == QuarantineDir && p != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(d.Name(), ".part") || d.Name() == downloader.NamesFile {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = info
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scanning %s: %w", root, err)
+	}
+	return files, nil
+}
+
+// Remove deletes a local file that no longer exists upstream, along with
+// directories it leaves empty.
+func Remove(localDir, rel string) error {
+	p := filepath.Join(localDir, filepath.FromSlash(rel))
+	if err := os.Remove(p); err != nil {
+		return err
+	}
+	pruneEmptyDirs(localDir, filepath.Dir(p))
+	return nil
+}
+
+// Quarantine moves a local file that no longer exists upstream into
+// QuarantineDir/batch below localDir, keeping its relative path.
+func Quarantine(localDir, batch, rel string) error {
+	src := filepath.Join(localDir, filepath.FromSlash(rel))
+	dst := filepath.Join(localDir, QuarantineDir, batch, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	pruneEmptyDirs(localDir, filepath.Dir(src))
+	return nil
+}
+
+// pruneEmptyDirs removes dir and its parents up to, not including, root
+// while they are empty.
+func pruneEmptyDirs(root, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package mirror
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/walk"
+)
+
+func writeFile(t *testing.T, root, rel string, size int, mtime time.Time) {
+	t.Helper()
+	p := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeFile(t, root, "same.zip", 2048, old)
+	writeFile(t, root, "resized.zip", 100, old)
+	writeFile(t, root, "sub/stale.zip", 2048, old)
+	writeFile(t, root, "gone.zip", 10, old)
+	writeFile(t, root, "gone.txt", 10, old)
+	writeFile(t, root, "partial.zip.part", 10, old)
+	writeFile(t, root, QuarantineDir+"/x/old.zip", 10, old)
+
+	remote := []walk.File{
+		{RelPath: "same.zip", Name: "same.zip", SizeText: "2.0 KiB", Date: "2023-12-31 10:00"},
+		{RelPath: "resized.zip", Name: "resized.zip", SizeText: "2.0 KiB", Date: "2023-12-31 10:00"},
+		{RelPath: "sub/stale.zip", Name: "stale.zip", SizeText: "2.0 KiB", Date: "2024-02-01 10:00"},
+		{RelPath: "sub/new.zip", Name: "new.zip", SizeText: "1 KiB"},
+	}
+	filter, err := walk.NewFilter([]string{"*.zip"}, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := Compute(remote, root, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.New) != 1 || plan.New[0].RelPath != "sub/new.zip" {
+		t.Errorf("New = %+v", plan.New)
+	}
+	if len(plan.Changed) != 2 || plan.Changed[0].File.RelPath != "resized.zip" || plan.Changed[1].File.RelPath != "sub/stale.zip" {
+		t.Errorf("Changed = %+v", plan.Changed)
+	}
+	if len(plan.Removed) != 1 || plan.Removed[0] != "gone.zip" {
+		t.Errorf("Removed = %v (gone.txt is filtered out, .part and quarantine are skipped)", plan.Removed)
+	}
+	if plan.Unchanged != 1 {
+		t.Errorf("Unchanged = %d", plan.Unchanged)
+	}
+}
+
+func TestQuarantineAndRemove(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	writeFile(t, root, "a/b/one.zip", 1, now)
+	writeFile(t, root, "a/two.zip", 1, now)
+
+	if err := Quarantine(root, "batch", "a/b/one.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, QuarantineDir, "batch", "a", "b", "one.zip")); err != nil {
+		t.Fatalf("quarantined file missing: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a", "b")); !os.IsNotExist(err) {
+		t.Fatalf("empty directory should be pruned, got %v", err)
+	}
+
+	if err := Remove(root, "a/two.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a")); !os.IsNotExist(err) {
+		t.Fatalf("empty directory should be pruned, got %v", err)
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Fatalf("root must survive: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Package parts finds partial downloads (.part files) that no download
+// queue knows about and matches them back to remote files through the
+// local index.
+package parts
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+)
+
+// Suffix is appended to a destination path while it downloads.
+const Suffix = ".part"
+
+// Part is a partial download found on disk.
+type Part struct {
+	// Path is the .part file; Dest is the file it becomes when complete.
+	Path    string    `json:"path"`
+	Dest    string    `json:"dest"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	// URL and RemotePath identify the matched remote file; both are empty
+	// when no match was found.
+	URL        string `json:"url,omitempty"`
+	RemotePath string `json:"remote_path,omitempty"`
+	RemoteSize string `json:"remote_size,omitempty"`
+	// Candidates is the number of indexed files sharing the name when none
+	// of them could be picked.
+	Candidates int `json:"candidates,omitempty"`
+}
+
+// Matched reports whether the part was matched to a remote file.
+func (p Part) Matched() bool {
+	return p.URL != ""
+}
+
+// Request builds the download request that continues the part.
+func (p Part) Request() downloader.Request {
+	return downloader.Request{
+		Name: filepath.Base(p.Dest),
+		URL:  p.URL,
+		Dir:  filepath.Dir(p.Dest),
+	}
+}
+
+// Scan lists the .part files below root, skipping those whose destination
+// known reports as owned by a queue or saved batch. known may be nil. A
+// missing root has no parts.
+func Scan(root string, known func(dest string) bool) ([]Part, error) {
+	var found []Part
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() || !strings.HasSuffix(d.Name(), Suffix) {
+			return nil
+		}
+		dest := strings.TrimSuffix(p, Suffix)
+		if known != nil && known(dest) {
+			return nil
+		}
+		// The rest of an archive being extracted is not a plain partial
+		// download; only its own item can continue it.
+		if downloader.Extracting(dest) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		found = append(found, Part{Path: p, Dest: dest, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scanning %s: %w", root, err)
+	}
+	return found, nil
+}
+
+// Match looks up each part's file name in the index. A candidate whose
+// remote path is a suffix of the destination below root wins (the longest
+// one if several are); otherwise a name that is unique in the index is
+// taken. Parts already matched are left alone.
+func Match(db *index.DB, root string, parts []Part) error {
+	for i := range parts {
+		p := &parts[i]
+		if p.Matched() {
+			continue
+		}
+		records, err := db.FilesNamed(filepath.Base(p.Dest))
+		if err != nil {
+			return fmt.Errorf("looking up %s: %w", filepath.Base(p.Dest), err)
+		}
+		if best, ok := pick(records, relSlash(root, p.Dest)); ok {
+			p.URL, p.RemotePath, p.RemoteSize = best.URL, best.Path, best.Size
+			p.Candidates = 0
+			continue
+		}
+		p.Candidates = len(records)
+	}
+	return nil
+}
+
+func pick(records []index.FileRecord, rel string) (index.FileRecord, bool) {
+	var best index.FileRecord
+	found := false
+	for _, r := range records {
+		if rel == r.Path || strings.HasSuffix(rel, "/"+r.Path) {
+			if !found || len(r.Path) > len(best.Path) {
+				best, found = r, true
+			}
+		}
+	}
+	if found {
+		return best, true
+	}
+	if len(records) == 1 {
+		return records[0], true
+	}
+	return index.FileRecord{}, false
+}
+
+// relSlash returns dest relative to root with "/" separators, or dest
+// itself when it is not below root.
+func relSlash(root, dest string) string {
+	rel, err := filepath.Rel(root, dest)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(dest)
+	}
+	return path.Clean(filepath.ToSlash(rel))
+}
+
+// Adopt adds the matched parts to dlm as paused downloads and returns how
+// many were added.
+func Adopt(dlm *downloader.Manager, parts []Part) int {
+	adopted := 0
+	for _, p := range parts {
+		if !p.Matched() {
+			continue
+		}
+		if _, created := dlm.Adopt(p.Request()); created {
+			adopted++
+		}
+	}
+	return adopted
+}
@@ -0,0 +1,76 @@
+package parts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/JohnDeved/myrient-cli/internal/index"
+)
+
+func TestScanAndMatch(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{
+		"No-Intro/Nintendo - Game Boy/Tetris (World).zip.part",
+		"elsewhere/Unique (USA).zip.part",
+		"Shared (Europe).zip.part",
+		"Nothing.zip.part",
+		"Owned.zip.part",
+		"Done.zip",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := index.OpenDB(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, f := range []struct{ name, path string }{
+		{"Tetris (World).zip", "No-Intro/Nintendo - Game Boy/Tetris (World).zip"},
+		{"Tetris (World).zip", "No-Intro/Nintendo - Game Boy Color/Tetris (World).zip"},
+		{"Unique (USA).zip", "Redump/Sony/Unique (USA).zip"},
+		{"Shared (Europe).zip", "A/Shared (Europe).zip"},
+		{"Shared (Europe).zip", "B/Shared (Europe).zip"},
+	} {
+		if err := db.InsertFile(f.name, f.path, "https://example.org/files/"+f.path, "1 KiB", "", 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	owned := filepath.Join(root, "Owned.zip")
+	found, err := Scan(root, func(dest string) bool { return dest == owned })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 4 {
+		t.Fatalf("Scan found %d parts, want 4: %+v", len(found), found)
+	}
+	if err := Match(db, root, found); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]Part{}
+	for _, p := range found {
+		rel, _ := filepath.Rel(root, p.Dest)
+		got[filepath.ToSlash(rel)] = p
+	}
+	if p := got["No-Intro/Nintendo - Game Boy/Tetris (World).zip"]; p.RemotePath != "No-Intro/Nintendo - Game Boy/Tetris (World).zip" {
+		t.Errorf("path match picked %q", p.RemotePath)
+	}
+	if p := got["elsewhere/Unique (USA).zip"]; p.RemotePath != "Redump/Sony/Unique (USA).zip" {
+		t.Errorf("unique name match picked %q", p.RemotePath)
+	}
+	if p := got["Shared (Europe).zip"]; p.Matched() || p.Candidates != 2 {
+		t.Errorf("ambiguous part = %+v", p)
+	}
+	if p := got["Nothing.zip"]; p.Matched() || p.Candidates != 0 {
+		t.Errorf("unknown part = %+v", p)
+	}
+}
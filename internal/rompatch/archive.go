@@ -0,0 +1,70 @@
+package rompatch
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// PatchFile returns the patch stored in a file called name. Patches are
+// often distributed in ZIP archives next to a readme; for those the single
+// patch inside is returned along with its name.
+func PatchFile(name string, data []byte) (string, []byte, error) {
+	if !isZip(name) {
+		return name, data, nil
+	}
+	return fromZip(name, data, IsPatchFile, "patch")
+}
+
+// ROMFile returns the ROM stored in a file called name. For ZIP archives,
+// as downloaded from Myrient, the largest member that is neither a patch
+// nor a text file is returned along with its name.
+func ROMFile(name string, data []byte) (string, []byte, error) {
+	if !isZip(name) {
+		return name, data, nil
+	}
+	return fromZip(name, data, func(member string) bool {
+		switch strings.ToLower(path.Ext(member)) {
+		case ".txt", ".nfo", ".diz", ".md", ".pdf":
+			return false
+		}
+		return !IsPatchFile(member)
+	}, "ROM")
+}
+
+func isZip(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".zip")
+}
+
+// fromZip reads the largest member of a ZIP archive that matches keep.
+func fromZip(name string, data []byte, keep func(string) bool, what string) (string, []byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	var best *zip.File
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !keep(f.Name) {
+			continue
+		}
+		if best == nil || f.UncompressedSize64 > best.UncompressedSize64 {
+			best = f
+		}
+	}
+	if best == nil {
+		return "", nil, fmt.Errorf("no %s found in %s", what, name)
+	}
+	rc, err := best.Open()
+	if err != nil {
+		return "", nil, fmt.Errorf("reading %s from %s: %w", best.Name, name, err)
+	}
+	defer rc.Close()
+	out, err := io.ReadAll(rc)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading %s from %s: %w", best.Name, name, err)
+	}
+	return path.Base(best.Name), out, nil
+}
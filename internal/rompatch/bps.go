@@ -0,0 +1,109 @@
+package rompatch
+
+import "fmt"
+
+// BPS actions, stored in the low two bits of each command.
+const (
+	bpsSourceRead = iota
+	bpsTargetRead
+	bpsSourceCopy
+	bpsTargetCopy
+)
+
+func applyBPS(patch, source []byte) ([]byte, error) {
+	f, err := readFooter(patch)
+	if err != nil {
+		return nil, err
+	}
+	r := &reader{data: patch, pos: len("BPS1"), end: len(patch) - 12}
+	sourceSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	metadataSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.bytes(metadataSize); err != nil {
+		return nil, err
+	}
+	if err := checkSource(source, sourceSize, f.source); err != nil {
+		return nil, err
+	}
+	if targetSize > maxTarget {
+		return nil, fmt.Errorf("%w: target size %d", ErrCorrupt, targetSize)
+	}
+
+	out := make([]byte, targetSize)
+	var outPos, sourceRel, targetRel int64
+	for r.pos < r.end {
+		cmd, err := r.number()
+		if err != nil {
+			return nil, err
+		}
+		n := int64(cmd>>2) + 1
+		if n > int64(targetSize)-outPos {
+			return nil, fmt.Errorf("%w: action past the end of the target", ErrCorrupt)
+		}
+		switch cmd & 3 {
+		case bpsSourceRead:
+			if outPos+n > int64(len(source)) {
+				return nil, fmt.Errorf("%w: read past the end of the source", ErrCorrupt)
+			}
+			copy(out[outPos:], source[outPos:outPos+n])
+		case bpsTargetRead:
+			data, err := r.bytes(uint64(n))
+			if err != nil {
+				return nil, err
+			}
+			copy(out[outPos:], data)
+		case bpsSourceCopy:
+			if sourceRel, err = r.relative(sourceRel); err != nil {
+				return nil, err
+			}
+			if sourceRel < 0 || sourceRel+n > int64(len(source)) {
+				return nil, fmt.Errorf("%w: copy outside the source", ErrCorrupt)
+			}
+			copy(out[outPos:], source[sourceRel:sourceRel+n])
+			sourceRel += n
+		case bpsTargetCopy:
+			if targetRel, err = r.relative(targetRel); err != nil {
+				return nil, err
+			}
+			if targetRel < 0 || targetRel >= outPos {
+				return nil, fmt.Errorf("%w: copy outside the target", ErrCorrupt)
+			}
+			// The ranges may overlap to repeat a pattern, so copy
+			// byte by byte.
+			for i := int64(0); i < n; i++ {
+				out[outPos+i] = out[targetRel+i]
+			}
+			targetRel += n
+		}
+		outPos += n
+	}
+	if outPos != int64(targetSize) {
+		return nil, fmt.Errorf("%w: target ends early", ErrCorrupt)
+	}
+	if err := checkTarget(out, f.target); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// relative decodes a signed offset and applies it to base.
+func (r *reader) relative(base int64) (int64, error) {
+	v, err := r.number()
+	if err != nil {
+		return 0, err
+	}
+	delta := int64(v >> 1)
+	if v&1 != 0 {
+		delta = -delta
+	}
+	return base + delta, nil
+}
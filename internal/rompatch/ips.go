@@ -0,0 +1,54 @@
+package rompatch
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ipsEOF marks the end of the records of an IPS patch.
+const ipsEOF = 0x454f46
+
+func applyIPS(patch, source []byte) ([]byte, error) {
+	r := &reader{data: patch, pos: len("PATCH"), end: len(patch)}
+	out := bytes.Clone(source)
+	for {
+		head, err := r.bytes(3)
+		if err != nil {
+			return nil, err
+		}
+		offset := int(head[0])<<16 | int(head[1])<<8 | int(head[2])
+		if offset == ipsEOF {
+			break
+		}
+		size, err := r.bytes(2)
+		if err != nil {
+			return nil, err
+		}
+		n := int(size[0])<<8 | int(size[1])
+		var data []byte
+		if n == 0 {
+			// Run-length record: a 16-bit count and the byte to repeat.
+			rle, err := r.bytes(3)
+			if err != nil {
+				return nil, err
+			}
+			data = bytes.Repeat(rle[2:3], int(rle[0])<<8|int(rle[1]))
+		} else if data, err = r.bytes(uint64(n)); err != nil {
+			return nil, err
+		}
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+	// An optional 24-bit size after the end marker truncates the output.
+	if r.end-r.pos >= 3 {
+		tail, _ := r.bytes(3)
+		size := int(tail[0])<<16 | int(tail[1])<<8 | int(tail[2])
+		if size > len(out) {
+			return nil, fmt.Errorf("%w: truncation beyond the end of the ROM", ErrCorrupt)
+		}
+		out = out[:size]
+	}
+	return out, nil
+}
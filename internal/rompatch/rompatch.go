@@ -0,0 +1,202 @@
+// Package rompatch applies IPS, UPS and BPS soft patches, such as the
+// translations and hacks of the T-En Collection, to base ROMs.
+package rompatch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path"
+	"strings"
+)
+
+// Format identifies a patch format.
+type Format int
+
+const (
+	// FormatIPS patches carry no checksums and cannot exceed 16 MiB.
+	FormatIPS Format = iota
+	// FormatUPS patches record the size and CRC32 of source and target.
+	FormatUPS
+	// FormatBPS patches record the size and CRC32 of source and target and
+	// copy data around, so they stay small for moved content.
+	FormatBPS
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatIPS:
+		return "ips"
+	case FormatUPS:
+		return "ups"
+	case FormatBPS:
+		return "bps"
+	default:
+		return "unknown"
+	}
+}
+
+var (
+	// ErrUnknownFormat is returned for data that is not a patch.
+	ErrUnknownFormat = errors.New("unknown patch format")
+	// ErrUnsupported is returned for patch formats that are recognized
+	// but cannot be applied, such as xdelta.
+	ErrUnsupported = errors.New("unsupported patch format")
+	// ErrCorrupt is returned for truncated or malformed patches and for
+	// patches whose own checksum does not match.
+	ErrCorrupt = errors.New("corrupt patch")
+	// ErrSourceMismatch is returned when the ROM is not the one the patch
+	// was made for.
+	ErrSourceMismatch = errors.New("source ROM does not match the patch")
+	// ErrTargetMismatch is returned when the patched output does not
+	// match the checksum recorded in the patch.
+	ErrTargetMismatch = errors.New("patched ROM does not match the patch")
+)
+
+// xdeltaMagic starts VCDIFF (xdelta3) patches.
+var xdeltaMagic = []byte{0xd6, 0xc3, 0xc4}
+
+// Detect returns the format of a patch from its header.
+func Detect(patch []byte) (Format, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte("PATCH")):
+		return FormatIPS, nil
+	case bytes.HasPrefix(patch, []byte("UPS1")):
+		return FormatUPS, nil
+	case bytes.HasPrefix(patch, []byte("BPS1")):
+		return FormatBPS, nil
+	case bytes.HasPrefix(patch, xdeltaMagic):
+		return 0, fmt.Errorf("%w: xdelta", ErrUnsupported)
+	}
+	return 0, ErrUnknownFormat
+}
+
+// Apply patches source and returns the patched ROM. For UPS and BPS
+// patches the source and the result are checked against the recorded
+// sizes and checksums.
+func Apply(patch, source []byte) ([]byte, error) {
+	format, err := Detect(patch)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatIPS:
+		return applyIPS(patch, source)
+	case FormatUPS:
+		return applyUPS(patch, source)
+	default:
+		return applyBPS(patch, source)
+	}
+}
+
+// patchExts lists the file extensions of patches, including the formats
+// Apply rejects, so they are recognized inside archives.
+var patchExts = map[string]bool{".ips": true, ".ups": true, ".bps": true, ".xdelta": true, ".vcdiff": true}
+
+// IsPatchFile reports whether name has the extension of a patch.
+func IsPatchFile(name string) bool {
+	return patchExts[strings.ToLower(path.Ext(name))]
+}
+
+// OutputName returns the file name of the patched ROM: the patch's name
+// with the ROM's extension, so "Game (Japan).sfc" patched with
+// "Game (Japan) [T-En by X].bps" becomes "Game (Japan) [T-En by X].sfc".
+func OutputName(romName, patchName string) string {
+	ext := path.Ext(romName)
+	out := strings.TrimSuffix(patchName, path.Ext(patchName)) + ext
+	if out == romName {
+		out = strings.TrimSuffix(romName, ext) + " (Patched)" + ext
+	}
+	return out
+}
+
+// footer holds the checksums that end UPS and BPS patches.
+type footer struct {
+	source, target uint32
+}
+
+// readFooter checks the patch's own checksum and returns the recorded
+// source and target checksums.
+func readFooter(patch []byte) (footer, error) {
+	if len(patch) < 12 {
+		return footer{}, ErrCorrupt
+	}
+	tail := patch[len(patch)-12:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(tail[8:]) {
+		return footer{}, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	return footer{
+		source: binary.LittleEndian.Uint32(tail[0:]),
+		target: binary.LittleEndian.Uint32(tail[4:]),
+	}, nil
+}
+
+// checkSource verifies the ROM against the size and checksum recorded in
+// the patch.
+func checkSource(source []byte, size uint64, crc uint32) error {
+	if uint64(len(source)) != size {
+		return fmt.Errorf("%w: size %d, want %d", ErrSourceMismatch, len(source), size)
+	}
+	if got := crc32.ChecksumIEEE(source); got != crc {
+		return fmt.Errorf("%w: CRC32 %08x, want %08x", ErrSourceMismatch, got, crc)
+	}
+	return nil
+}
+
+// checkTarget verifies the patched ROM against the recorded checksum.
+func checkTarget(target []byte, crc uint32) error {
+	if got := crc32.ChecksumIEEE(target); got != crc {
+		return fmt.Errorf("%w: CRC32 %08x, want %08x", ErrTargetMismatch, got, crc)
+	}
+	return nil
+}
+
+// reader decodes the fields of a patch body.
+type reader struct {
+	data []byte
+	pos  int
+	end  int
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.pos >= r.end {
+		return 0, fmt.Errorf("%w: truncated", ErrCorrupt)
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) bytes(n uint64) ([]byte, error) {
+	if n > uint64(r.end-r.pos) {
+		return nil, fmt.Errorf("%w: truncated", ErrCorrupt)
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// number decodes the variable-length integers used by UPS and BPS.
+func (r *reader) number() (uint64, error) {
+	var value uint64
+	shift := uint64(1)
+	for i := 0; i < 10; i++ {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		value += uint64(b&0x7f) * shift
+		if b&0x80 != 0 {
+			return value, nil
+		}
+		shift <<= 7
+		value += shift
+	}
+	return 0, fmt.Errorf("%w: number too large", ErrCorrupt)
+}
+
+// maxTarget bounds the output size a patch can ask for, so a corrupt
+// header does not allocate unbounded memory.
+const maxTarget = 1 << 32
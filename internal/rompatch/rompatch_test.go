@@ -0,0 +1,156 @@
+package rompatch
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// encodeNumber writes the variable-length integers of UPS and BPS.
+func encodeNumber(buf *bytes.Buffer, v uint64) {
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			buf.WriteByte(b | 0x80)
+			return
+		}
+		buf.WriteByte(b)
+		v--
+	}
+}
+
+// finish appends the source, target and patch checksums.
+func finish(buf *bytes.Buffer, source, target []byte) []byte {
+	binary.Write(buf, binary.LittleEndian, crc32.ChecksumIEEE(source))
+	binary.Write(buf, binary.LittleEndian, crc32.ChecksumIEEE(target))
+	binary.Write(buf, binary.LittleEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	return buf.Bytes()
+}
+
+func TestApply_IPS(t *testing.T) {
+	source := []byte("0123456789")
+	var p bytes.Buffer
+	p.WriteString("PATCH")
+	p.Write([]byte{0, 0, 2, 0, 3})
+	p.WriteString("abc")
+	// Run of four 'z' starting past the end of the source.
+	p.Write([]byte{0, 0, 9, 0, 0, 0, 4, 'z'})
+	p.WriteString("EOF")
+	want := []byte("01abc5678zzzz")
+	got, err := Apply(p.Bytes(), source)
+	if err != nil || !bytes.Equal(got, want) {
+		t.Fatalf("Apply = %q, %v; want %q", got, err, want)
+	}
+
+	// A size after the end marker truncates the output.
+	p.Write([]byte{0, 0, 5})
+	if got, err := Apply(p.Bytes(), source); err != nil || string(got) != "01abc" {
+		t.Fatalf("truncated Apply = %q, %v", got, err)
+	}
+	if !bytes.Equal(source, []byte("0123456789")) {
+		t.Error("source was modified")
+	}
+}
+
+func TestApply_UPS(t *testing.T) {
+	source := []byte("hello world")
+	target := []byte("jello world!!")
+	var p bytes.Buffer
+	p.WriteString("UPS1")
+	encodeNumber(&p, uint64(len(source)))
+	encodeNumber(&p, uint64(len(target)))
+	encodeNumber(&p, 0)
+	p.WriteByte('h' ^ 'j')
+	p.WriteByte(0)
+	encodeNumber(&p, 9) // skip to offset 11, past the source
+	p.Write([]byte{'!', '!', 0})
+	patch := finish(&p, source, target)
+
+	got, err := Apply(patch, source)
+	if err != nil || !bytes.Equal(got, target) {
+		t.Fatalf("Apply = %q, %v; want %q", got, err, target)
+	}
+	if _, err := Apply(patch, []byte("hello World")); !errors.Is(err, ErrSourceMismatch) {
+		t.Errorf("wrong source: err = %v, want ErrSourceMismatch", err)
+	}
+	patch[5] ^= 1
+	if _, err := Apply(patch, source); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("damaged patch: err = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestApply_BPS(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	target := []byte("ABCDxyxyxyEFGH")
+	var p bytes.Buffer
+	p.WriteString("BPS1")
+	encodeNumber(&p, uint64(len(source)))
+	encodeNumber(&p, uint64(len(target)))
+	encodeNumber(&p, 4)
+	p.WriteString("meta")
+	action := func(kind, n uint64) { encodeNumber(&p, (n-1)<<2|kind) }
+	action(bpsSourceRead, 4) // ABCD
+	action(bpsTargetRead, 2) // xy
+	p.WriteString("xy")
+	action(bpsTargetCopy, 4) // xyxy, overlapping its own output
+	encodeNumber(&p, 4<<1)
+	action(bpsSourceCopy, 4) // EFGH
+	encodeNumber(&p, 4<<1)
+	patch := finish(&p, source, target)
+
+	got, err := Apply(patch, source)
+	if err != nil || !bytes.Equal(got, target) {
+		t.Fatalf("Apply = %q, %v; want %q", got, err, target)
+	}
+	if _, err := Apply(patch, source[:7]); !errors.Is(err, ErrSourceMismatch) {
+		t.Errorf("short source: err = %v, want ErrSourceMismatch", err)
+	}
+}
+
+func TestDetect_RejectsXdelta(t *testing.T) {
+	if _, err := Detect([]byte{0xd6, 0xc3, 0xc4, 0}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("xdelta: err = %v, want ErrUnsupported", err)
+	}
+	if _, err := Detect([]byte("not a patch")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("err = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestPatchAndROMFile_FromZip(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range map[string]string{
+		"readme.txt":       "a long readme describing the translation",
+		"Game [T-En].ips":  "PATCH",
+		"Game (Japan).sfc": "rom",
+		"extras/notes.nfo": "notes notes notes notes notes",
+	} {
+		fw, _ := w.Create(name)
+		fw.Write([]byte(data))
+	}
+	w.Close()
+
+	name, data, err := PatchFile("Game [T-En].zip", buf.Bytes())
+	if err != nil || name != "Game [T-En].ips" || string(data) != "PATCH" {
+		t.Errorf("PatchFile = %q, %q, %v", name, data, err)
+	}
+	name, data, err = ROMFile("Game (Japan).zip", buf.Bytes())
+	if err != nil || name != "Game (Japan).sfc" || string(data) != "rom" {
+		t.Errorf("ROMFile = %q, %q, %v", name, data, err)
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	for _, tc := range []struct{ rom, patch, want string }{
+		{"Game (Japan).sfc", "Game (Japan) [T-En by X].bps", "Game (Japan) [T-En by X].sfc"},
+		{"Game (Japan).sfc", "Game (Japan).ips", "Game (Japan) (Patched).sfc"},
+	} {
+		if got := OutputName(tc.rom, tc.patch); got != tc.want {
+			t.Errorf("OutputName(%q, %q) = %q, want %q", tc.rom, tc.patch, got, tc.want)
+		}
+	}
+}
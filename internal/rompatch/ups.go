@@ -0,0 +1,57 @@
+package rompatch
+
+import "fmt"
+
+func applyUPS(patch, source []byte) ([]byte, error) {
+	f, err := readFooter(patch)
+	if err != nil {
+		return nil, err
+	}
+	r := &reader{data: patch, pos: len("UPS1"), end: len(patch) - 12}
+	sourceSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSource(source, sourceSize, f.source); err != nil {
+		return nil, err
+	}
+	if targetSize > maxTarget {
+		return nil, fmt.Errorf("%w: target size %d", ErrCorrupt, targetSize)
+	}
+
+	// Hunks XOR the source with the patch bytes; bytes past the end of the
+	// source read as zero.
+	out := make([]byte, targetSize)
+	copy(out, source)
+	pos := uint64(0)
+	for r.pos < r.end {
+		skip, err := r.number()
+		if err != nil {
+			return nil, err
+		}
+		pos += skip
+		for {
+			b, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			if b == 0 {
+				pos++
+				break
+			}
+			if pos >= targetSize {
+				return nil, fmt.Errorf("%w: hunk past the end of the target", ErrCorrupt)
+			}
+			out[pos] ^= b
+			pos++
+		}
+	}
+	if err := checkTarget(out, f.target); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
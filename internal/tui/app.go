@@ -3,6 +3,9 @@ package tui
 import (
 	"context"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
 	"sort"
 	"sync"
 	"strings"
@@ -14,8 +17,15 @@ import (
 
 	"github.com/JohnDeved/myrient-cli/internal/client"
 	"github.com/JohnDeved/myrient-cli/internal/config"
+	"github.com/JohnDeved/myrient-cli/internal/daemon"
+	"github.com/JohnDeved/myrient-cli/internal/destpath"
+	"github.com/JohnDeved/myrient-cli/internal/discset"
 	"github.com/JohnDeved/myrient-cli/internal/downloader"
+	"github.com/JohnDeved/myrient-cli/internal/history"
 	"github.com/JohnDeved/myrient-cli/internal/index"
+	"github.com/JohnDeved/myrient-cli/internal/library"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+	"github.com/JohnDeved/myrient-cli/internal/walk"
 )
 
 // Tab identifies the active view.
@@ -32,6 +42,7 @@ type entriesMsg struct {
 	entries []client.Entry
 	path    []string
 	dirPath string
+	owned   map[string]bool
 }
 
 type errMsg struct{ err error }
@@ -85,13 +96,25 @@ func (j *searchJob) getResults() []index.SearchResult {
 	return out
 }
 
-type downloadUpdateMsg struct{}
+type downloadEventMsg struct{ ev downloader.Event }
+
+// downloadsRefreshMsg carries queue snapshots fetched outside Update, since
+// listing a daemon's queue is a round trip.
+type downloadsRefreshMsg struct{ items []downloader.Snapshot }
+
+// dirWalkMsg carries the files found below a directory selected for a
+// recursive download, pending user confirmation.
+type dirWalkMsg struct {
+	dirPath string // remote path of the walked directory, with trailing slash
+	files   []walk.File
+	err     error
+}
 
 // Model is the main Bubble Tea model.
 type Model struct {
 	client       *client.Client
 	db           *index.DB
-	dlManager    *downloader.Manager
+	queue        downloadQueue
 	cfg          *config.Config
 	activeTab    Tab
 	browser      browserModel
@@ -111,11 +134,23 @@ type Model struct {
 	searchLastRefresh time.Time
 	indexRefreshRunning bool
 	indexRefreshCrawler *index.Crawler
+	dirWalking   bool
+	dirConfirm   *dirWalkMsg
+	downloadsRefreshing bool
+	pathTmpl     *destpath.Template
+	historyDB    *history.DB
+	libraryDB    *library.DB
+	history      historyModel
+	showHistory  bool
+	knownParts   func(dest string) bool
 }
 
 type RunOptions struct {
 	AltScreen   bool
 	MouseMotion bool
+	// KnownParts reports destinations whose .part files belong to a saved
+	// CLI batch; the startup scan leaves them alone.
+	KnownParts func(dest string) bool
 }
 
 // NewModel creates the TUI model.
@@ -123,12 +158,35 @@ func NewModel(c *client.Client, db *index.DB, cfg *config.Config, startPath stri
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 
-	dlm := downloader.NewManager(c, cfg.DownloadDir, cfg.MaxConcurrentDownloads)
+	// Hand downloads to a running daemon so they survive the TUI exiting.
+	// The daemon records its own history; the TUI still reads it.
+	hdb, _ := history.Open(config.HistoryDBPath())
+	// Owned files are only marked once "myrient library scan" has run.
+	var ldb *library.DB
+	if _, err := os.Stat(config.LibraryDBPath()); err == nil {
+		ldb, _ = library.Open(config.LibraryDBPath())
+	}
+	var queue downloadQueue
+	if dc, err := daemon.Dial(config.SocketPath()); err == nil {
+		queue = newRemoteQueue(dc)
+	} else {
+		dlm := downloader.NewManager(c, cfg.DownloadDir, cfg.MaxConcurrentDownloads)
+		dlm.ApplyConfig(cfg)
+		if hdb != nil {
+			dlm.SetFinishHook(hdb.Hook(c.BaseURL(), nil))
+		}
+		queue = localQueue{dlm}
+	}
+
+	tmpl, tmplErr := destpath.Parse(cfg.PathTemplate)
+	if tmplErr != nil {
+		tmpl, _ = destpath.Parse(destpath.DefaultTemplate)
+	}
 
 	m := Model{
 		client:    c,
 		db:        db,
-		dlManager: dlm,
+		queue:     queue,
 		cfg:       cfg,
 		activeTab: TabBrowse,
 		browser:   newBrowserModel(),
@@ -138,6 +196,12 @@ func NewModel(c *client.Client, db *index.DB, cfg *config.Config, startPath stri
 		startPath: startPath,
 		width:     100,
 		height:    30,
+		pathTmpl:  tmpl,
+		historyDB: hdb,
+		libraryDB: ldb,
+	}
+	if tmplErr != nil {
+		m.statusMsg = fmt.Sprintf("Invalid path_template, using %s: %v", destpath.DefaultTemplate, tmplErr)
 	}
 
 	return m
@@ -147,6 +211,7 @@ func (m Model) Init() tea.Cmd {
 	return tea.Batch(
 		m.spinner.Tick,
 		m.loadDirectory(m.startPath),
+		m.adoptParts(),
 	)
 }
 
@@ -158,9 +223,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.width = msg.Width
 		m.height = msg.Height
 		viewHeight := m.height - 8 // Account for header, tabs, status bar
-		m.browser.height = viewHeight
+		m.browser.height = viewHeight - 1 // Destination preview line
 		m.search.height = viewHeight - 3
 		m.downloads.height = viewHeight - 2
+		m.history.height = viewHeight - 2
 		return m, nil
 
 	case tea.KeyMsg:
@@ -171,6 +237,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case entriesMsg:
 		m.browser.setPathAndEntries(msg.path, msg.entries)
+		m.browser.setOwned(msg.owned)
 		return m, m.indexFromBrowseSnapshot(msg)
 
 	case browseIndexErrMsg:
@@ -260,8 +327,54 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.search.bgErrors = p.Errors
 		return m, m.indexRefreshTick()
 
-	case downloadUpdateMsg:
-		m.downloads.setItems(m.dlManager.Items())
+	case dirWalkMsg:
+		m.dirWalking = false
+		if msg.err != nil {
+			return m, m.setStatus(fmt.Sprintf("Listing /%s failed: %v", msg.dirPath, msg.err))
+		}
+		if len(msg.files) == 0 {
+			return m, m.setStatus(fmt.Sprintf("No files under /%s", msg.dirPath))
+		}
+		m.dirConfirm = &msg
+		return m, nil
+
+	case partsAdoptedMsg:
+		if status := msg.status(); status != "" {
+			return m, m.setStatus(status)
+		}
+		return m, nil
+
+	case historyLoadedMsg:
+		m.history.err = msg.err
+		m.history.setEntries(msg.entries)
+		return m, nil
+
+	case downloadEventMsg:
+		// Events can arrive many times a second; refresh the list at most
+		// once per interval instead of for each of them.
+		var refresh tea.Cmd
+		if !m.downloadsRefreshing {
+			m.downloadsRefreshing = true
+			refresh = m.downloadsRefresh()
+		}
+		switch msg.ev.Type {
+		case downloader.EventCompleted:
+			if msg.ev.Status == downloader.StatusPresent {
+				return m, tea.Batch(refresh, m.setStatus(fmt.Sprintf("Already present: %s", msg.ev.Name)))
+			}
+			return m, tea.Batch(refresh, m.setStatus(fmt.Sprintf("Downloaded: %s", msg.ev.Name)), m.reloadHistory())
+		case downloader.EventFailed:
+			if msg.ev.Class != downloader.ClassCancelled {
+				return m, tea.Batch(refresh, m.setStatus(fmt.Sprintf("Failed (%s): %s", msg.ev.Class, msg.ev.Name)), m.reloadHistory())
+			}
+		case downloader.EventRetrying:
+			return m, tea.Batch(refresh, m.setStatus(fmt.Sprintf("Retrying after %s error: %s", msg.ev.Class, msg.ev.Name)))
+		}
+		return m, refresh
+
+	case downloadsRefreshMsg:
+		m.downloadsRefreshing = false
+		m.downloads.setItems(msg.items)
 		return m, nil
 
 	case statusClearMsg:
@@ -347,6 +460,10 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	if m.dirConfirm != nil {
+		return m.handleDirConfirmKey(key)
+	}
+
 	// In browse view, plain character keys are reserved for filtering.
 	if m.activeTab == TabBrowse && isTypeAheadKey(key) {
 		return m.handleBrowseKey(key)
@@ -360,10 +477,10 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		if m.quitConfirm {
-			m.dlManager.CancelAll()
+			m.queue.CancelAll()
 			return m, tea.Quit
 		}
-		if m.dlManager.HasActive() {
+		if !m.queue.Remote() && m.queue.HasActive() {
 			m.quitConfirm = true
 			return m, m.setStatus("Active downloads running. Press q again to cancel and quit, or Esc to stay")
 		}
@@ -391,7 +508,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m.maybeRefreshIndexInSearchTab()
 		case TabSearch:
 			m.activeTab = TabDownloads
-			m.downloads.setItems(m.dlManager.Items())
+			m.downloads.setItems(m.queue.Snapshots())
 		case TabDownloads:
 			m.activeTab = TabBrowse
 		}
@@ -402,7 +519,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		switch m.activeTab {
 		case TabBrowse:
 			m.activeTab = TabDownloads
-			m.downloads.setItems(m.dlManager.Items())
+			m.downloads.setItems(m.queue.Snapshots())
 		case TabSearch:
 			m.activeTab = TabBrowse
 		case TabDownloads:
@@ -442,7 +559,7 @@ func (m Model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 			if msg.X >= 22 && msg.X <= 36 {
 				m.activeTab = TabDownloads
 				m.search.input.Blur()
-				m.downloads.setItems(m.dlManager.Items())
+				m.downloads.setItems(m.queue.Snapshots())
 				return m, nil
 			}
 		}
@@ -503,10 +620,48 @@ func (m Model) handleBrowseKey(key string) (tea.Model, tea.Cmd) {
 			m.browser.loading = true
 			return m, m.loadDirectory(strings.Join(newPath, "/") + "/")
 		} else if sel != nil {
-			subdir := strings.Join(m.browser.path, "/")
-			return m, m.enqueueDownload(sel.Name, sel.URL, subdir)
+			cmd := m.enqueueDownload(m.browseRemotePath(sel.Name), sel.URL, sel.Size)
+			if _, discs, ok := m.browser.discSet(sel.Name); ok && strings.HasPrefix(m.statusMsg, "Queued") {
+				cmd = m.setStatus(fmt.Sprintf("%s (1 of %d discs; Ctrl+S queues the whole set)", m.statusMsg, len(discs)))
+			}
+			return m, cmd
 		}
 
+	case "ctrl+s":
+		sel := m.browser.selected()
+		if sel == nil || sel.IsDir {
+			return m, m.setStatus("Select a disc of a multi-disc set")
+		}
+		title, discs, ok := m.browser.discSet(sel.Name)
+		if !ok {
+			return m, m.setStatus(fmt.Sprintf("Not part of a multi-disc set: %s", sel.Name))
+		}
+		return m, m.enqueueDiscSet(title, discs)
+
+	case "ctrl+t":
+		sel := m.browser.selected()
+		if sel == nil || sel.IsDir {
+			return m, m.setStatus("Only files can be marked")
+		}
+		m.browser.toggleMark()
+		m.browser.moveDown()
+		return m, m.setStatus(fmt.Sprintf("%d file(s) marked", m.browser.markedCount()))
+
+	case "ctrl+e":
+		return m, m.exportBrowse()
+
+	case "ctrl+g":
+		sel := m.browser.selected()
+		if sel == nil || !sel.IsDir {
+			return m, m.setStatus("Select a directory to download it recursively")
+		}
+		if m.dirWalking {
+			return m, m.setStatus("Already listing a directory, please wait")
+		}
+		dirPath := m.browseRemotePath(sel.Name) + "/"
+		m.dirWalking = true
+		return m, tea.Batch(m.setStatus(fmt.Sprintf("Listing /%s recursively...", dirPath)), m.walkDirectory(dirPath))
+
 	case "backspace", "left":
 		if key == "backspace" && m.browser.filter != "" {
 			m.browser.backspaceFilter()
@@ -543,6 +698,35 @@ func (m Model) handleBrowseKey(key string) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m Model) handleDirConfirmKey(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "y", "Y", "enter":
+		pending := m.dirConfirm
+		m.dirConfirm = nil
+		queued := 0
+		for _, f := range pending.files {
+			subdir, name := m.pathTmpl.Resolve(pending.dirPath + f.RelPath)
+			created, err := m.addDownload(downloader.Request{
+				Name:   name,
+				URL:    f.URL,
+				Subdir: subdir,
+				Size:   f.Size,
+			})
+			if err != nil {
+				return m, m.setStatus(fmt.Sprintf("Queueing failed after %d file(s): %v", queued, err))
+			}
+			if created {
+				queued++
+			}
+		}
+		return m, m.setStatus(fmt.Sprintf("Queued %d file(s) from /%s", queued, pending.dirPath))
+	case "n", "N", "esc", "q", "ctrl+c":
+		m.dirConfirm = nil
+		return m, m.setStatus("Recursive download canceled")
+	}
+	return m, nil
+}
+
 func (m Model) handleSearchKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if m.search.input.Focused() {
 		switch key {
@@ -628,7 +812,7 @@ func (m Model) handleSearchKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd)
 		case "enter":
 			// Download selected result.
 			if sel := m.search.selected(); sel != nil {
-				return m, m.enqueueDownload(sel.Name, sel.URL, sel.CollectionName)
+				return m, m.enqueueDownload(sel.Path, sel.URL, sel.Size)
 			}
 		case "i", "/":
 			m.search.input.Focus()
@@ -641,6 +825,8 @@ func (m Model) handleSearchKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd)
 				status := m.setStatus("Opened result location in browser")
 				return m, tea.Batch(status, m.loadDirectory(path))
 			}
+		case "ctrl+e":
+			return m, m.exportSearchResults()
 		case "esc":
 			m.search.input.Focus()
 		}
@@ -650,7 +836,13 @@ func (m Model) handleSearchKey(key string, msg tea.KeyMsg) (tea.Model, tea.Cmd)
 }
 
 func (m Model) handleDownloadsKey(key string) (tea.Model, tea.Cmd) {
+	if m.showHistory {
+		return m.handleHistoryKey(key)
+	}
 	switch key {
+	case "h":
+		m.showHistory = true
+		return m, m.loadHistory()
 	case "up", "k":
 		m.downloads.moveUp()
 	case "down", "j":
@@ -662,21 +854,18 @@ func (m Model) handleDownloadsKey(key string) (tea.Model, tea.Cmd) {
 	case "c":
 		// Cancel selected download.
 		if sel := m.downloads.selected(); sel != nil {
-			m.dlManager.Cancel(sel.ID)
+			m.queue.Cancel(sel.ID)
 			return m, m.setStatus(fmt.Sprintf("Cancelled: %s", sel.Name))
 		}
 	case "p":
 		if sel := m.downloads.selected(); sel != nil {
-			sel.Mu.Lock()
-			status := sel.Status
-			sel.Mu.Unlock()
-			switch status {
+			switch sel.Status {
 			case downloader.StatusPaused:
-				if m.dlManager.Resume(sel.ID) {
+				if m.queue.Resume(sel.ID) {
 					return m, m.setStatus(fmt.Sprintf("Resumed: %s", sel.Name))
 				}
-			case downloader.StatusActive, downloader.StatusQueued:
-				if m.dlManager.Pause(sel.ID) {
+			case downloader.StatusActive, downloader.StatusQueued, downloader.StatusRetryWait:
+				if m.queue.Pause(sel.ID) {
 					return m, m.setStatus(fmt.Sprintf("Paused: %s", sel.Name))
 				}
 			}
@@ -684,18 +873,18 @@ func (m Model) handleDownloadsKey(key string) (tea.Model, tea.Cmd) {
 		}
 	case "r":
 		// Refresh download list.
-		m.downloads.setItems(m.dlManager.Items())
+		m.downloads.setItems(m.queue.Snapshots())
 	case "R":
 		if sel := m.downloads.selected(); sel != nil {
-			if m.dlManager.Retry(sel.ID) {
+			if m.queue.Retry(sel.ID) {
 				return m, m.setStatus(fmt.Sprintf("Retrying: %s", sel.Name))
 			}
 			return m, m.setStatus("Selected download is not retryable")
 		}
 	case "x":
-		removed := m.dlManager.ClearFinished()
+		removed := m.queue.ClearFinished()
 		if removed > 0 {
-			m.downloads.setItems(m.dlManager.Items())
+			m.downloads.setItems(m.queue.Snapshots())
 			return m, m.setStatus(fmt.Sprintf("Cleared %d finished downloads", removed))
 		}
 		return m, m.setStatus("No finished downloads to clear")
@@ -723,7 +912,23 @@ func (m Model) loadDirectory(path string) tea.Cmd {
 			segments = strings.Split(path, "/")
 		}
 
-		return entriesMsg{entries: entries, path: segments, dirPath: path}
+		msg := entriesMsg{entries: entries, path: segments, dirPath: path}
+		if m.libraryDB != nil {
+			dir := path
+			if dir != "" {
+				dir += "/"
+			}
+			msg.owned, _ = m.libraryDB.OwnedIn(dir)
+		}
+		return msg
+	}
+}
+
+func (m Model) walkDirectory(dirPath string) tea.Cmd {
+	c := m.client
+	return func() tea.Msg {
+		files, err := walk.Remote(context.Background(), c, dirPath, nil)
+		return dirWalkMsg{dirPath: dirPath, files: files, err: err}
 	}
 }
 
@@ -1013,6 +1218,14 @@ func crawlSelectedCollections(ctx context.Context, crawler *index.Crawler, colle
 	return firstErr
 }
 
+// downloadsRefresh fetches the queue after a short delay, off the update loop.
+func (m Model) downloadsRefresh() tea.Cmd {
+	queue := m.queue
+	return tea.Tick(200*time.Millisecond, func(time.Time) tea.Msg {
+		return downloadsRefreshMsg{items: queue.Snapshots()}
+	})
+}
+
 func (m Model) searchProgressTick() tea.Cmd {
 	return tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg {
 		return searchProgressTickMsg{}
@@ -1053,11 +1266,14 @@ func (m Model) View() string {
 	}
 
 	// Add download count badge.
-	dlCount := m.dlManager.ActiveCount()
+	dlCount := m.queue.ActiveCount()
 	if dlCount > 0 {
-		badge := successStyle.Render(fmt.Sprintf(" [%d active]", dlCount))
+		badge := successStyle.Render(fmt.Sprintf(" [%d active, %s/s]", dlCount, util.FormatBytes(int64(m.queue.Throughput()))))
 		tabLine.WriteString(badge)
 	}
+	if m.queue.Remote() {
+		tabLine.WriteString(helpStyle.Render(" (daemon)"))
+	}
 
 	sb.WriteString(tabLine.String())
 	sb.WriteString("\n")
@@ -1075,18 +1291,33 @@ func (m Model) View() string {
 		content := ""
 		switch m.activeTab {
 		case TabBrowse:
-			content = m.browser.view(m.width, m.spinner.View())
+			preview := ""
+			if sel := m.browser.selected(); sel != nil && !sel.IsDir {
+				preview = m.destPreview(m.browseRemotePath(sel.Name))
+			}
+			content = m.browser.view(m.width, m.spinner.View(), preview)
 		case TabSearch:
-			content = m.search.view(m.width, m.spinner.View())
+			preview := ""
+			if sel := m.search.selected(); sel != nil {
+				preview = m.destPreview(sel.Path)
+			}
+			content = m.search.view(m.width, m.spinner.View(), preview)
 		case TabDownloads:
-			content = m.downloads.view(m.width)
+			if m.showHistory {
+				content = m.history.view(m.width)
+			} else {
+				content = m.downloads.view(m.width, m.queueStats())
+			}
 		}
 		sb.WriteString(fitToHeight(content, contentHeight))
 	}
 
 	// Status bar.
 	statusLine := m.statusMsg
-	if statusLine == "" {
+	if m.dirConfirm != nil {
+		statusLine = fmt.Sprintf("Download %d file(s) (%s) from /%s? y/Enter: confirm  n/Esc: cancel",
+			len(m.dirConfirm.files), util.FormatBytes(walk.TotalSize(m.dirConfirm.files)), m.dirConfirm.dirPath)
+	} else if statusLine == "" {
 		statusLine = m.defaultStatus()
 	}
 	sb.WriteString("\n")
@@ -1097,6 +1328,17 @@ func (m Model) View() string {
 	return sb.String()
 }
 
+func (m Model) queueStats() queueStats {
+	qs := queueStats{speed: m.queue.Throughput()}
+	qs.eta, qs.etaOK = m.queue.QueueETA()
+	free, err := m.queue.FreeSpace()
+	if err != nil {
+		free = -1
+	}
+	qs.free = free
+	return qs
+}
+
 func fitToHeight(content string, maxLines int) string {
 	if maxLines <= 0 {
 		return ""
@@ -1117,11 +1359,11 @@ func fitToHeight(content string, maxLines int) string {
 func (m Model) defaultStatus() string {
 	switch m.activeTab {
 	case TabBrowse:
-		return "Arrows:navigate  Enter:open/download  type:filter  Backspace/Esc:clear filter  ?:help"
+		return "Arrows:navigate  Enter:open/download  Ctrl+G:download dir  type:filter  Backspace/Esc:clear filter  ?:help"
 	case TabSearch:
 		return "/:focus search  Arrows:results  Home/End/PgUp/PgDn:scroll  Enter:download  b:open in browser  ?:help"
 	case TabDownloads:
-		return "j/k:navigate  p:pause/resume  c:cancel  R:retry failed  x:clear done  r:refresh  ?:help"
+		return "j/k:navigate  p:pause/resume  c:cancel  R:retry failed/held  x:clear done  r:refresh  ?:help"
 	}
 	return ""
 }
@@ -1140,6 +1382,10 @@ func (m Model) helpView(maxLines int) string {
 		"  Browser:",
 		"    Up/Down       Navigate",
 		"    Enter         Open directory / queue file",
+		"    Ctrl+G        Queue every file below selected directory",
+		"    Ctrl+S        Queue every disc of the selected multi-disc set",
+		"    Ctrl+T        Mark/unmark selected file",
+		"    Ctrl+E        Export marked (or selected) files for aria2/wget/curl",
 		"    Backspace     Remove filter char / go up when filter empty",
 		"    Home/End      Go to top/bottom",
 		"    PgUp / PgDn   Page up/down",
@@ -1154,14 +1400,16 @@ func (m Model) helpView(maxLines int) string {
 		"    PgUp / PgDn   Page up/down",
 		"    Enter         Download selected",
 		"    b / o         Open selected path in browser",
+		"    Ctrl+E        Export all results for aria2/wget/curl",
 		"",
 		"  Downloads:",
 		"    j/k           Navigate",
 		"    p             Pause/resume selected",
 		"    c             Cancel selected",
-		"    R             Retry failed",
+		"    R             Retry failed / held for space",
 		"    x             Clear completed/failed",
 		"    r             Refresh list",
+		"    h             Show/hide download history (f: filter)",
 		"",
 		"  Help view scroll: mouse wheel, j/k, PgUp/PgDn",
 		"  Press ? or Esc to close help.",
@@ -1203,14 +1451,73 @@ func (m *Model) setStatus(msg string) tea.Cmd {
 	})
 }
 
-func (m *Model) enqueueDownload(name, fileURL, subdir string) tea.Cmd {
-	_, created := m.dlManager.Enqueue(name, fileURL, subdir)
+// browseRemotePath returns the remote path of an entry in the current
+// browse directory.
+func (m Model) browseRemotePath(name string) string {
+	return strings.Join(append(append([]string{}, m.browser.path...), name), "/")
+}
+
+// destPreview returns the local path a remote file would be saved to.
+func (m Model) destPreview(remotePath string) string {
+	subdir, name := m.pathTmpl.Resolve(remotePath)
+	profile, _ := downloader.ParseFSProfile(m.cfg.FSProfile)
+	return filepath.Join(m.cfg.DownloadDir, filepath.FromSlash(profile.Subdir(subdir)), profile.Name(name))
+}
+
+// addDownload queues req. Requests sent to a daemon carry the TUI's
+// download directory so files land where the preview said.
+func (m *Model) addDownload(req downloader.Request) (bool, error) {
+	if m.queue.Remote() {
+		if dir, err := filepath.Abs(m.cfg.DownloadDir); err == nil {
+			req.Dir = dir
+		}
+	}
+	return m.queue.Add(req)
+}
+
+func (m *Model) enqueueDownload(remotePath, fileURL, size string) tea.Cmd {
+	subdir, name := m.pathTmpl.Resolve(remotePath)
+	created, err := m.addDownload(downloader.Request{
+		Name:   name,
+		URL:    fileURL,
+		Subdir: subdir,
+		Size:   util.ParseSize(size),
+	})
+	if err != nil {
+		return m.setStatus(fmt.Sprintf("Queueing failed: %v", err))
+	}
 	if !created {
 		return m.setStatus(fmt.Sprintf("Already queued: %s", name))
 	}
 	return m.setStatus(fmt.Sprintf("Queued: %s", name))
 }
 
+// enqueueDiscSet queues every disc of a set with its playlist, in a folder
+// of its own when disc_set_folder is set.
+func (m *Model) enqueueDiscSet(title string, discs []browserEntry) tea.Cmd {
+	queued := 0
+	for _, d := range discs {
+		subdir, name := m.pathTmpl.Resolve(m.browseRemotePath(d.Name))
+		if m.cfg.DiscSetFolder {
+			subdir = path.Join(subdir, title)
+		}
+		created, err := m.addDownload(downloader.Request{
+			Name:     name,
+			URL:      d.URL,
+			Subdir:   subdir,
+			Size:     util.ParseSize(d.Size),
+			Playlist: discset.PlaylistName(title),
+		})
+		if err != nil {
+			return m.setStatus(fmt.Sprintf("Queueing failed: %v", err))
+		}
+		if created {
+			queued++
+		}
+	}
+	return m.setStatus(fmt.Sprintf("Queued disc set %s: %d of %d disc(s) added", title, queued, len(discs)))
+}
+
 func browsePathForSearchResult(filePath string) string {
 	filePath = strings.Trim(filePath, "/")
 	if filePath == "" {
@@ -1238,6 +1545,13 @@ func isTypeAheadKey(key string) bool {
 // Run starts the TUI.
 func Run(c *client.Client, db *index.DB, cfg *config.Config, startPath string, opts RunOptions) error {
 	m := NewModel(c, db, cfg, startPath)
+	m.knownParts = opts.KnownParts
+	if m.historyDB != nil {
+		defer m.historyDB.Close()
+	}
+	if m.libraryDB != nil {
+		defer m.libraryDB.Close()
+	}
 
 	// Wire up download change notifications.
 	programOpts := []tea.ProgramOption{}
@@ -1249,9 +1563,13 @@ func Run(c *client.Client, db *index.DB, cfg *config.Config, startPath string, o
 	}
 	p := tea.NewProgram(m, programOpts...)
 
-	m.dlManager.SetOnChange(func() {
-		go p.Send(downloadUpdateMsg{})
-	})
+	events, unsubscribe := m.queue.Subscribe(64)
+	defer unsubscribe()
+	go func() {
+		for ev := range events {
+			p.Send(downloadEventMsg{ev: ev})
+		}
+	}()
 
 	_, err := p.Run()
 	return err
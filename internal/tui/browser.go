@@ -8,6 +8,7 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/discset"
 	"github.com/JohnDeved/myrient-cli/internal/util"
 )
 
@@ -15,6 +16,8 @@ import (
 type browserEntry struct {
 	client.Entry
 	Marked bool
+	// Owned is set for files a library scan found locally.
+	Owned bool
 }
 
 // browserModel manages the directory browser view.
@@ -244,6 +247,13 @@ func (b *browserModel) setEntries(entries []client.Entry) {
 	b.err = nil
 }
 
+// setOwned flags the files whose names are in owned.
+func (b *browserModel) setOwned(owned map[string]bool) {
+	for i := range b.entries {
+		b.entries[i].Owned = !b.entries[i].IsDir && owned[b.entries[i].Name]
+	}
+}
+
 func (b *browserModel) persistMarks() {
 	if len(b.entries) == 0 {
 		return
@@ -276,6 +286,30 @@ func (b *browserModel) restoreMarks() {
 	}
 }
 
+// discSet returns the set title and the entries of the multi-disc set the
+// named file belongs to, in disc order. ok is false for other files.
+func (b *browserModel) discSet(name string) (title string, discs []browserEntry, ok bool) {
+	names := make([]string, len(b.entries))
+	at := -1
+	for i, e := range b.entries {
+		names[i] = e.Name
+		if e.Name == name && !e.IsDir {
+			at = i
+		}
+	}
+	if at < 0 {
+		return "", nil, false
+	}
+	set, ok := discset.Find(names, at)
+	if !ok {
+		return "", nil, false
+	}
+	for _, i := range set.Indices {
+		discs = append(discs, b.entries[i])
+	}
+	return set.Title, discs, true
+}
+
 func (b *browserModel) setError(err error) {
 	b.err = err
 	b.loading = false
@@ -422,7 +456,9 @@ func (b *browserModel) markedCount() int {
 	return count
 }
 
-func (b *browserModel) view(width int, spin string) string {
+// view renders the listing. preview is the local destination of the selected
+// file, or empty when a directory is selected.
+func (b *browserModel) view(width int, spin string, preview string) string {
 	var sb strings.Builder
 	breadWidth := width - 2
 	if breadWidth < 8 {
@@ -432,6 +468,10 @@ func (b *browserModel) view(width int, spin string) string {
 	// Breadcrumb
 	sb.WriteString(breadcrumbStyle.Render(util.TruncatePath(b.breadcrumb(), breadWidth)))
 	sb.WriteString("\n")
+	if preview != "" {
+		sb.WriteString(helpStyle.Render("  Save to: " + util.TruncatePath(preview, max(20, width-12))))
+	}
+	sb.WriteString("\n")
 
 	if b.loading {
 		sb.WriteString(fmt.Sprintf("\n  %s Loading...\n", spin))
@@ -477,7 +517,7 @@ func (b *browserModel) view(width int, spin string) string {
 	for i := b.offset; i < end; i++ {
 		e := b.entries[visible[i]]
 		isSelected := i == b.cursor
-		line := renderBrowseLikeRow(e.Name, e.Size, e.Date, e.IsDir, rowWidth, isSelected)
+		line := renderBrowseLikeRow(e.Name, e.Size, e.Date, e.IsDir, e.Marked, e.Owned, rowWidth, isSelected)
 		sb.WriteString(line)
 		sb.WriteString("\n")
 	}
@@ -494,7 +534,7 @@ func (b *browserModel) view(width int, spin string) string {
 	return sb.String()
 }
 
-func renderBrowseLikeRow(name, size, date string, isDir bool, rowWidth int, isSelected bool) string {
+func renderBrowseLikeRow(name, size, date string, isDir, marked, owned bool, rowWidth int, isSelected bool) string {
 	var icon string
 	var displayName string
 	if isDir {
@@ -504,6 +544,11 @@ func renderBrowseLikeRow(name, size, date string, isDir bool, rowWidth int, isSe
 		icon = " "
 		displayName = fileStyle.Render(truncateText(name, max(12, rowWidth-35)))
 	}
+	if marked {
+		icon = "*"
+	} else if owned {
+		icon = ownedStyle.Render("✓")
+	}
 
 	line := fmt.Sprintf("  %s%s  %s  %s",
 		icon,
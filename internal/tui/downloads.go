@@ -3,6 +3,7 @@ package tui
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/JohnDeved/myrient-cli/internal/downloader"
 	"github.com/JohnDeved/myrient-cli/internal/util"
@@ -10,7 +11,7 @@ import (
 
 // downloadsModel manages the downloads view.
 type downloadsModel struct {
-	items  []*downloader.Item
+	items  []downloader.Snapshot
 	cursor int
 	offset int
 	height int
@@ -22,7 +23,7 @@ func newDownloadsModel() downloadsModel {
 	}
 }
 
-func (d *downloadsModel) setItems(items []*downloader.Item) {
+func (d *downloadsModel) setItems(items []downloader.Snapshot) {
 	d.items = items
 	if d.cursor >= len(d.items) {
 		d.cursor = len(d.items) - 1
@@ -95,14 +96,22 @@ func (d *downloadsModel) pageDown() {
 	}
 }
 
-func (d *downloadsModel) selected() *downloader.Item {
+func (d *downloadsModel) selected() *downloader.Snapshot {
 	if d.cursor >= 0 && d.cursor < len(d.items) {
-		return d.items[d.cursor]
+		return &d.items[d.cursor]
 	}
 	return nil
 }
 
-func (d *downloadsModel) view(width int) string {
+// queueStats holds manager-wide figures shown above the download list.
+type queueStats struct {
+	free  int64 // unreserved space on the download filesystem, negative when unknown
+	speed float64
+	eta   time.Duration
+	etaOK bool
+}
+
+func (d *downloadsModel) view(width int, qs queueStats) string {
 	var sb strings.Builder
 
 	if len(d.items) == 0 {
@@ -111,9 +120,8 @@ func (d *downloadsModel) view(width int) string {
 	}
 
 	// Stats line.
-	active, queued, completed, failed := 0, 0, 0, 0
+	active, queued, completed, failed, noSpace, present := 0, 0, 0, 0, 0, 0
 	for _, it := range d.items {
-		it.Mu.Lock()
 		switch it.Status {
 		case downloader.StatusActive:
 			active++
@@ -123,13 +131,31 @@ func (d *downloadsModel) view(width int) string {
 			completed++
 		case downloader.StatusFailed:
 			failed++
+		case downloader.StatusNoSpace:
+			noSpace++
+		case downloader.StatusPresent:
+			present++
 		}
-		it.Mu.Unlock()
 	}
 
 	stats := fmt.Sprintf("  Active: %d  Queued: %d  Completed: %d  Failed: %d",
 		active, queued, completed, failed)
+	if present > 0 {
+		stats += fmt.Sprintf("  Present: %d", present)
+	}
+	if qs.speed > 0 {
+		stats += fmt.Sprintf("  Speed: %s/s", util.FormatBytes(int64(qs.speed)))
+	}
+	if qs.etaOK {
+		stats += fmt.Sprintf("  ETA: %s", util.FormatDuration(qs.eta))
+	}
+	if qs.free >= 0 {
+		stats += fmt.Sprintf("  Free: %s", util.FormatBytes(qs.free))
+	}
 	sb.WriteString(helpStyle.Render(stats))
+	if noSpace > 0 {
+		sb.WriteString(errorStyle.Render(fmt.Sprintf("  Insufficient space: %d", noSpace)))
+	}
 	sb.WriteString("\n\n")
 
 	end := d.offset + d.height
@@ -146,15 +172,11 @@ func (d *downloadsModel) view(width int) string {
 		it := d.items[i]
 		isSelected := i == d.cursor
 
-		it.Mu.Lock()
 		status := it.Status
 		name := it.Name
-		errVal := it.Error
-		it.Mu.Unlock()
-
 		progress := it.Progress()
-		speed := it.Speed()
-		done := it.DoneBytes.Load()
+		speed := it.Speed
+		done := it.DoneBytes
 		total := it.TotalBytes
 
 		// Status indicator.
@@ -170,6 +192,13 @@ func (d *downloadsModel) view(width int) string {
 			statusStr = errorStyle.Render("[Failed]")
 		case downloader.StatusPaused:
 			statusStr = helpStyle.Render("[Paused]")
+		case downloader.StatusNoSpace:
+			statusStr = errorStyle.Render("[No space]")
+		case downloader.StatusPresent:
+			statusStr = helpStyle.Render("[Present]")
+		case downloader.StatusRetryWait:
+			wait := time.Until(it.NextRetry)
+			statusStr = markedStyle.Render(fmt.Sprintf("[Retry in %s]", util.FormatDuration(wait)))
 		}
 
 		// Progress bar.
@@ -186,13 +215,23 @@ func (d *downloadsModel) view(width int) string {
 		var speedInfo string
 		if status == downloader.StatusActive && speed > 0 {
 			speedInfo = fmt.Sprintf(" %s/s", util.FormatBytes(int64(speed)))
+			if it.ETAKnown {
+				speedInfo += " ETA " + util.FormatDuration(it.ETA)
+			}
 		}
 
 		line := fmt.Sprintf("  %s %s  %s  %s%s",
 			statusStr, name, bar, sizeInfo, speedInfo)
 
-		if errVal != nil {
-			line += "  " + errorStyle.Render(errVal.Error())
+		if it.Attempts > 1 {
+			line += helpStyle.Render(fmt.Sprintf("  (attempt %d)", it.Attempts))
+		}
+		if it.Error != "" {
+			reason := it.Error
+			if it.Class != downloader.ClassNone {
+				reason = it.Class.String() + ": " + reason
+			}
+			line += "  " + errorStyle.Render(reason)
 		}
 
 		if isSelected {
@@ -207,6 +246,15 @@ func (d *downloadsModel) view(width int) string {
 			sb.WriteString(dest)
 			sb.WriteString("\n")
 		}
+
+		// Attempt history for the selected item.
+		if isSelected {
+			for n, a := range it.History {
+				entry := fmt.Sprintf("    #%d %s  %s: %s", n+1, a.At.Format("15:04:05"), a.Class, a.Error)
+				sb.WriteString(helpStyle.Render(truncateText(entry, max(20, width-2))))
+				sb.WriteString("\n")
+			}
+		}
 	}
 
 	if len(d.items) > d.height {
@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/JohnDeved/myrient-cli/internal/export"
+)
+
+// exportFiles writes a download list for the given remote paths and URLs to
+// a timestamped file in the download directory, in the configured
+// export_format.
+func (m *Model) exportFiles(remotePaths, urls []string) tea.Cmd {
+	if len(urls) == 0 {
+		return m.setStatus("Nothing to export")
+	}
+	format, err := export.ParseFormat(m.cfg.ExportFormat)
+	if err != nil {
+		return m.setStatus(fmt.Sprintf("Export failed: %v", err))
+	}
+
+	entries := make([]export.Entry, 0, len(urls))
+	for i, u := range urls {
+		subdir, name := m.pathTmpl.Resolve(remotePaths[i])
+		entries = append(entries, export.Entry{URL: u, Path: strings.TrimPrefix(subdir+"/"+name, "/")})
+	}
+
+	if err := os.MkdirAll(m.cfg.DownloadDir, 0o755); err != nil {
+		return m.setStatus(fmt.Sprintf("Export failed: %v", err))
+	}
+	name := fmt.Sprintf("myrient-export-%s.%s", time.Now().Format("20060102-150405"), format.Ext())
+	path := filepath.Join(m.cfg.DownloadDir, name)
+	mode := os.FileMode(0o644)
+	if format == export.Wget || format == export.Curl {
+		mode = 0o755
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return m.setStatus(fmt.Sprintf("Export failed: %v", err))
+	}
+	err = export.Write(f, format, m.cfg.DownloadDir, entries)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return m.setStatus(fmt.Sprintf("Export failed: %v", err))
+	}
+	return m.setStatus(fmt.Sprintf("Exported %d file(s) as %s to %s", len(entries), format, path))
+}
+
+// exportBrowse exports the marked files of the current directory, or the
+// selected file when none are marked.
+func (m *Model) exportBrowse() tea.Cmd {
+	entries := m.browser.markedEntries()
+	if len(entries) == 0 {
+		if sel := m.browser.selected(); sel != nil && !sel.IsDir {
+			entries = append(entries, *sel)
+		}
+	}
+	if len(entries) == 0 {
+		return m.setStatus("Mark files with Ctrl+T to export them")
+	}
+	var paths, urls []string
+	for _, e := range entries {
+		paths = append(paths, m.browseRemotePath(e.Name))
+		urls = append(urls, e.URL)
+	}
+	return m.exportFiles(paths, urls)
+}
+
+// exportSearchResults exports every current search result.
+func (m *Model) exportSearchResults() tea.Cmd {
+	var paths, urls []string
+	for _, r := range m.search.results {
+		paths = append(paths, r.Path)
+		urls = append(urls, r.URL)
+	}
+	return m.exportFiles(paths, urls)
+}
@@ -0,0 +1,191 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/JohnDeved/myrient-cli/internal/history"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+)
+
+// historyLimit caps how many past downloads the history view loads.
+const historyLimit = 500
+
+// historyLoadedMsg carries entries read from the history database.
+type historyLoadedMsg struct {
+	entries []history.Entry
+	err     error
+}
+
+// historyModel lists finished downloads from the history database. It is
+// shown in place of the queue on the downloads tab.
+type historyModel struct {
+	entries []history.Entry
+	cursor  int
+	offset  int
+	height  int
+	// status filters by history.StatusCompleted or StatusFailed; empty
+	// shows everything.
+	status string
+	err    error
+}
+
+func (h *historyModel) setEntries(entries []history.Entry) {
+	h.entries = entries
+	if h.cursor >= len(h.entries) {
+		h.cursor = max(0, len(h.entries)-1)
+	}
+	if h.offset > h.cursor {
+		h.offset = h.cursor
+	}
+}
+
+// cycleStatus switches between all, completed and failed entries.
+func (h *historyModel) cycleStatus() {
+	switch h.status {
+	case "":
+		h.status = history.StatusCompleted
+	case history.StatusCompleted:
+		h.status = history.StatusFailed
+	default:
+		h.status = ""
+	}
+	h.cursor = 0
+	h.offset = 0
+}
+
+// rows is the number of entries that fit; each entry takes two lines.
+func (h *historyModel) rows() int {
+	return max(1, h.height/2)
+}
+
+func (h *historyModel) moveUp() {
+	if h.cursor > 0 {
+		h.cursor--
+		if h.cursor < h.offset {
+			h.offset = h.cursor
+		}
+	}
+}
+
+func (h *historyModel) moveDown() {
+	if h.cursor < len(h.entries)-1 {
+		h.cursor++
+		if h.cursor >= h.offset+h.rows() {
+			h.offset = h.cursor - h.rows() + 1
+		}
+	}
+}
+
+func (h *historyModel) pageUp() {
+	for i := 0; i < h.rows(); i++ {
+		h.moveUp()
+	}
+}
+
+func (h *historyModel) pageDown() {
+	for i := 0; i < h.rows(); i++ {
+		h.moveDown()
+	}
+}
+
+func (h *historyModel) view(width int) string {
+	var sb strings.Builder
+
+	filter := "all"
+	if h.status != "" {
+		filter = h.status
+	}
+	sb.WriteString(helpStyle.Render(fmt.Sprintf("  History (%s, %d shown)  f: filter  h/Esc: back to queue", filter, len(h.entries))))
+	sb.WriteString("\n\n")
+
+	if h.err != nil {
+		sb.WriteString(errorStyle.Render(fmt.Sprintf("  Error: %v", h.err)))
+		sb.WriteString("\n")
+		return sb.String()
+	}
+	if len(h.entries) == 0 {
+		sb.WriteString(helpStyle.Render("  No downloads recorded."))
+		sb.WriteString("\n")
+		return sb.String()
+	}
+
+	rows := h.rows()
+	end := min(h.offset+rows, len(h.entries))
+	for i := h.offset; i < end; i++ {
+		e := h.entries[i]
+		status := successStyle.Render("[Done]")
+		if e.Status == history.StatusFailed {
+			status = errorStyle.Render("[Failed]")
+		}
+		line := fmt.Sprintf("  %s %s  %s  %s", e.FinishedAt.Format("2006-01-02 15:04"), status, e.Name,
+			sizeStyle.Render(util.FormatBytes(e.Size)))
+		if e.AvgSpeed > 0 {
+			line += helpStyle.Render(fmt.Sprintf("  %s/s", util.FormatBytes(int64(e.AvgSpeed))))
+		}
+		if e.Error != "" {
+			line += "  " + errorStyle.Render(e.Error)
+		}
+		if i == h.cursor {
+			line = selectedStyle.Render(line)
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+
+		detail := "    to: " + e.DestPath
+		if i == h.cursor && e.SHA1 != "" {
+			detail += "  sha1: " + e.SHA1
+		}
+		sb.WriteString(helpStyle.Render(truncateText(detail, max(20, width-2))))
+		sb.WriteString("\n")
+	}
+
+	if len(h.entries) > rows {
+		sb.WriteString(helpStyle.Render(fmt.Sprintf("  %d/%d downloads", h.cursor+1, len(h.entries))))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// loadHistory reads the history database with the view's current filter.
+func (m Model) loadHistory() tea.Cmd {
+	db, status := m.historyDB, m.history.status
+	return func() tea.Msg {
+		if db == nil {
+			return historyLoadedMsg{err: fmt.Errorf("download history is unavailable")}
+		}
+		entries, err := db.List(history.Query{Status: status, Limit: historyLimit})
+		return historyLoadedMsg{entries: entries, err: err}
+	}
+}
+
+// reloadHistory refreshes the history view while it is shown.
+func (m Model) reloadHistory() tea.Cmd {
+	if !m.showHistory {
+		return nil
+	}
+	return m.loadHistory()
+}
+
+func (m Model) handleHistoryKey(key string) (tea.Model, tea.Cmd) {
+	switch key {
+	case "up", "k":
+		m.history.moveUp()
+	case "down", "j":
+		m.history.moveDown()
+	case "pgup", "ctrl+u":
+		m.history.pageUp()
+	case "pgdown", "ctrl+d":
+		m.history.pageDown()
+	case "f":
+		m.history.cycleStatus()
+		return m, m.loadHistory()
+	case "r":
+		return m, m.loadHistory()
+	case "h", "esc":
+		m.showHistory = false
+	}
+	return m, nil
+}
@@ -0,0 +1,54 @@
+package tui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/JohnDeved/myrient-cli/internal/parts"
+)
+
+// partsAdoptedMsg reports the startup scan for partial downloads.
+type partsAdoptedMsg struct {
+	adopted   int
+	unmatched int
+	err       error
+}
+
+// adoptParts queues partial downloads left by earlier sessions as paused
+// items. A daemon does this itself, so only the in-process queue scans.
+func (m Model) adoptParts() tea.Cmd {
+	q, ok := m.queue.(localQueue)
+	if !ok {
+		return nil
+	}
+	root, db, known := m.cfg.DownloadDir, m.db, m.knownParts
+	return func() tea.Msg {
+		found, err := parts.Scan(root, known)
+		if err != nil || len(found) == 0 {
+			return partsAdoptedMsg{err: err}
+		}
+		if db != nil {
+			if err := parts.Match(db, root, found); err != nil {
+				return partsAdoptedMsg{err: err}
+			}
+		}
+		msg := partsAdoptedMsg{adopted: parts.Adopt(q.Manager, found)}
+		msg.unmatched = len(found) - msg.adopted
+		return msg
+	}
+}
+
+func (msg partsAdoptedMsg) status() string {
+	switch {
+	case msg.err != nil:
+		return fmt.Sprintf("Scanning for partial downloads: %v", msg.err)
+	case msg.adopted > 0 && msg.unmatched > 0:
+		return fmt.Sprintf("Found %d partial download(s), paused in the queue; %d unmatched (see 'myrient parts')", msg.adopted, msg.unmatched)
+	case msg.adopted > 0:
+		return fmt.Sprintf("Found %d partial download(s), paused in the queue", msg.adopted)
+	case msg.unmatched > 0:
+		return fmt.Sprintf("%d partial download(s) could not be matched (see 'myrient parts')", msg.unmatched)
+	}
+	return ""
+}
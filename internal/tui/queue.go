@@ -0,0 +1,163 @@
+package tui
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/JohnDeved/myrient-cli/internal/daemon"
+	"github.com/JohnDeved/myrient-cli/internal/downloader"
+)
+
+// downloadQueue is the download backend the TUI drives: either an
+// in-process manager or a running daemon.
+type downloadQueue interface {
+	Add(req downloader.Request) (created bool, err error)
+	Snapshots() []downloader.Snapshot
+	Pause(id int) bool
+	Resume(id int) bool
+	Cancel(id int)
+	Retry(id int) bool
+	ClearFinished() int
+	CancelAll()
+	HasActive() bool
+	ActiveCount() int
+	Throughput() float64
+	QueueETA() (time.Duration, bool)
+	FreeSpace() (int64, error)
+	Subscribe(buffer int) (<-chan downloader.Event, func())
+	// Remote reports whether downloads outlive the TUI.
+	Remote() bool
+}
+
+// localQueue runs downloads inside the TUI process.
+type localQueue struct {
+	*downloader.Manager
+}
+
+func (q localQueue) Add(req downloader.Request) (bool, error) {
+	_, created := q.Manager.Add(req)
+	return created, nil
+}
+
+func (q localQueue) Remote() bool { return false }
+
+// remoteQueue forwards to a daemon. Snapshots refreshes a cached copy of
+// the queue; the cheap per-frame accessors read that cache.
+type remoteQueue struct {
+	dc *daemon.Client
+
+	mu    sync.Mutex
+	items []downloader.Snapshot
+	stats daemon.Stats
+}
+
+func newRemoteQueue(dc *daemon.Client) *remoteQueue {
+	q := &remoteQueue{dc: dc}
+	q.stats.FreeBytes = -1
+	q.Snapshots()
+	return q
+}
+
+func (q *remoteQueue) Add(req downloader.Request) (bool, error) {
+	added, err := q.dc.Add([]downloader.Request{req})
+	if err != nil {
+		return false, err
+	}
+	return len(added) == 1 && added[0].Created, nil
+}
+
+func (q *remoteQueue) Snapshots() []downloader.Snapshot {
+	items, stats, err := q.dc.List()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err == nil {
+		q.items = items
+		if stats != nil {
+			q.stats = *stats
+		}
+	}
+	return q.items
+}
+
+func (q *remoteQueue) Pause(id int) bool {
+	n, err := q.dc.Pause(id)
+	return err == nil && n > 0
+}
+
+func (q *remoteQueue) Resume(id int) bool {
+	n, err := q.dc.Resume(id)
+	return err == nil && n > 0
+}
+
+func (q *remoteQueue) Cancel(id int) {
+	q.dc.Cancel(id)
+}
+
+func (q *remoteQueue) Retry(id int) bool {
+	n, err := q.dc.Retry(id)
+	return err == nil && n > 0
+}
+
+func (q *remoteQueue) ClearFinished() int {
+	n, _ := q.dc.ClearFinished()
+	return n
+}
+
+func (q *remoteQueue) CancelAll() {
+	q.dc.CancelAll()
+}
+
+func (q *remoteQueue) HasActive() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, it := range q.items {
+		if it.Status.Pending() {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *remoteQueue) ActiveCount() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	count := 0
+	for _, it := range q.items {
+		if it.Status == downloader.StatusActive {
+			count++
+		}
+	}
+	return count
+}
+
+func (q *remoteQueue) Throughput() float64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats.Throughput
+}
+
+func (q *remoteQueue) QueueETA() (time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats.ETA, q.stats.ETAKnown
+}
+
+func (q *remoteQueue) FreeSpace() (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stats.FreeBytes, nil
+}
+
+func (q *remoteQueue) Subscribe(buffer int) (<-chan downloader.Event, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := q.dc.Watch(ctx)
+	if err != nil {
+		ch := make(chan downloader.Event)
+		close(ch)
+		return ch, cancel
+	}
+	return events, cancel
+}
+
+func (q *remoteQueue) Remote() bool { return true }
@@ -202,7 +202,7 @@ func (s *searchModel) pageDown() {
 	}
 }
 
-func (s *searchModel) view(width int, spin string) string {
+func (s *searchModel) view(width int, spin string, preview string) string {
 	var sb strings.Builder
 	s.normalizeViewport()
 	usedLines := 0
@@ -318,7 +318,7 @@ func (s *searchModel) view(width int, spin string) string {
 	sb.WriteString("\n\n")
 	usedLines += 2
 
-	resultDetailsLines := 2
+	resultDetailsLines := 3
 	scrollInfoLines := 0
 	if len(s.results) > s.pageSize() {
 		scrollInfoLines = 1
@@ -343,7 +343,7 @@ func (s *searchModel) view(width int, spin string) string {
 	for i := s.offset; i < end; i++ {
 		r := s.results[i]
 		isSelected := i == s.cursor
-		line := renderBrowseLikeRow(r.Name, r.Size, r.Date, false, rowWidth, isSelected)
+		line := renderBrowseLikeRow(r.Name, r.Size, r.Date, false, false, false, rowWidth, isSelected)
 		sb.WriteString(line)
 		sb.WriteString("\n")
 	}
@@ -353,6 +353,8 @@ func (s *searchModel) view(width int, spin string) string {
 		sb.WriteString("\n")
 		sb.WriteString(padToWidth(helpStyle.Render("  Collection: "+sel.CollectionName), width))
 		sb.WriteString("\n")
+		sb.WriteString(padToWidth(helpStyle.Render("  Save to: "+util.TruncatePath(preview, max(20, width-13))), width))
+		sb.WriteString("\n")
 	}
 
 	if len(s.results) > availableRows {
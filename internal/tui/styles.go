@@ -83,6 +83,9 @@ var (
 			Foreground(colorWarning).
 			Bold(true)
 
+	ownedStyle = lipgloss.NewStyle().
+			Foreground(colorSuccess)
+
 	progressBarFilled = lipgloss.NewStyle().
 				Foreground(colorSuccess)
 
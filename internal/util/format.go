@@ -1,6 +1,11 @@
 package util
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // FormatBytes formats a byte count into a human-readable string.
 func FormatBytes(b int64) string {
@@ -16,6 +21,115 @@ func FormatBytes(b int64) string {
 	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
 }
 
+// ParseSize parses a listing size such as "1.2M", "512 KiB" or "3.4 GB" into
+// bytes. Directory markers and unparseable values return 0.
+func ParseSize(s string) int64 {
+	num, _, mult, ok := parseSizeParts(s)
+	if !ok {
+		return 0
+	}
+	return int64(num * mult)
+}
+
+// SizeMatches reports whether size bytes is consistent with a rounded
+// listing size such as "1.2 MiB", allowing for the rounding of the last
+// shown digit. Sizes without a unit must match exactly.
+func SizeMatches(size int64, s string) bool {
+	num, decimals, mult, ok := parseSizeParts(s)
+	if !ok {
+		return false
+	}
+	if mult == 1 {
+		return size == int64(num)
+	}
+	tolerance := mult / 2
+	for i := 0; i < decimals; i++ {
+		tolerance /= 10
+	}
+	diff := float64(size) - num*mult
+	return diff >= -tolerance-1 && diff <= tolerance+1
+}
+
+func parseSizeParts(s string) (num float64, decimals int, mult float64, ok bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "-" {
+		return 0, 0, 0, false
+	}
+
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+	num, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil || num < 0 {
+		return 0, 0, 0, false
+	}
+	if dot := strings.IndexByte(s[:i], '.'); dot >= 0 {
+		decimals = i - dot - 1
+	}
+
+	suffix := strings.ToUpper(strings.TrimSpace(s[i:]))
+	suffix = strings.TrimSuffix(strings.TrimSuffix(suffix, "B"), "I")
+	mult = 1
+	switch suffix {
+	case "":
+	case "K":
+		mult = 1 << 10
+	case "M":
+		mult = 1 << 20
+	case "G":
+		mult = 1 << 30
+	case "T":
+		mult = 1 << 40
+	case "P":
+		mult = 1 << 50
+	default:
+		return 0, 0, 0, false
+	}
+	return num, decimals, mult, true
+}
+
+// listingDateLayouts are the date formats seen in directory listings.
+var listingDateLayouts = []string{
+	"02-Jan-2006 15:04",
+	"02-Jan-2006 15:04:05",
+	"2006-Jan-02 15:04",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+}
+
+// ParseListingDate parses the modification date shown in a directory
+// listing, interpreted as UTC.
+func ParseListingDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range listingDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FormatDuration formats a duration compactly, e.g. "1h02m", "3m04s" or "12s".
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Round(time.Second)
+	h := int(d / time.Hour)
+	m := int(d % time.Hour / time.Minute)
+	s := int(d % time.Minute / time.Second)
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case m > 0:
+		return fmt.Sprintf("%dm%02ds", m, s)
+	default:
+		return fmt.Sprintf("%ds", s)
+	}
+}
+
 // TruncatePath truncates a path from the left, keeping the rightmost part visible.
 func TruncatePath(path string, maxLen int) string {
 	if len(path) <= maxLen {
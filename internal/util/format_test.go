@@ -0,0 +1,76 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{
+		"":           0,
+		"-":          0,
+		"123":        123,
+		"1K":         1024,
+		"1.5M":       1572864,
+		"512 KiB":    524288,
+		"2 GiB":      2147483648,
+		"3.0 GB":     3221225472,
+		"garbage":    0,
+		"12 parsecs": 0,
+	}
+	for in, want := range cases {
+		if got := ParseSize(in); got != want {
+			t.Errorf("ParseSize(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		0:                             "0s",
+		12 * time.Second:              "12s",
+		3*time.Minute + 4*time.Second: "3m04s",
+		time.Hour + 2*time.Minute + 9*time.Second: "1h02m",
+		-time.Second: "0s",
+	}
+	for in, want := range cases {
+		if got := FormatDuration(in); got != want {
+			t.Errorf("FormatDuration(%v) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSizeMatches(t *testing.T) {
+	cases := []struct {
+		size int64
+		text string
+		want bool
+	}{
+		{123, "123", true},
+		{124, "123", false},
+		{1258291, "1.2 MiB", true},  // exactly 1.2 MiB
+		{1290000, "1.2 MiB", true},  // within half of 0.1 MiB
+		{1320000, "1.2 MiB", false}, // rounds to 1.3 MiB
+		{3 << 30, "3 GiB", true},    // no decimals: half a GiB either way
+		{3<<30 + 1<<29 + 2, "3 GiB", false},
+		{100, "-", false},
+	}
+	for _, c := range cases {
+		if got := SizeMatches(c.size, c.text); got != c.want {
+			t.Errorf("SizeMatches(%d, %q) = %v, want %v", c.size, c.text, got, c.want)
+		}
+	}
+}
+
+func TestParseListingDate(t *testing.T) {
+	want := time.Date(2024, 3, 5, 14, 7, 0, 0, time.UTC)
+	for _, s := range []string{"05-Mar-2024 14:07", "2024-03-05 14:07", "2024-Mar-05 14:07"} {
+		got, ok := ParseListingDate(s)
+		if !ok || !got.Equal(want) {
+			t.Errorf("ParseListingDate(%q) = %v, %v", s, got, ok)
+		}
+	}
+	if _, ok := ParseListingDate("yesterday"); ok {
+		t.Error("expected failure for unknown format")
+	}
+}
@@ -0,0 +1,208 @@
+// Package walk enumerates remote directory trees, either by live listing or
+// from the local index, and filters the files found.
+package walk
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/JohnDeved/myrient-cli/internal/client"
+	"github.com/JohnDeved/myrient-cli/internal/index"
+	"github.com/JohnDeved/myrient-cli/internal/util"
+)
+
+// File is a remote file found under a walked directory.
+type File struct {
+	// RelPath is the path relative to the walked directory, using "/".
+	RelPath  string
+	Name     string
+	URL      string
+	Size     int64
+	SizeText string
+	// Date is the modification date as shown in the listing.
+	Date string
+}
+
+// RelDir returns the directory part of RelPath, or "" for top-level files.
+func (f File) RelDir() string {
+	dir := path.Dir(f.RelPath)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// Filter selects files by name pattern and size. Patterns are shell globs
+// matched against the file name, or against the relative path when they
+// contain a "/". A "re:" prefix makes a pattern a regular expression matched
+// against the relative path.
+type Filter struct {
+	include []matcher
+	exclude []matcher
+	MinSize int64
+	MaxSize int64
+}
+
+type matcher func(relPath, name string) bool
+
+// NewFilter compiles include and exclude patterns. A file must match at
+// least one include pattern (when any are given) and no exclude pattern.
+// Zero sizes disable the corresponding limit.
+func NewFilter(include, exclude []string, minSize, maxSize int64) (*Filter, error) {
+	f := &Filter{MinSize: minSize, MaxSize: maxSize}
+	for _, p := range include {
+		m, err := compilePattern(p)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, m)
+	}
+	for _, p := range exclude {
+		m, err := compilePattern(p)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, m)
+	}
+	return f, nil
+}
+
+func compilePattern(p string) (matcher, error) {
+	if expr, ok := strings.CutPrefix(p, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", expr, err)
+		}
+		return func(relPath, _ string) bool { return re.MatchString(relPath) }, nil
+	}
+	if _, err := path.Match(p, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", p, err)
+	}
+	lower := strings.ToLower(p)
+	if strings.Contains(p, "/") {
+		return func(relPath, _ string) bool {
+			ok, _ := path.Match(lower, strings.ToLower(relPath))
+			return ok
+		}, nil
+	}
+	return func(_, name string) bool {
+		ok, _ := path.Match(lower, strings.ToLower(name))
+		return ok
+	}, nil
+}
+
+// Match reports whether a file passes the filter. Files of unknown size
+// (0) are not subject to size limits.
+func (f *Filter) Match(file File) bool {
+	if f == nil {
+		return true
+	}
+	if file.Size > 0 {
+		if f.MinSize > 0 && file.Size < f.MinSize {
+			return false
+		}
+		if f.MaxSize > 0 && file.Size > f.MaxSize {
+			return false
+		}
+	}
+	if len(f.include) > 0 {
+		ok := false
+		for _, m := range f.include {
+			if m(file.RelPath, file.Name) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for _, m := range f.exclude {
+		if m(file.RelPath, file.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Remote walks dirPath recursively using live directory listings.
+func Remote(ctx context.Context, c *client.Client, dirPath string, f *Filter) ([]File, error) {
+	root := normalizeDir(dirPath)
+	var files []File
+	var walkDir func(rel string) error
+	walkDir = func(rel string) error {
+		entries, err := c.ListDirectory(ctx, root+rel)
+		if err != nil {
+			return fmt.Errorf("listing /%s%s: %w", root, rel, err)
+		}
+		for _, e := range entries {
+			if e.IsDir {
+				if err := walkDir(rel + e.Name + "/"); err != nil {
+					return err
+				}
+				continue
+			}
+			file := File{
+				RelPath:  rel + e.Name,
+				Name:     e.Name,
+				URL:      e.URL,
+				Size:     util.ParseSize(e.Size),
+				SizeText: e.Size,
+				Date:     e.Date,
+			}
+			if f.Match(file) {
+				files = append(files, file)
+			}
+		}
+		return nil
+	}
+	if err := walkDir(""); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Indexed lists files under dirPath from the local index without touching
+// the network. Directories that were never crawled are simply missing.
+func Indexed(db *index.DB, dirPath string, f *Filter) ([]File, error) {
+	root := normalizeDir(dirPath)
+	records, err := db.FilesUnder(root)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]File, 0, len(records))
+	for _, r := range records {
+		file := File{
+			RelPath:  strings.TrimPrefix(r.Path, root),
+			Name:     r.Name,
+			URL:      r.URL,
+			Size:     util.ParseSize(r.Size),
+			SizeText: r.Size,
+			Date:     r.Date,
+		}
+		if f.Match(file) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// TotalSize sums the known sizes of files.
+func TotalSize(files []File) int64 {
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	return total
+}
+
+func normalizeDir(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return p + "/"
+}
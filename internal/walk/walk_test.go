@@ -0,0 +1,39 @@
+package walk
+
+import "testing"
+
+func TestFilter_GlobRegexAndSize(t *testing.T) {
+	f, err := NewFilter(
+		[]string{"*(europe)*.zip", "re:^Beta/"},
+		[]string{"*(demo)*"},
+		0, 10<<20,
+	)
+	if err != nil {
+		t.Fatalf("NewFilter returned error: %v", err)
+	}
+
+	cases := []struct {
+		file File
+		want bool
+	}{
+		{File{RelPath: "Game (Europe).zip", Name: "Game (Europe).zip", Size: 1 << 20}, true},
+		{File{RelPath: "Game (USA).zip", Name: "Game (USA).zip", Size: 1 << 20}, false},
+		{File{RelPath: "Game (Europe) (Demo).zip", Name: "Game (Europe) (Demo).zip"}, false},
+		{File{RelPath: "Big (Europe).zip", Name: "Big (Europe).zip", Size: 20 << 20}, false},
+		{File{RelPath: "Beta/Proto.bin", Name: "Proto.bin"}, true},
+	}
+	for _, tc := range cases {
+		if got := f.Match(tc.file); got != tc.want {
+			t.Errorf("Match(%q) = %v, want %v", tc.file.RelPath, got, tc.want)
+		}
+	}
+}
+
+func TestNewFilter_RejectsBadPatterns(t *testing.T) {
+	if _, err := NewFilter([]string{"re:("}, nil, 0, 0); err == nil {
+		t.Fatal("expected error for invalid regex")
+	}
+	if _, err := NewFilter(nil, []string{"[a-"}, 0, 0); err == nil {
+		t.Fatal("expected error for invalid glob")
+	}
+}
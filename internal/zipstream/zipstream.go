@@ -0,0 +1,263 @@
+// Package zipstream reads ZIP archives front to back, from local file
+// headers alone, so members can be extracted while the archive is still
+// downloading. Only members whose sizes are known up front can be streamed:
+// archives written with data descriptors, encrypted members and
+// compression methods other than store and deflate are reported as
+// unstreamable, and callers fall back to reading the complete archive.
+package zipstream
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	localHeaderSig   = 0x04034b50
+	centralHeaderSig = 0x02014b50
+	endOfCentralSig  = 0x06054b50
+	zip64EndSig      = 0x06064b50
+
+	localHeaderLen = 30
+
+	flagEncrypted      = 0x1
+	flagDataDescriptor = 0x8
+
+	methodStore   = 0
+	methodDeflate = 8
+
+	zip64ExtraID = 0x0001
+)
+
+// ErrUnstreamable is returned by Next for a member that cannot be extracted
+// without the archive's central directory.
+var ErrUnstreamable = errors.New("member cannot be streamed")
+
+// ErrChecksum is returned when an extracted member does not match its CRC-32.
+var ErrChecksum = errors.New("zip member checksum mismatch")
+
+// Header describes one archive member, as read from its local file header.
+type Header struct {
+	Name             string
+	Method           uint16
+	Flags            uint16
+	CRC32            uint32
+	CompressedSize   uint64
+	UncompressedSize uint64
+	Modified         time.Time
+	// Offset is the position of the local file header in the archive.
+	Offset int64
+}
+
+// IsDir reports whether the member is a directory entry.
+func (h *Header) IsDir() bool {
+	return strings.HasSuffix(h.Name, "/")
+}
+
+// Reader walks the members of a ZIP stream.
+type Reader struct {
+	r   io.Reader
+	off int64
+	// raw holds the bytes of the last header read, so Rest can hand the
+	// stream back from the start of that member.
+	raw []byte
+	// left is the unread compressed data of the current member.
+	left int64
+}
+
+// NewReader reads an archive stream whose first byte is at offset in the
+// archive. offset must be the start of a local file header (or of the
+// central directory), e.g. 0 or a value taken from Offset.
+func NewReader(r io.Reader, offset int64) *Reader {
+	return &Reader{r: r, off: offset}
+}
+
+// Offset returns the archive position of the next unread byte. Between
+// members it is where the next local file header starts.
+func (z *Reader) Offset() int64 {
+	return z.off
+}
+
+func (z *Reader) read(p []byte) error {
+	n, err := io.ReadFull(z.r, p)
+	z.off += int64(n)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Next skips whatever is left of the current member and reads the next
+// local file header. It returns io.EOF once the central directory is
+// reached. For members that cannot be streamed it returns the header along
+// with an error wrapping ErrUnstreamable; Rest then yields the stream from
+// that member on.
+func (z *Reader) Next() (*Header, error) {
+	if z.left > 0 {
+		n, err := io.CopyN(io.Discard, z.r, z.left)
+		z.off += n
+		z.left -= n
+		if err != nil {
+			return nil, fmt.Errorf("skipping member: %w", err)
+		}
+	}
+	start := z.off
+	var buf [localHeaderLen]byte
+	if err := z.read(buf[:4]); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	switch binary.LittleEndian.Uint32(buf[:4]) {
+	case localHeaderSig:
+	case centralHeaderSig, endOfCentralSig, zip64EndSig:
+		return nil, io.EOF
+	default:
+		return nil, fmt.Errorf("no zip header at offset %d", start)
+	}
+	if err := z.read(buf[4:]); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	le := binary.LittleEndian
+	h := &Header{
+		Flags:            le.Uint16(buf[6:]),
+		Method:           le.Uint16(buf[8:]),
+		Modified:         msDosTime(le.Uint16(buf[12:]), le.Uint16(buf[10:])),
+		CRC32:            le.Uint32(buf[14:]),
+		CompressedSize:   uint64(le.Uint32(buf[18:])),
+		UncompressedSize: uint64(le.Uint32(buf[22:])),
+		Offset:           start,
+	}
+	vars := make([]byte, int(le.Uint16(buf[26:]))+int(le.Uint16(buf[28:])))
+	if err := z.read(vars); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	nameLen := int(le.Uint16(buf[26:]))
+	h.Name = string(vars[:nameLen])
+	if h.CompressedSize == 0xffffffff || h.UncompressedSize == 0xffffffff {
+		readZip64Sizes(h, vars[nameLen:])
+	}
+	z.raw = append(append(z.raw[:0], buf[:]...), vars...)
+
+	switch {
+	case h.Flags&flagDataDescriptor != 0:
+		return h, fmt.Errorf("%w: %s has a data descriptor", ErrUnstreamable, h.Name)
+	case h.Flags&flagEncrypted != 0:
+		return h, fmt.Errorf("%w: %s is encrypted", ErrUnstreamable, h.Name)
+	case h.Method != methodStore && h.Method != methodDeflate:
+		return h, fmt.Errorf("%w: %s uses compression method %d", ErrUnstreamable, h.Name, h.Method)
+	case h.CompressedSize == 0xffffffff || h.UncompressedSize == 0xffffffff:
+		return h, fmt.Errorf("%w: %s has no usable size", ErrUnstreamable, h.Name)
+	}
+	z.left = int64(h.CompressedSize)
+	return h, nil
+}
+
+// Rest returns the remaining archive stream starting at the local header
+// last returned by Next. Use it to hand an unstreamable member and
+// everything after it to a fallback. The Reader must not be used after.
+func (z *Reader) Rest() io.Reader {
+	return io.MultiReader(bytes.NewReader(z.raw), z.r)
+}
+
+// Open returns the uncompressed contents of the current member. Reading it
+// to the end verifies the size and CRC-32 from the header.
+func (z *Reader) Open(h *Header) io.Reader {
+	src := &memberReader{z: z}
+	var rc io.Reader = src
+	if h.Method == methodDeflate {
+		rc = flate.NewReader(src)
+	}
+	return &checksumReader{r: rc, h: h, crc: crc32.NewIEEE()}
+}
+
+// memberReader reads the compressed data of the current member.
+type memberReader struct {
+	z *Reader
+}
+
+func (m *memberReader) Read(p []byte) (int, error) {
+	if m.z.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > m.z.left {
+		p = p[:m.z.left]
+	}
+	n, err := m.z.r.Read(p)
+	m.z.off += int64(n)
+	m.z.left -= int64(n)
+	if errors.Is(err, io.EOF) && m.z.left > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+type checksumReader struct {
+	r   io.Reader
+	h   *Header
+	crc interface {
+		io.Writer
+		Sum32() uint32
+	}
+	n uint64
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.crc.Write(p[:n])
+	c.n += uint64(n)
+	if errors.Is(err, io.EOF) {
+		if c.n != c.h.UncompressedSize {
+			return n, fmt.Errorf("%s: got %d of %d bytes: %w", c.h.Name, c.n, c.h.UncompressedSize, io.ErrUnexpectedEOF)
+		}
+		if c.crc.Sum32() != c.h.CRC32 {
+			return n, fmt.Errorf("%s: %w", c.h.Name, ErrChecksum)
+		}
+	}
+	return n, err
+}
+
+// readZip64Sizes fills the sizes from a ZIP64 extra field. In local headers
+// the field holds both sizes, uncompressed first.
+func readZip64Sizes(h *Header, extra []byte) {
+	le := binary.LittleEndian
+	for len(extra) >= 4 {
+		id, size := le.Uint16(extra), int(le.Uint16(extra[2:]))
+		extra = extra[4:]
+		if size > len(extra) {
+			return
+		}
+		if id == zip64ExtraID && size >= 16 {
+			h.UncompressedSize = le.Uint64(extra)
+			h.CompressedSize = le.Uint64(extra[8:])
+			return
+		}
+		extra = extra[size:]
+	}
+}
+
+// msDosTime converts an MS-DOS date and time into a time.Time.
+func msDosTime(date, clock uint16) time.Time {
+	return time.Date(
+		int(date>>9+1980), time.Month(date>>5&0xf), int(date&0x1f),
+		int(clock>>11), int(clock>>5&0x3f), int(clock&0x1f*2),
+		0, time.Local,
+	)
+}
+
+// LocalPath returns the member name as a clean relative path, or an error
+// when it would escape the extraction directory.
+func LocalPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	clean := path.Clean(strings.TrimSuffix(name, "/"))
+	drive := len(clean) > 1 && clean[1] == ':'
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || path.IsAbs(clean) || drive {
+		return "", fmt.Errorf("unsafe path in archive: %q", name)
+	}
+	return clean, nil
+}
@@ -0,0 +1,133 @@
+package zipstream
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+)
+
+// buildZip writes members without data descriptors, deflating those whose
+// name ends in ".bin".
+func buildZip(t *testing.T, members map[string][]byte, order []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range order {
+		data := members[name]
+		fh := &zip.FileHeader{
+			Name:               name,
+			Method:             zip.Store,
+			CRC32:              crc32.ChecksumIEEE(data),
+			UncompressedSize64: uint64(len(data)),
+		}
+		raw := data
+		if bytes.HasSuffix([]byte(name), []byte(".bin")) {
+			var c bytes.Buffer
+			fw, _ := flate.NewWriter(&c, flate.BestCompression)
+			fw.Write(data)
+			fw.Close()
+			fh.Method, raw = zip.Deflate, c.Bytes()
+		}
+		fh.CompressedSize64 = uint64(len(raw))
+		fw, err := w.CreateRaw(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(raw)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReader_StreamsMembers(t *testing.T) {
+	members := map[string][]byte{
+		"a.txt":     []byte("plain stored member"),
+		"dir/b.bin": bytes.Repeat([]byte("compressible "), 1000),
+	}
+	archive := buildZip(t, members, []string{"a.txt", "dir/b.bin"})
+
+	zr := NewReader(bytes.NewReader(archive), 0)
+	var offsets []int64
+	for {
+		h, err := zr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		offsets = append(offsets, h.Offset)
+		got, err := io.ReadAll(zr.Open(h))
+		if err != nil {
+			t.Fatalf("%s: %v", h.Name, err)
+		}
+		if !bytes.Equal(got, members[h.Name]) {
+			t.Errorf("%s: contents differ", h.Name)
+		}
+	}
+	if len(offsets) != 2 || offsets[0] != 0 {
+		t.Fatalf("offsets = %v", offsets)
+	}
+
+	// Starting at the second member's offset yields just that member.
+	zr = NewReader(bytes.NewReader(archive[offsets[1]:]), offsets[1])
+	h, err := zr.Next()
+	if err != nil || h.Name != "dir/b.bin" || h.Offset != offsets[1] {
+		t.Fatalf("resumed Next = %+v, %v", h, err)
+	}
+}
+
+func TestReader_DetectsCorruption(t *testing.T) {
+	archive := buildZip(t, map[string][]byte{"a.txt": []byte("some data")}, []string{"a.txt"})
+	archive[bytes.Index(archive, []byte("some"))] = 'S'
+	zr := NewReader(bytes.NewReader(archive), 0)
+	h, err := zr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(zr.Open(h)); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("err = %v, want ErrChecksum", err)
+	}
+}
+
+func TestReader_DataDescriptorIsUnstreamable(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	fw, _ := w.Create("described.txt")
+	fw.Write([]byte("written with a data descriptor"))
+	w.Close()
+
+	zr := NewReader(bytes.NewReader(buf.Bytes()), 0)
+	h, err := zr.Next()
+	if !errors.Is(err, ErrUnstreamable) || h == nil {
+		t.Fatalf("Next = %+v, %v; want ErrUnstreamable", h, err)
+	}
+	rest, _ := io.ReadAll(zr.Rest())
+	if !bytes.Equal(rest, buf.Bytes()) {
+		t.Error("Rest did not return the stream from the member's header")
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	for name, want := range map[string]string{
+		"a/b.txt":   "a/b.txt",
+		`dir\c.txt`: "dir/c.txt",
+		"sub/":      "sub",
+		"./x/../y":  "y",
+	} {
+		if got, err := LocalPath(name); err != nil || got != want {
+			t.Errorf("LocalPath(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	for _, name := range []string{"../evil", "/etc/passwd", "C:/x", "a/../../b"} {
+		if _, err := LocalPath(name); err == nil {
+			t.Errorf("LocalPath(%q) accepted an unsafe path", name)
+		}
+	}
+}